@@ -0,0 +1,85 @@
+package merkle_patricia_trie
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/example/infra/db/merkle_patricia_trie/mptproof"
+	"github.com/example/infra/db/merkle_patricia_trie/trie"
+	"github.com/example/service/crypto"
+)
+
+// An absence proof is a MerklePath starting at the node where the lookup of the key terminates:
+// either a branch without a child for the next nibble, or an extension which diverges from the key,
+// ends before it without a next node, or matches it without holding a value.
+// A trie without keys is proven by its root level alone.
+
+var errKeyExists = fmt.Errorf("key exists")
+
+func (mt *MerklePatriciaTrie) absencePathInExtension(key string, node trie.NodeExtension) (MerklePath, error) {
+	mt.touch()
+	if key == node.Key() {
+		if node.HasValueObject() {
+			return nil, errKeyExists
+		}
+		return MerklePath{extensionSet(node, true)}, nil
+	}
+	if !strings.HasPrefix(key, node.Key()) || !node.HasNext() {
+		return MerklePath{extensionSet(node, true)}, nil
+	}
+
+	keyTail := key[len(node.Key()):]
+	var path MerklePath
+	var err error
+	switch next := node.Next().(type) {
+	case trie.NodeExtension:
+		path, err = mt.absencePathInExtension(keyTail, next)
+	case trie.NodeBranch:
+		path, err = mt.absencePathInBranch(keyTail, next)
+	default:
+		panic("Unknown node type")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return append(path, extensionSet(node, false)), nil
+}
+
+func (mt *MerklePatriciaTrie) absencePathInBranch(key string, node trie.NodeBranch) (MerklePath, error) {
+	mt.touch()
+	c := key[0]
+	if !node.HasChildAt(c) {
+		return MerklePath{branchSet(node)}, nil
+	}
+	path, err := mt.absencePathInExtension(key, node.ChildAt(c))
+	if err != nil {
+		return nil, err
+	}
+	return append(path, branchSet(node)), nil
+}
+
+// ProveAbsence returns a path showing that key is not in the trie. It fails if the key exists.
+func (mt *MerklePatriciaTrie) ProveAbsence(key []byte) (MerklePath, error) {
+	key, err := mt.forwardKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(key) == 0 {
+		return nil, ErrEmptyKey
+	}
+	root := MerkleSet{hashes: []trie.HashBlob{mt.root.Hash()}}
+	if !branchSet(mt.root).isBranch() {
+		return MerklePath{root}, nil
+	}
+	path, err := mt.absencePathInBranch(hex.EncodeToString(key), mt.root)
+	if err != nil {
+		return nil, err
+	}
+	return append(path, root), nil
+}
+
+// VerifyAbsence checks that path proves key is not stored under rootHash.
+func VerifyAbsence(hs crypto.Hash, rootHash trie.HashBlob, key []byte, path MerklePath) error {
+	return mptproof.VerifyAbsence(hs, rootHash, key, path.ProofPath())
+}
@@ -0,0 +1,103 @@
+package merkle_patricia_trie
+
+import (
+	"testing"
+)
+
+func TestMerklePatriciaTrie_ProveAbsence(t *testing.T) {
+	hs := hashService(t)
+
+	{
+		t.Log("Empty trie")
+
+		mt := NewMerklePatriciaTrie(hs)
+		path, err := mt.ProveAbsence([]byte("key"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := VerifyAbsence(hs, mt.RootHash(), []byte("key"), path); err != nil {
+			t.Errorf("Valid absence proof is rejected: %s", err)
+		}
+	}
+
+	mt := NewMerklePatriciaTrie(hs)
+	present := []string{"key", "key123", "key12ab", "dog", "doge", "cat"}
+	for _, key := range present {
+		if err := mt.Insert([]byte(key), []byte("v"+key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := mt.Insert([]byte("kez"), []byte("vkez")); err != nil {
+		t.Fatal(err)
+	}
+	if err := mt.Delete([]byte("kez")); err != nil {
+		t.Fatal(err)
+	}
+
+	{
+		t.Log("Absent keys are proven")
+
+		// Missing branch child, divergence inside an extension, key ending inside an extension,
+		// extension without value and key running past a leaf
+		for _, key := range []string{"x", "ke", "kez", "key1", "key12", "key1234", "do", "dogs", "cab", "\x00"} {
+			path, err := mt.ProveAbsence([]byte(key))
+			if err != nil {
+				t.Fatalf("Failed to prove absence of %q: %s", key, err)
+			}
+			if err := VerifyAbsence(hs, mt.RootHash(), []byte(key), path); err != nil {
+				t.Errorf("Valid absence proof of %q is rejected: %s", key, err)
+			}
+		}
+	}
+	{
+		t.Log("Present keys cannot be proven absent")
+
+		for _, key := range present {
+			if _, err := mt.ProveAbsence([]byte(key)); err == nil {
+				t.Errorf("Absence of present key %s must not be proven", key)
+			}
+		}
+		// Reusing the proof of another key must not work either
+		for _, pair := range [][2]string{{"key1234", "key123"}, {"key12", "key12ab"}, {"dogs", "doge"}, {"key1", "key"}, {"cab", "dog"}} {
+			path, err := mt.ProveAbsence([]byte(pair[0]))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := VerifyAbsence(hs, mt.RootHash(), []byte(pair[1]), path); err == nil {
+				t.Errorf("Absence proof of %s must not prove absence of %s", pair[0], pair[1])
+			}
+		}
+		// The proof ends at an extension below the root branch, and "q" takes another child of that branch
+		small := NewMerklePatriciaTrie(hs)
+		for _, key := range []string{"a", "q"} {
+			if err := small.Insert([]byte(key), []byte("v"+key)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		path, err := small.ProveAbsence([]byte("b"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := VerifyAbsence(hs, small.RootHash(), []byte("q"), path); err == nil {
+			t.Error("Absence proof of b must not prove absence of q")
+		}
+		inclusion, err := mt.FindMerklePath([]byte("key"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := VerifyAbsence(hs, mt.RootHash(), []byte("key"), inclusion); err == nil {
+			t.Error("Inclusion proof must not prove absence")
+		}
+	}
+	{
+		t.Log("Proofs against another root are rejected")
+
+		path, err := mt.ProveAbsence([]byte("x"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := VerifyAbsence(hs, NewMerklePatriciaTrie(hs).RootHash(), []byte("x"), path); err == nil {
+			t.Error("Absence proof must not verify against another root")
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package merkle_patricia_trie
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/example/infra/db/merkle_patricia_trie/trie"
+)
+
+type KV struct {
+	Key   []byte
+	Value []byte
+}
+
+// InsertBatch inserts all pairs unhashed and then hashes the touched nodes bottom-up once,
+// instead of rehashing the whole path on every insert.
+// Like Insert, it fails if a key already exists or appears twice. No pair is inserted if it fails.
+func (mt *MerklePatriciaTrie) InsertBatch(pairs []KV) error {
+	if mt.keys != nil {
+		stored := make([]KV, len(pairs))
+		for i, kv := range pairs {
+			key, err := mt.forwardKey(kv.Key)
+			if err != nil {
+				return err
+			}
+			stored[i] = KV{Key: key, Value: kv.Value}
+		}
+		pairs = stored
+	}
+	eks := make([]string, len(pairs))
+	seen := make(map[string]struct{}, len(pairs))
+	for i, kv := range pairs {
+		if len(kv.Key) == 0 {
+			return ErrEmptyKey
+		}
+		ek := hex.EncodeToString(kv.Key)
+		if _, ok := seen[ek]; ok {
+			return fmt.Errorf("key = <%s> appears twice in the batch", ek)
+		}
+		seen[ek] = struct{}{}
+		eks[i] = ek
+	}
+
+	root := copyPathsInBranch(eks, mt.root)
+	mt.deferHash = true
+	defer func() { mt.deferHash = false }()
+	for i, kv := range pairs {
+		value, err := mt.forwardValue(kv.Key, kv.Value, ValueMeta{})
+		if err != nil {
+			return err
+		}
+		if _, err := mt.insertToBranch(eks[i], trie.NewValueObject(value), root, false); err != nil {
+			return err
+		}
+	}
+	if err := mt.rehashBranch(eks, root); err != nil {
+		return err
+	}
+	mt.root = root
+	return nil
+}
+
+// rehashExtension hashes node and every node below it that is on the paths of keys or not hashed yet.
+// Children are hashed before their parents, so every node is hashed once.
+// All keys must start with the key of node.
+func (mt *MerklePatriciaTrie) rehashExtension(keys []string, node trie.NodeExtension) error {
+	if node.HasNext() {
+		var tails []string
+		for _, key := range keys {
+			if len(key) > len(node.Key()) && strings.HasPrefix(key, node.Key()) {
+				tails = append(tails, key[len(node.Key()):])
+			}
+		}
+		if next := node.Next(); len(tails) > 0 || !next.HasHash() {
+			switch next := next.(type) {
+			case trie.NodeExtension:
+				if err := mt.rehashExtension(tails, next); err != nil {
+					return err
+				}
+			case trie.NodeBranch:
+				if err := mt.rehashBranch(tails, next); err != nil {
+					return err
+				}
+			default:
+				panic("Unknown node type")
+			}
+		}
+	}
+	return node.UpdateHash(mt.hs)
+}
+
+func (mt *MerklePatriciaTrie) rehashBranch(keys []string, node trie.NodeBranch) error {
+	groups := make(map[byte][]string)
+	for _, key := range keys {
+		if !node.HasChildAt(key[0]) {
+			panic("Inserted key must have a child under the branch")
+		}
+		groups[key[0]] = append(groups[key[0]], key)
+	}
+	for _, child := range node.ListChildren() {
+		if child == nil {
+			continue
+		}
+		if group := groups[child.Key()[0]]; len(group) > 0 || !child.HasHash() {
+			if err := mt.rehashExtension(group, child); err != nil {
+				return err
+			}
+		}
+	}
+	return node.UpdateHash(mt.hs)
+}
@@ -0,0 +1,94 @@
+package merkle_patricia_trie
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/example/service/crypto"
+)
+
+// countingHash counts the calls to the hash service it wraps.
+type countingHash struct {
+	hs    crypto.Hash
+	calls int
+}
+
+func (h *countingHash) Hash(data []byte) ([]byte, error) {
+	h.calls++
+	return h.hs.Hash(data)
+}
+
+func TestMerklePatriciaTrie_InsertBatch(t *testing.T) {
+	hs := hashService(t)
+
+	{
+		t.Log("Root hash equals the one of sequential Insert()")
+
+		var pairs []KV
+		for i := 0; i < 200; i++ {
+			pairs = append(pairs, KV{[]byte(fmt.Sprintf("key%d", i*7)), []byte(fmt.Sprintf("value%d", i))})
+		}
+		pairs = append(pairs, KV{[]byte("k"), []byte("v")}, KV{[]byte("kk"), []byte("v")}, KV{[]byte("ke"), []byte("v")})
+
+		want := NewMerklePatriciaTrie(hs)
+		for _, kv := range pairs {
+			if err := want.Insert(kv.Key, kv.Value); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		trie := NewMerklePatriciaTrie(hs)
+		if err := trie.InsertBatch(pairs[:100]); err != nil {
+			t.Fatal(err)
+		}
+		if err := trie.InsertBatch(pairs[100:]); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(want.RootHash(), trie.RootHash()) {
+			t.Error("Inconsistent root hash between InsertBatch() and Insert()")
+		}
+	}
+	{
+		t.Log("Every node is hashed once")
+
+		counter := &countingHash{hs: hs}
+		trie := NewMerklePatriciaTrie(counter)
+		var pairs []KV
+		for _, key := range []string{"dog", "doge", "do", "cat", "cab", "horse"} {
+			pairs = append(pairs, KV{[]byte(key), []byte("value-" + key)})
+		}
+		counter.calls = 0
+		if err := trie.InsertBatch(pairs); err != nil {
+			t.Fatal(err)
+		}
+		nodes := 0
+		for it := trie.NewNodeIterator(); it.Next(); {
+			nodes++
+		}
+		if counter.calls != nodes {
+			t.Errorf("Unexpected number of hashes.\n  got = %d\n  want = %d", counter.calls, nodes)
+		}
+	}
+	{
+		t.Log("Invalid batch leaves the trie untouched")
+
+		trie := NewMerklePatriciaTrie(hs)
+		if err := trie.Insert([]byte("dog"), []byte("value")); err != nil {
+			t.Fatal(err)
+		}
+		rootHash := trie.RootHash()
+		for _, pairs := range [][]KV{
+			{{[]byte("cat"), []byte("value")}, {[]byte("dog"), []byte("value")}},
+			{{[]byte("cat"), []byte("value")}, {[]byte("cat"), []byte("value")}},
+			{{[]byte("cat"), []byte("value")}, {nil, []byte("value")}},
+		} {
+			if err := trie.InsertBatch(pairs); err == nil {
+				t.Error("Invalid batch must be rejected")
+			}
+			if ok, _ := trie.Has([]byte("cat")); ok || !bytes.Equal(rootHash, trie.RootHash()) {
+				t.Error("Rejected batch must not modify the trie")
+			}
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package merkle_patricia_trie
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/example/service/crypto"
+)
+
+// Builder collects the configuration of a trie, so a dependency-injection container can provide
+// one constructor instead of repeating NewMerklePatriciaTrie followed by the Use* calls.
+// The trie keeps its nodes in memory and runs nothing in the background, so there is nothing to close.
+type Builder struct {
+	hs         crypto.Hash
+	keys       KeyTransformer
+	transforms []ValueTransform
+	envelopes  bool
+}
+
+func NewBuilder() *Builder {
+	return &Builder{}
+}
+
+func (b *Builder) Hash(hs crypto.Hash) *Builder {
+	b.hs = hs
+	return b
+}
+
+// Keys sets the key transformer, as UseKeys does.
+func (b *Builder) Keys(kt KeyTransformer) *Builder {
+	b.keys = kt
+	return b
+}
+
+// Values appends value transforms, as Use does.
+func (b *Builder) Values(transforms ...ValueTransform) *Builder {
+	b.transforms = append(b.transforms, transforms...)
+	return b
+}
+
+// Envelopes stores values with their ValueMeta, as UseEnvelopes does.
+func (b *Builder) Envelopes() *Builder {
+	b.envelopes = true
+	return b
+}
+
+// Build validates the configuration with ValidateConfig and returns an empty trie.
+// It fails instead of panicking if the hash service cannot hash the root, and if ctx is done.
+func (b *Builder) Build(ctx context.Context) (*MerklePatriciaTrie, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if b.hs == nil {
+		return nil, fmt.Errorf("builder has no hash service")
+	}
+	mt, err := NewValidatedMerklePatriciaTrie(b.hs)
+	if err != nil {
+		return nil, err
+	}
+	mt.keys = b.keys
+	mt.transforms = append([]ValueTransform(nil), b.transforms...)
+	mt.envelopes = b.envelopes
+	return mt, nil
+}
+
+// BuildSecure is Build for a SecureTrie, whose keys are hashed before the key transformer runs.
+func (b *Builder) BuildSecure(ctx context.Context) (*SecureTrie, error) {
+	mt, err := b.Build(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &SecureTrie{mt: mt, preimages: make(map[string][]byte)}, nil
+}
@@ -0,0 +1,72 @@
+package merkle_patricia_trie
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+func TestBuilder_Build(t *testing.T) {
+	hs := hashService(t)
+
+	{
+		t.Log("Build() applies the configuration")
+		mt, err := NewBuilder().Hash(hs).Keys(NewPrefixKeyTransformer([]byte("t:"))).Values(prefixTransform{[]byte("p:")}).Envelopes().Build(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		c, err := mt.Capabilities()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.ValueTransforms != 1 || !c.Envelopes {
+			t.Errorf("Unexpected capabilities = %+v", c)
+		}
+		if err := mt.Insert([]byte("key"), []byte("value")); err != nil {
+			t.Fatal(err)
+		}
+		if value, err := mt.Get([]byte("key")); err != nil || string(value) != "value" {
+			t.Errorf("Unexpected value = <%s>, err: %v", value, err)
+		}
+		path, err := mt.FindMerklePath([]byte("key"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if key, err := path.ProofPath().Key(); err != nil || string(key) != "t:key" {
+			t.Errorf("Key must be stored under the prefix of the key transformer\n  got = %s\n  want = t:key", key)
+		}
+	}
+
+	{
+		t.Log("Build() starts from the root of NewMerklePatriciaTrie()")
+		mt, err := NewBuilder().Hash(hs).Build(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(mt.RootHash(), NewMerklePatriciaTrie(hs).RootHash()) {
+			t.Error("Built trie must start empty")
+		}
+		st, err := NewBuilder().Hash(hs).BuildSecure(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := st.Insert([]byte("key"), []byte("value")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	{
+		t.Log("Build() fails on an invalid configuration instead of panicking")
+		if _, err := NewBuilder().Build(context.Background()); err == nil {
+			t.Error("Build() without a hash service must fail")
+		}
+		if _, err := NewBuilder().Hash(&failingHash{hs: hs, failAt: 1}).Build(context.Background()); err == nil {
+			t.Error("Build() with a failing hash service must fail")
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if _, err := NewBuilder().Hash(hs).Build(ctx); err != context.Canceled {
+			t.Errorf("Build() must fail with the error of a done context, got %v", err)
+		}
+	}
+}
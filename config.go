@@ -0,0 +1,106 @@
+package merkle_patricia_trie
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/example/infra/db/merkle_patricia_trie/mptproof"
+	"github.com/example/infra/db/merkle_patricia_trie/trie"
+	"github.com/example/service/crypto"
+	"github.com/pkg/errors"
+)
+
+// Capabilities reports the configuration a trie commits with.
+type Capabilities struct {
+	// HashSize is the digest size of the hash service in bytes.
+	HashSize int
+	// BranchingFactor is the number of children of a branch node.
+	BranchingFactor int
+	// ProofVersion is the version of the binary encoding of proofs.
+	ProofVersion int
+	// ValueTransforms is the number of value transforms registered with Use.
+	ValueTransforms int
+	// Envelopes is set if values are stored with their ValueMeta.
+	Envelopes bool
+	// SecureKeys is set if keys are stored hashed, as in a SecureTrie.
+	SecureKeys bool
+}
+
+// Capabilities leaves out the hash algorithm and the node store: a crypto.Hash does not name its algorithm,
+// and a trie is kept in memory and only written to the NodeStore passed to Commit.
+func (mt *MerklePatriciaTrie) Capabilities() (Capabilities, error) {
+	size, err := HashSize(mt.hs)
+	if err != nil {
+		return Capabilities{}, err
+	}
+	return Capabilities{
+		HashSize:        size,
+		BranchingFactor: trie.ChildIndexCount,
+		ProofVersion:    mptproof.PathVersion,
+		ValueTransforms: len(mt.transforms),
+		Envelopes:       mt.envelopes,
+	}, nil
+}
+
+// NewValidatedMerklePatriciaTrie is NewMerklePatriciaTrie checking hs with ValidateConfig first.
+// It fails instead of panicking if hs cannot hash the root.
+func NewValidatedMerklePatriciaTrie(hs crypto.Hash) (*MerklePatriciaTrie, error) {
+	mt := &MerklePatriciaTrie{hs: hs}
+	if err := mt.ValidateConfig(); err != nil {
+		return nil, err
+	}
+	root := trie.NewNodeBranch()
+	if err := root.UpdateHash(hs); err != nil {
+		return nil, errors.Wrap(err, "failed to hash the root")
+	}
+	mt.root = root
+	return mt, nil
+}
+
+// NewValidatedSecureMerklePatriciaTrie is NewSecureMerklePatriciaTrie checking hs with ValidateConfig first.
+func NewValidatedSecureMerklePatriciaTrie(hs crypto.Hash) (*SecureTrie, error) {
+	mt, err := NewValidatedMerklePatriciaTrie(hs)
+	if err != nil {
+		return nil, err
+	}
+	return &SecureTrie{mt: mt, preimages: make(map[string][]byte)}, nil
+}
+
+// maxProofHashSize is the largest digest the binary proof encoding can hold in its 1-byte hash size.
+const maxProofHashSize = 255
+
+// ValidateConfig checks the hash service up front, so a misconfigured trie fails right after
+// construction instead of deep in hashing or proof encoding later.
+// The hash service must produce non-empty digests, the same digest for the same input
+// and digests small enough for binary proofs.
+func (mt *MerklePatriciaTrie) ValidateConfig() error {
+	probe := []byte("merkle patricia trie")
+	first, err := mt.hs.Hash(probe)
+	if err != nil {
+		return errors.Wrap(err, "hash service fails")
+	}
+	second, err := mt.hs.Hash(probe)
+	if err != nil {
+		return errors.Wrap(err, "hash service fails")
+	}
+	if len(first) == 0 {
+		return fmt.Errorf("hash service produces empty digests")
+	}
+	if !bytes.Equal(first, second) {
+		return fmt.Errorf("hash service is not deterministic")
+	}
+	if len(first) > maxProofHashSize {
+		return fmt.Errorf("digests of %d bytes exceed the %d bytes binary proofs can hold", len(first), maxProofHashSize)
+	}
+	return nil
+}
+
+// Capabilities reports the configuration of the trie holding the hashed keys.
+func (st *SecureTrie) Capabilities() (Capabilities, error) {
+	c, err := st.mt.Capabilities()
+	if err != nil {
+		return Capabilities{}, err
+	}
+	c.SecureKeys = true
+	return c, nil
+}
@@ -0,0 +1,105 @@
+package merkle_patricia_trie
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/example/service/crypto"
+)
+
+// saltedHash mixes a running counter into every digest.
+type saltedHash struct {
+	hs crypto.Hash
+	n  int
+}
+
+func (h *saltedHash) Hash(data []byte) ([]byte, error) {
+	h.n++
+	return h.hs.Hash(append([]byte(fmt.Sprintf("%d:", h.n)), data...))
+}
+
+// wideHash stretches every digest to size bytes.
+type wideHash struct {
+	hs   crypto.Hash
+	size int
+}
+
+func (h wideHash) Hash(data []byte) ([]byte, error) {
+	d, err := h.hs.Hash(data)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.Repeat(d, h.size/len(d)+1)[:h.size], nil
+}
+
+func TestMerklePatriciaTrie_Capabilities(t *testing.T) {
+	hs := hashService(t)
+
+	mt := NewMerklePatriciaTrie(hs)
+	mt.Use(prefixTransform{[]byte("p:")})
+	c, err := mt.Capabilities()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Capabilities{HashSize: 32, BranchingFactor: 16, ProofVersion: 1, ValueTransforms: 1}
+	if c != want {
+		t.Errorf("Unexpected capabilities.\n  got = %+v\n  want = %+v", c, want)
+	}
+
+	{
+		t.Log("A SecureTrie reports its hashed keys")
+		c, err := NewSecureMerklePatriciaTrie(hs).Capabilities()
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := Capabilities{HashSize: 32, BranchingFactor: 16, ProofVersion: 1, SecureKeys: true}
+		if c != want {
+			t.Errorf("Unexpected capabilities.\n  got = %+v\n  want = %+v", c, want)
+		}
+	}
+}
+
+func TestMerklePatriciaTrie_ValidateConfig(t *testing.T) {
+	hs := hashService(t)
+
+	if err := NewMerklePatriciaTrie(hs).ValidateConfig(); err != nil {
+		t.Errorf("Default configuration must be valid. err: %v", err)
+	}
+
+	invalid := map[string]crypto.Hash{
+		"failing":           &failingHash{hs: hs, failAt: 2},
+		"non-deterministic": &saltedHash{hs: hs},
+		"too wide":          wideHash{hs, 256},
+	}
+	for name, h := range invalid {
+		if err := NewMerklePatriciaTrie(h).ValidateConfig(); err == nil {
+			t.Errorf("Hash service which is %s must be rejected", name)
+		}
+	}
+
+	{
+		t.Log("Validated constructors fail on a broken hash service")
+		for name, h := range invalid {
+			if _, err := NewValidatedMerklePatriciaTrie(h); err == nil {
+				t.Errorf("Construction with a hash service which is %s must fail", name)
+			}
+			if _, err := NewValidatedSecureMerklePatriciaTrie(h); err == nil {
+				t.Errorf("Secure construction with a hash service which is %s must fail", name)
+			}
+		}
+		if _, err := NewValidatedMerklePatriciaTrie(&failingHash{hs: hs, failAt: 3}); err == nil {
+			t.Error("Construction must fail if the root cannot be hashed")
+		}
+		mt, err := NewValidatedMerklePatriciaTrie(hs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(mt.RootHash(), NewMerklePatriciaTrie(hs).RootHash()) {
+			t.Error("Validated trie must start empty")
+		}
+	}
+	if err := NewMerklePatriciaTrie(wideHash{hs, 255}).ValidateConfig(); err != nil {
+		t.Errorf("Digest of 255 bytes must be accepted. err: %v", err)
+	}
+}
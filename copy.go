@@ -0,0 +1,66 @@
+package merkle_patricia_trie
+
+import (
+	"github.com/pkg/errors"
+)
+
+// TrieReader is a source of key/value pairs for CopyFrom, such as another trie,
+// possibly with another hash service and transforms, or a client verifying the pairs it reads.
+type TrieReader interface {
+	// WalkPrefix calls fn for every pair whose key starts with prefix in key order until fn returns false.
+	// An empty prefix selects every pair.
+	WalkPrefix(prefix []byte, fn func(key, value []byte) bool) error
+}
+
+// copyBatchSize is the number of pairs CopyFrom inserts with one InsertBatch.
+const copyBatchSize = 1024
+
+// WalkPrefix calls fn for every pair whose key starts with prefix in key order until fn returns false.
+func (mt *MerklePatriciaTrie) WalkPrefix(prefix []byte, fn func(key, value []byte) bool) error {
+	it := mt.NewPrefixIterator(prefix)
+	for it.Next() {
+		if !fn(it.Key(), it.Value()) {
+			break
+		}
+	}
+	return it.Err()
+}
+
+// CopyFrom inserts every pair of src whose key starts with prefix and returns the number of pairs inserted.
+// Values are read as src hands them out and stored through the key and value transforms of mt,
+// so data can move between hash services and transforms.
+// Pairs are inserted in batches of InsertBatch. Like Insert, it fails if a key exists;
+// the batches inserted before the failure stay.
+func (mt *MerklePatriciaTrie) CopyFrom(src TrieReader, prefix []byte) (int, error) {
+	copied := 0
+	var batch []KV
+	var err error
+	flush := func() error {
+		if err := mt.InsertBatch(batch); err != nil {
+			return errors.Wrapf(err, "failed to copy the batch after %d pairs", copied)
+		}
+		copied += len(batch)
+		batch = batch[:0]
+		return nil
+	}
+	walkErr := src.WalkPrefix(prefix, func(key, value []byte) bool {
+		batch = append(batch, KV{Key: append([]byte(nil), key...), Value: append([]byte(nil), value...)})
+		if len(batch) < copyBatchSize {
+			return true
+		}
+		err = flush()
+		return err == nil
+	})
+	if err != nil {
+		return copied, err
+	}
+	if walkErr != nil {
+		return copied, errors.Wrap(walkErr, "failed to read the source")
+	}
+	if len(batch) > 0 {
+		if err := flush(); err != nil {
+			return copied, err
+		}
+	}
+	return copied, nil
+}
@@ -0,0 +1,69 @@
+package merkle_patricia_trie
+
+import (
+	"strings"
+
+	"github.com/example/infra/db/merkle_patricia_trie/trie"
+)
+
+// Mutations run on copies of the nodes along the mutated paths and the copied root is swapped in
+// only after every hash was updated. If hashing fails halfway, the current root and all of its
+// nodes are left untouched, so a failed mutation cannot leave stale hashes behind.
+// Nodes off the paths are shared between the old and the new root and are never modified.
+
+// copyPathsInBranch copies node and every node below it on the paths of keys.
+func copyPathsInBranch(keys []string, node trie.NodeBranch) trie.NodeBranch {
+	c := node.Copy()
+	groups := make(map[byte][]string)
+	for _, key := range keys {
+		groups[key[0]] = append(groups[key[0]], key)
+	}
+	for ch, group := range groups {
+		if !c.HasChildAt(ch) {
+			continue
+		}
+		child := copyPathsInExtension(group, c.ChildAt(ch))
+		if err := c.Delete(ch); err != nil {
+			panic(err)
+		}
+		if err := c.Append(child); err != nil {
+			panic(err)
+		}
+	}
+	return c
+}
+
+// copyPathsInExtension copies node and every node below it on the paths of keys.
+// All keys must start with the first character of the key of node.
+func copyPathsInExtension(keys []string, node trie.NodeExtension) trie.NodeExtension {
+	c := node.Copy()
+	if !c.HasNext() {
+		return c
+	}
+	var tails []string
+	for _, key := range keys {
+		if len(key) > len(c.Key()) && strings.HasPrefix(key, c.Key()) {
+			tails = append(tails, key[len(c.Key()):])
+		}
+	}
+	if len(tails) == 0 {
+		return c
+	}
+	switch next := c.Next().(type) {
+	case trie.NodeExtension:
+		var nextTails []string
+		for _, tail := range tails {
+			if tail[0] == next.Key()[0] {
+				nextTails = append(nextTails, tail)
+			}
+		}
+		if len(nextTails) > 0 {
+			c.SetNext(copyPathsInExtension(nextTails, next))
+		}
+	case trie.NodeBranch:
+		c.SetNext(copyPathsInBranch(tails, next))
+	default:
+		panic("Unknown node type")
+	}
+	return c
+}
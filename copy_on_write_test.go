@@ -0,0 +1,181 @@
+package merkle_patricia_trie
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"testing"
+
+	"github.com/example/service/crypto"
+)
+
+// failingHash fails the failAt-th call and every call after it, counted from 1. Zero never fails.
+// It also fails every input containing poison, if set, and with oneIn > 0 about one in oneIn inputs
+// chosen by seed. The choice depends on the input only, so the same mutations fail at the same nodes on every run.
+type failingHash struct {
+	hs     crypto.Hash
+	calls  int
+	failAt int
+	poison []byte
+	seed   uint64
+	oneIn  uint64
+}
+
+func (h *failingHash) Hash(data []byte) ([]byte, error) {
+	h.calls++
+	if h.failAt > 0 && h.calls >= h.failAt {
+		return nil, fmt.Errorf("injected hash failure at call #%d", h.calls)
+	}
+	if h.poison != nil && bytes.Contains(data, h.poison) {
+		return nil, fmt.Errorf("injected hash failure on input with <%s>", h.poison)
+	}
+	if h.oneIn > 0 {
+		f := fnv.New64a()
+		binary.Write(f, binary.BigEndian, h.seed)
+		f.Write(data)
+		if f.Sum64()%h.oneIn == 0 {
+			return nil, fmt.Errorf("injected hash failure for seed %d", h.seed)
+		}
+	}
+	return h.hs.Hash(data)
+}
+
+func TestMerklePatriciaTrie_HashFailure(t *testing.T) {
+	fh := &failingHash{hs: hashService(t)}
+
+	mutations := map[string]func(mt *MerklePatriciaTrie) error{
+		"Insert": func(mt *MerklePatriciaTrie) error {
+			return mt.Insert([]byte("key12ab"), []byte("value"))
+		},
+		"Put": func(mt *MerklePatriciaTrie) error {
+			_, _, err := mt.Put([]byte("key123"), []byte("value2"))
+			return err
+		},
+		"Delete": func(mt *MerklePatriciaTrie) error {
+			return mt.Delete([]byte("key"))
+		},
+		"InsertBatch": func(mt *MerklePatriciaTrie) error {
+			return mt.InsertBatch([]KV{{[]byte("key12ab"), []byte("value")}, {[]byte("kez"), []byte("value")}})
+		},
+	}
+	for name, mutate := range mutations {
+		for failAt := 1; ; failAt++ {
+			fh.failAt = 0
+			mt := NewMerklePatriciaTrie(fh)
+			for _, key := range []string{"key", "key123", "keyxyz", "dog"} {
+				if err := mt.Insert([]byte(key), []byte("value")); err != nil {
+					t.Fatal(err)
+				}
+			}
+			rootHash := mt.RootHash()
+			keys := mt.Keys()
+
+			fh.calls, fh.failAt = 0, failAt
+			err := mutate(mt)
+			fh.failAt = 0
+			if err == nil {
+				// failAt is beyond the number of hash calls of the mutation
+				break
+			}
+			if !bytes.Equal(rootHash, mt.RootHash()) {
+				t.Errorf("%s: root hash changed after hash failure at call #%d", name, failAt)
+			}
+			if got := mt.Keys(); len(got) != len(keys) {
+				t.Errorf("%s: keys changed after hash failure at call #%d. got = %q", name, failAt, got)
+			}
+			if err := mt.VerifySample(20, 1); err != nil {
+				t.Errorf("%s: trie is inconsistent after hash failure at call #%d. err: %v", name, failAt, err)
+			}
+		}
+	}
+}
+
+func TestMerklePatriciaTrie_HashFailureOnInput(t *testing.T) {
+	hs := hashService(t)
+
+	{
+		t.Log("Nodes holding a poisoned value cannot be hashed")
+		fh := &failingHash{hs: hs}
+		mt := NewMerklePatriciaTrie(fh)
+		if err := mt.Insert([]byte("key"), []byte("value")); err != nil {
+			t.Fatal(err)
+		}
+		rootHash := mt.RootHash()
+		fh.poison = []byte("poison")
+		if err := mt.Insert([]byte("key123"), []byte("poison")); err == nil {
+			t.Fatal("Insert of a poisoned value must fail")
+		}
+		if !bytes.Equal(rootHash, mt.RootHash()) {
+			t.Error("Root hash changed after hash failure")
+		}
+		if err := mt.Insert([]byte("key123"), []byte("value")); err != nil {
+			t.Errorf("Other values must still be inserted. err: %v", err)
+		}
+	}
+
+	{
+		t.Log("Seeded failures hit the same mutations on every run")
+		run := func(seed uint64) string {
+			fh := &failingHash{hs: hs}
+			mt := NewMerklePatriciaTrie(fh)
+			fh.seed, fh.oneIn = seed, 4
+			var failed []string
+			for _, key := range []string{"key", "key123", "keyxyz", "dog", "doge", "cat", "key12ab"} {
+				before := mt.RootHash()
+				if err := mt.Insert([]byte(key), []byte("value")); err != nil {
+					failed = append(failed, key)
+					if !bytes.Equal(before, mt.RootHash()) {
+						t.Errorf("Root hash changed after hash failure on %s", key)
+					}
+				}
+			}
+			fh.oneIn = 0
+			if err := mt.VerifySample(20, 1); err != nil {
+				t.Errorf("Trie is inconsistent after seeded failures. err: %v", err)
+			}
+			return fmt.Sprint(failed)
+		}
+		for seed := uint64(0); seed < 4; seed++ {
+			if a, b := run(seed), run(seed); a != b {
+				t.Errorf("Failures of seed %d differ between runs. got = %s and %s", seed, a, b)
+			}
+		}
+	}
+}
+
+func TestMerklePatriciaTrie_CopyOnWrite(t *testing.T) {
+	hs := hashService(t)
+
+	mt := NewMerklePatriciaTrie(hs)
+	for _, key := range []string{"key", "key123", "keyxyz", "dog"} {
+		if err := mt.Insert([]byte(key), []byte("value")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	old := &MerklePatriciaTrie{hs: hs, root: mt.root}
+	oldRootHash := old.RootHash()
+
+	if err := mt.Insert([]byte("key12ab"), []byte("value")); err != nil {
+		t.Fatal(err)
+	}
+	if err := mt.Delete([]byte("key")); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := mt.Put([]byte("dog"), []byte("value2")); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(oldRootHash, old.RootHash()) {
+		t.Error("Old root must not be modified")
+	}
+	if err := old.VerifySample(20, 1); err != nil {
+		t.Errorf("Old root must stay consistent. err: %v", err)
+	}
+	if ok, _ := old.Has([]byte("key")); !ok {
+		t.Error("Old root must keep the deleted key")
+	}
+	if value, _ := old.Get([]byte("dog")); string(value) != "value" {
+		t.Errorf("Old root must keep the old value. got = %s", value)
+	}
+}
@@ -0,0 +1,67 @@
+package merkle_patricia_trie
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+type failingReader struct {
+	pairs int
+}
+
+func (r failingReader) WalkPrefix(prefix []byte, fn func(key, value []byte) bool) error {
+	for i := 0; i < r.pairs; i++ {
+		if !fn([]byte(fmt.Sprintf("key%05d", i)), []byte("value")) {
+			return nil
+		}
+	}
+	return fmt.Errorf("connection lost")
+}
+
+func TestMerklePatriciaTrie_CopyFrom(t *testing.T) {
+	hs := hashService(t)
+
+	src := NewMerklePatriciaTrie(hs)
+	src.Use(prefixTransform{[]byte("ab:")})
+	want := NewMerklePatriciaTrie(hs)
+	for i := 0; i < 3000; i++ {
+		key, value := []byte(fmt.Sprintf("key%05d", i)), []byte(fmt.Sprintf("value%d", i))
+		if err := src.Insert(key, value); err != nil {
+			t.Fatal(err)
+		}
+		if err := want.Insert(key, value); err != nil {
+			t.Fatal(err)
+		}
+		if err := src.Insert(append([]byte("other"), key...), value); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	{
+		t.Log("Pairs under the prefix are copied through the transforms of the destination")
+		dst := NewMerklePatriciaTrie(hs)
+		n, err := dst.CopyFrom(src, []byte("key"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != 3000 || !bytes.Equal(dst.RootHash(), want.RootHash()) {
+			t.Errorf("Copy does not match the source. copied: %d", n)
+		}
+		if _, err := dst.CopyFrom(src, []byte("key00001")); err == nil {
+			t.Error("Copying existing keys must fail")
+		}
+	}
+
+	{
+		t.Log("Read errors of the source are returned")
+		dst := NewMerklePatriciaTrie(hs)
+		n, err := dst.CopyFrom(failingReader{1500}, nil)
+		if err == nil {
+			t.Fatal("Read error must be returned")
+		}
+		if n != copyBatchSize || len(dst.Keys()) != copyBatchSize {
+			t.Errorf("Full batches before the error must stay. copied: %d, stored: %d", n, len(dst.Keys()))
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package merkle_patricia_trie
+
+import (
+	"fmt"
+
+	"github.com/example/service/crypto"
+)
+
+// Cost is the work done by the operations run under Measure or WithBudget, for layers which charge for it.
+type Cost struct {
+	// NodesRead counts the nodes visited by lookups, writes, proofs, walks and iterators.
+	NodesRead int
+	// NodesWritten counts the nodes encoded and hashed, which are the nodes a write creates or rewrites.
+	// Every other call to the hash service, such as hashing a key of a SecureTrie, counts as one too.
+	NodesWritten int
+	// BytesHashed is the total input to the hash service.
+	BytesHashed int
+}
+
+// Budget limits the cost of the operations run under WithBudget. A zero limit is no limit.
+type Budget struct {
+	MaxNodesRead   int
+	MaxBytesHashed int
+}
+
+func (b Budget) exceededBy(c Cost) bool {
+	return (b.MaxNodesRead > 0 && c.NodesRead > b.MaxNodesRead) || (b.MaxBytesHashed > 0 && c.BytesHashed > b.MaxBytesHashed)
+}
+
+// BudgetError is returned by WithBudget when the operations exceed the budget.
+type BudgetError struct {
+	Budget Budget
+	// Cost is the cost up to and including the node or hash which exceeded the budget.
+	Cost Cost
+	// meter is the measure the budget belongs to, so nested measures pass the error on to it
+	meter *meter
+}
+
+func (e *BudgetError) Error() string {
+	return fmt.Sprintf("budget exceeded: read %d nodes of max %d, hashed %d bytes of max %d",
+		e.Cost.NodesRead, e.Budget.MaxNodesRead, e.Cost.BytesHashed, e.Budget.MaxBytesHashed)
+}
+
+// meter is one measure in progress. Charges go to it and to every measure it is nested in.
+type meter struct {
+	cost   Cost
+	budget Budget
+	outer  *meter
+}
+
+// charge adds to the cost of every measure in progress.
+// An exceeded budget aborts the operation by a panic, which the measure owning the budget recovers.
+// Writes replace the root only after they complete, so an aborted write leaves the trie untouched.
+func (mt *MerklePatriciaTrie) charge(c Cost) {
+	for m := mt.meter; m != nil; m = m.outer {
+		m.cost.NodesRead += c.NodesRead
+		m.cost.NodesWritten += c.NodesWritten
+		m.cost.BytesHashed += c.BytesHashed
+		if m.budget.exceededBy(m.cost) {
+			panic(&BudgetError{Budget: m.budget, Cost: m.cost, meter: m})
+		}
+	}
+}
+
+// touch charges a visit of a node.
+func (mt *MerklePatriciaTrie) touch() {
+	if mt.meter != nil {
+		mt.charge(Cost{NodesRead: 1})
+	}
+}
+
+// hashFunction names the embedded hash service of meteredHash, whose Hash method it overrides.
+type hashFunction = crypto.Hash
+
+// meteredHash charges every hash before computing it.
+type meteredHash struct {
+	hashFunction
+	mt *MerklePatriciaTrie
+}
+
+func (h meteredHash) Hash(b []byte) ([]byte, error) {
+	if h.mt.meter != nil {
+		h.mt.charge(Cost{NodesWritten: 1, BytesHashed: len(b)})
+	}
+	return h.hashFunction.Hash(b)
+}
+
+// Measure runs fn and returns the cost of the operations it runs on the trie, also if fn fails.
+// Measures may nest; the cost of the inner one also counts for the outer one.
+// The trie must not be used from other goroutines meanwhile.
+func (mt *MerklePatriciaTrie) Measure(fn func() error) (Cost, error) {
+	return mt.WithBudget(Budget{}, fn)
+}
+
+// WithBudget is Measure aborting the operation which exceeds b with a *BudgetError.
+// Iterators aborted this way must not be used further.
+func (mt *MerklePatriciaTrie) WithBudget(b Budget, fn func() error) (cost Cost, err error) {
+	m := &meter{budget: b, outer: mt.meter}
+	if mt.meter == nil {
+		hs := mt.hs
+		mt.hs = meteredHash{hs, mt}
+		defer func() { mt.hs = hs }()
+	}
+	mt.meter = m
+	defer func() {
+		mt.meter = m.outer
+		if r := recover(); r != nil {
+			e, ok := r.(*BudgetError)
+			if !ok || e.meter != m {
+				panic(r)
+			}
+			cost, err = m.cost, e
+		}
+	}()
+	err = fn()
+	return m.cost, err
+}
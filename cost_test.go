@@ -0,0 +1,243 @@
+package merkle_patricia_trie
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestMerklePatriciaTrie_Measure(t *testing.T) {
+	hs := hashService(t)
+
+	trie := NewMerklePatriciaTrie(hs)
+	for i := 0; i < 100; i++ {
+		if err := trie.Insert([]byte(fmt.Sprintf("key%d", i)), []byte("value")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	{
+		t.Log("Reads touch nodes without hashing")
+		cost, err := trie.Measure(func() error {
+			_, err := trie.Get([]byte("key42"))
+			return err
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cost.NodesRead == 0 || cost.NodesWritten != 0 || cost.BytesHashed != 0 {
+			t.Errorf("Unexpected cost of Get(): %+v", cost)
+		}
+	}
+
+	{
+		t.Log("Proofs and deletes charge each node on the path once")
+		get, _ := trie.Measure(func() error {
+			_, err := trie.Get([]byte("key42"))
+			return err
+		})
+		for _, op := range []struct {
+			name string
+			fn   func() error
+		}{
+			{"FindMerklePath", func() error {
+				_, err := trie.FindMerklePath([]byte("key42"))
+				return err
+			}},
+			{"ProveKeys", func() error {
+				_, err := trie.ProveKeys([][]byte{[]byte("key42")})
+				return err
+			}},
+			{"ProveSubtree", func() error {
+				_, err := trie.ProveSubtree([]byte("key42"))
+				return err
+			}},
+			{"Remove", func() error {
+				_, err := trie.Remove([]byte("key42"))
+				return err
+			}},
+		} {
+			cost, err := trie.Measure(op.fn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cost.NodesRead != get.NodesRead {
+				t.Errorf("Unexpected nodes read by %s().\n  got = %d\n  want = %d", op.name, cost.NodesRead, get.NodesRead)
+			}
+		}
+		if err := trie.Insert([]byte("key42"), []byte("value")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	{
+		t.Log("Writes hash the nodes on the path up to the root")
+		cost, err := trie.Measure(func() error {
+			_, _, err := trie.Put([]byte("key42"), []byte("other"))
+			return err
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cost.NodesWritten < 2 || cost.BytesHashed == 0 || cost.NodesRead < cost.NodesWritten-1 {
+			t.Errorf("Unexpected cost of Put(): %+v", cost)
+		}
+	}
+
+	{
+		t.Log("Scans are charged per node")
+		point, _ := trie.Measure(func() error { return trie.Walk(func(key, value []byte) bool { return false }) })
+		full, _ := trie.Measure(func() error { return trie.Walk(func(key, value []byte) bool { return true }) })
+		iterated, _ := trie.Measure(func() error {
+			for it := trie.NewIterator(); it.Next(); {
+			}
+			return nil
+		})
+		if full.NodesRead <= point.NodesRead || full.NodesRead < 100 || iterated.NodesRead != full.NodesRead {
+			t.Errorf("Unexpected cost of scans: stopped %+v, full %+v, iterated %+v", point, full, iterated)
+		}
+	}
+
+	{
+		t.Log("Nested costs count for the outer measure")
+		var inner Cost
+		outer, err := trie.Measure(func() error {
+			if err := trie.Insert([]byte("new1"), []byte("value")); err != nil {
+				return err
+			}
+			var err error
+			inner, err = trie.Measure(func() error { return trie.Insert([]byte("new2"), []byte("value")) })
+			return err
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if inner.NodesWritten == 0 || outer.NodesWritten <= inner.NodesWritten || outer.BytesHashed <= inner.BytesHashed {
+			t.Errorf("Unexpected nested costs: outer %+v, inner %+v", outer, inner)
+		}
+	}
+
+	{
+		t.Log("Nothing is charged outside of a measure")
+		if err := trie.Insert([]byte("new3"), []byte("value")); err != nil {
+			t.Fatal(err)
+		}
+		if trie.meter != nil {
+			t.Error("Measure() must restore the trie")
+		}
+		if _, ok := trie.hs.(meteredHash); ok {
+			t.Error("Measure() must restore the hash service")
+		}
+	}
+}
+
+func TestMerklePatriciaTrie_WithBudget(t *testing.T) {
+	hs := hashService(t)
+
+	trie := NewMerklePatriciaTrie(hs)
+	for i := 0; i < 100; i++ {
+		if err := trie.Insert([]byte(fmt.Sprintf("key%d", i)), []byte("value")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	root := trie.RootHash()
+
+	{
+		t.Log("Operations within the budget succeed")
+		cost, err := trie.WithBudget(Budget{MaxNodesRead: 100, MaxBytesHashed: 10000}, func() error {
+			_, err := trie.Get([]byte("key42"))
+			return err
+		})
+		if err != nil || cost.NodesRead == 0 {
+			t.Errorf("Unexpected result of a cheap Get(). cost: %+v, err: %v", cost, err)
+		}
+	}
+
+	{
+		t.Log("Scans abort when reading too many nodes")
+		budget := Budget{MaxNodesRead: 20}
+		visited := 0
+		cost, err := trie.WithBudget(budget, func() error {
+			for it := trie.NewIterator(); it.Next(); {
+				visited++
+			}
+			return nil
+		})
+		var be *BudgetError
+		if !errors.As(err, &be) || be.Budget != budget || be.Cost != cost {
+			t.Fatalf("Expected a BudgetError, got %v", err)
+		}
+		if cost.NodesRead != budget.MaxNodesRead+1 || visited >= 100 {
+			t.Errorf("Scan must stop at the budget. cost: %+v, visited: %d", cost, visited)
+		}
+	}
+
+	{
+		t.Log("Proofs abort when reading too many nodes")
+		budget := Budget{MaxNodesRead: 5}
+		keys := make([][]byte, 50)
+		for i := range keys {
+			keys[i] = []byte(fmt.Sprintf("key%d", i))
+		}
+		for _, op := range []struct {
+			name string
+			fn   func() error
+		}{
+			{"ProveKeys", func() error {
+				_, err := trie.ProveKeys(keys)
+				return err
+			}},
+			{"ProveSubtree", func() error {
+				_, err := trie.ProveSubtree([]byte("key42"))
+				return err
+			}},
+			{"ProveRange", func() error {
+				_, err := trie.ProveRange([]byte("key"), nil)
+				return err
+			}},
+		} {
+			cost, err := trie.WithBudget(budget, op.fn)
+			var be *BudgetError
+			if !errors.As(err, &be) || cost.NodesRead != budget.MaxNodesRead+1 {
+				t.Errorf("%s() must stop at the budget. cost: %+v, err: %v", op.name, cost, err)
+			}
+		}
+	}
+
+	{
+		t.Log("Aborted writes leave the trie untouched")
+		_, err := trie.WithBudget(Budget{MaxBytesHashed: 1}, func() error {
+			return trie.Insert([]byte("key1000"), []byte("value"))
+		})
+		var be *BudgetError
+		if !errors.As(err, &be) {
+			t.Fatalf("Expected a BudgetError, got %v", err)
+		}
+		if !bytes.Equal(root, trie.RootHash()) {
+			t.Error("Aborted Insert() must not change the root")
+		}
+		if ok, err := trie.Has([]byte("key1000")); err != nil || ok {
+			t.Errorf("Aborted Insert() must not store the key. ok: %v, err: %v", ok, err)
+		}
+		if err := trie.InsertBatch([]KV{{[]byte("key1000"), []byte("value")}}); err != nil {
+			t.Errorf("Trie must stay usable after an aborted write: %s", err)
+		}
+	}
+
+	{
+		t.Log("Budgets of outer measures apply to nested ones")
+		var innerErr error
+		_, err := trie.WithBudget(Budget{MaxNodesRead: 5}, func() error {
+			_, innerErr = trie.Measure(func() error { return trie.Walk(func(key, value []byte) bool { return true }) })
+			return nil
+		})
+		var be *BudgetError
+		if !errors.As(err, &be) || innerErr != nil {
+			t.Errorf("Outer budget must abort the outer measure. err: %v, inner: %v", err, innerErr)
+		}
+		if trie.meter != nil {
+			t.Error("WithBudget() must restore the trie")
+		}
+	}
+}
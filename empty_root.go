@@ -0,0 +1,37 @@
+package merkle_patricia_trie
+
+import (
+	"bytes"
+
+	"github.com/example/infra/db/merkle_patricia_trie/mptproof"
+	"github.com/example/infra/db/merkle_patricia_trie/trie"
+	"github.com/example/service/crypto"
+)
+
+// EmptyRootSha256 is the root hash of a trie without keys under SHA-256, as formatted by FormatRoot.
+// The root of an empty trie is the hash of a branch without children, so it depends only on the hash service.
+const EmptyRootSha256 = "0xa43b055080cacd427d884276e25034a9b261eef7597a38a95a7a7d03c412ea87"
+
+// EmptyRoot returns the root hash of a trie without keys under hs, which a fresh trie reports.
+func EmptyRoot(hs crypto.Hash) (trie.HashBlob, error) {
+	return mptproof.EmptyRoot(hs)
+}
+
+// IsEmptyRoot reports whether root commits to a trie without keys under hs.
+func IsEmptyRoot(hs crypto.Hash, root trie.HashBlob) (bool, error) {
+	h, err := EmptyRoot(hs)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(root, h), nil
+}
+
+// IsEmpty reports whether the trie holds no keys.
+func (mt *MerklePatriciaTrie) IsEmpty() bool {
+	for _, child := range mt.root.ListChildren() {
+		if child != nil {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,35 @@
+package merkle_patricia_trie
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestMerklePatriciaTrie_EmptyRoot(t *testing.T) {
+	hs := hashService(t)
+
+	want, err := EmptyRoot(hs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := FormatRoot(want); got != EmptyRootSha256 {
+		t.Errorf("Unexpected empty root\n  got = %s\n  want = %s", got, EmptyRootSha256)
+	}
+
+	trie := NewMerklePatriciaTrie(hs)
+	if !trie.IsEmpty() || !bytes.Equal(trie.RootHash(), want) {
+		t.Error("Fresh trie must report the empty root")
+	}
+	if err := trie.Insert([]byte("key"), []byte("value")); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := IsEmptyRoot(hs, trie.RootHash()); err != nil || ok || trie.IsEmpty() {
+		t.Errorf("Trie with a key must not be empty. err: %v", err)
+	}
+	if err := trie.Delete([]byte("key")); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := IsEmptyRoot(hs, trie.RootHash()); err != nil || !ok || !trie.IsEmpty() {
+		t.Errorf("Trie without keys left must be empty again. err: %v", err)
+	}
+}
@@ -0,0 +1,149 @@
+package merkle_patricia_trie
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"fmt"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+const dataKeySize = 32
+
+var ErrErased = errors.New("value has been erased")
+
+// KeyStore keeps the per-key data keys of an ErasableTrie apart from the trie itself.
+type KeyStore interface {
+	Get(key []byte) ([]byte, error)
+	Put(key []byte, dataKey []byte) error
+	Delete(key []byte) error
+}
+
+type memoryKeyStore struct {
+	dataKeys map[string][]byte
+}
+
+func NewMemoryKeyStore() KeyStore {
+	return &memoryKeyStore{make(map[string][]byte)}
+}
+
+func (ks *memoryKeyStore) Get(key []byte) ([]byte, error) {
+	dataKey, ok := ks.dataKeys[string(key)]
+	if !ok {
+		return nil, ErrErased
+	}
+	return dataKey, nil
+}
+
+func (ks *memoryKeyStore) Put(key []byte, dataKey []byte) error {
+	ks.dataKeys[string(key)] = dataKey
+	return nil
+}
+
+func (ks *memoryKeyStore) Delete(key []byte) error {
+	delete(ks.dataKeys, string(key))
+	return nil
+}
+
+// ErasableTrie stores every value encrypted under its own data key.
+// Erase() destroys the data key only, so the committed ciphertext and
+// the root hash stay as they are while the value becomes unrecoverable.
+type ErasableTrie struct {
+	mt *MerklePatriciaTrie
+	ks KeyStore
+}
+
+func NewErasableTrie(mt *MerklePatriciaTrie, ks KeyStore) *ErasableTrie {
+	return &ErasableTrie{mt, ks}
+}
+
+func sealValue(dataKey, key, value []byte) ([]byte, error) {
+	block, err := aes.NewCipher(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	// The key is bound as additional data so ciphertexts cannot be swapped between keys
+	return aead.Seal(nonce, nonce, value, key), nil
+}
+
+func openValue(dataKey, key, sealed []byte) ([]byte, error) {
+	block, err := aes.NewCipher(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, fmt.Errorf("sealed value is too short")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, key)
+}
+
+func (et *ErasableTrie) Insert(key []byte, value []byte) error {
+	dataKey := make([]byte, dataKeySize)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return errors.Wrap(err, "failed to generate data key")
+	}
+	sealed, err := sealValue(dataKey, key, value)
+	if err != nil {
+		return errors.Wrap(err, "failed to encrypt value")
+	}
+	if err := et.mt.Insert(key, sealed); err != nil {
+		return err
+	}
+	if err := et.ks.Put(key, dataKey); err != nil {
+		if delErr := et.mt.Delete(key); delErr != nil {
+			return errors.Wrapf(err, "failed to store data key (rollback failed: %s)", delErr)
+		}
+		return errors.Wrap(err, "failed to store data key")
+	}
+	return nil
+}
+
+func (et *ErasableTrie) Get(key []byte) ([]byte, error) {
+	sealed, err := et.mt.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	dataKey, err := et.ks.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	value, err := openValue(dataKey, key, sealed)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decrypt value")
+	}
+	return value, nil
+}
+
+// Erase destroys the data key of key. The encrypted value stays committed in the trie.
+func (et *ErasableTrie) Erase(key []byte) error {
+	if _, err := et.mt.Get(key); err != nil {
+		return err
+	}
+	return et.ks.Delete(key)
+}
+
+func (et *ErasableTrie) Delete(key []byte) error {
+	if err := et.mt.Delete(key); err != nil {
+		return err
+	}
+	return et.ks.Delete(key)
+}
+
+func (et *ErasableTrie) FindMerklePath(key []byte) (MerklePath, error) {
+	return et.mt.FindMerklePath(key)
+}
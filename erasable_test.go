@@ -0,0 +1,56 @@
+package merkle_patricia_trie
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestErasableTrie_Erase(t *testing.T) {
+	hs := hashService(t)
+
+	mt := NewMerklePatriciaTrie(hs)
+	et := NewErasableTrie(mt, NewMemoryKeyStore())
+	if err := et.Insert([]byte("dog"), []byte("puppy")); err != nil {
+		t.Fatal(err)
+	}
+	if err := et.Insert([]byte("cat"), []byte("kitten")); err != nil {
+		t.Fatal(err)
+	}
+
+	value, err := et.Get([]byte("dog"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(value) != "puppy" {
+		t.Errorf("Unexpected value.\n  got = %s\n  want = puppy", value)
+	}
+	sealed, err := mt.Get([]byte("dog"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(sealed, []byte("puppy")) {
+		t.Error("Value must be committed encrypted")
+	}
+
+	rootHash := append([]byte(nil), mt.root.Hash()...)
+	if err := et.Erase([]byte("dog")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := et.Get([]byte("dog")); errors.Cause(err) != ErrErased {
+		t.Errorf("Erased value must not be readable. err: %v", err)
+	}
+	if !bytes.Equal(rootHash, mt.root.Hash()) {
+		t.Error("Erase() must not change the root hash")
+	}
+	if _, err := et.FindMerklePath([]byte("dog")); err != nil {
+		t.Errorf("Erased key must stay provable. err: %v", err)
+	}
+	if value, err := et.Get([]byte("cat")); err != nil || string(value) != "kitten" {
+		t.Errorf("Other values must stay readable. value: %s, err: %v", value, err)
+	}
+	if err := et.Erase([]byte("cow")); err == nil {
+		t.Error("Cannot erase non-existent key")
+	}
+}
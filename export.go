@@ -0,0 +1,96 @@
+package merkle_patricia_trie
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+
+	"github.com/example/infra/db/merkle_patricia_trie/mptproof"
+	"github.com/example/infra/db/merkle_patricia_trie/trie"
+	"github.com/example/service/crypto"
+	"github.com/pkg/errors"
+)
+
+// The format of verifiable exports is defined in mptproof, which verifies them without the trie.
+
+const (
+	exportManifest = mptproof.ExportManifestFile
+	exportData     = mptproof.ExportDataFile
+	exportProof    = mptproof.ExportProofFile
+)
+
+func writeTarFile(tw *tar.Writer, name string, data []byte) error {
+	if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(data))}); err != nil {
+		return err
+	}
+	_, err := tw.Write(data)
+	return err
+}
+
+// ExportVerifiable writes the pairs under prefix with their range proof and a manifest to w.
+// An empty prefix exports the whole trie.
+func (mt *MerklePatriciaTrie) ExportVerifiable(prefix []byte, w io.Writer) error {
+	start, err := mt.forwardBound(append([]byte{}, prefix...))
+	if err != nil {
+		return err
+	}
+	end := mptproof.PrefixEnd(start)
+	proof, err := mt.rawRangeProof(start, end)
+	if err != nil {
+		return err
+	}
+	root := mt.RootHash()
+	pairs, err := VerifyRange(mt.hs, root, start, end, proof)
+	if err != nil {
+		return errors.Wrap(err, "range proof of the export does not verify")
+	}
+
+	var data bytes.Buffer
+	enc := json.NewEncoder(&data)
+	for _, kv := range pairs {
+		if err := enc.Encode(mptproof.ExportPair{Key: hex.EncodeToString(kv.Key), Value: hex.EncodeToString(kv.Value)}); err != nil {
+			return err
+		}
+	}
+	proofData, err := proof.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	manifest, err := json.Marshal(mptproof.ExportManifest{
+		Version: mptproof.ExportVersion,
+		Root:    FormatRoot(root),
+		Prefix:  hex.EncodeToString(start),
+		Count:   len(pairs),
+	})
+	if err != nil {
+		return err
+	}
+
+	tw := tar.NewWriter(w)
+	for _, f := range []struct {
+		name string
+		data []byte
+	}{{exportManifest, manifest}, {exportData, data.Bytes()}, {exportProof, proofData}} {
+		if err := writeTarFile(tw, f.name, f.data); err != nil {
+			return errors.Wrapf(err, "failed to write %s", f.name)
+		}
+	}
+	return tw.Close()
+}
+
+// VerifyBundle reads an export of ExportVerifiable and checks that it is bound to rootHash and prefix
+// and that its data are exactly the pairs under prefix proven by its range proof. It returns the pairs.
+// prefix is the stored prefix, i.e. after the key transformer of the exporting trie.
+func VerifyBundle(hs crypto.Hash, r io.Reader, rootHash trie.HashBlob, prefix []byte) ([]KV, error) {
+	proofPairs, err := mptproof.VerifyBundle(hs, r, rootHash, prefix)
+	if err != nil {
+		return nil, err
+	}
+	pairs := make([]KV, len(proofPairs))
+	for i, kv := range proofPairs {
+		pairs[i] = KV(kv)
+	}
+	return pairs, nil
+}
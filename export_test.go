@@ -0,0 +1,112 @@
+package merkle_patricia_trie
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestMerklePatriciaTrie_ExportVerifiable(t *testing.T) {
+	hs := hashService(t)
+
+	mt := NewMerklePatriciaTrie(hs)
+	for _, key := range []string{"user/alice", "user/bob", "user/", "users", "team/x", "v"} {
+		if err := mt.Insert([]byte(key), []byte("v"+key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var bf bytes.Buffer
+	if err := mt.ExportVerifiable([]byte("user/"), &bf); err != nil {
+		t.Fatal(err)
+	}
+	export := append([]byte(nil), bf.Bytes()...)
+	pairs, err := VerifyBundle(hs, bytes.NewReader(export), mt.RootHash(), []byte("user/"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pairs) != 3 || string(pairs[0].Key) != "user/" || string(pairs[2].Key) != "user/bob" || string(pairs[1].Value) != "vuser/alice" {
+		t.Errorf("Unexpected pairs. got = %q", pairs)
+	}
+
+	{
+		t.Log("Whole trie and empty trie")
+		bf.Reset()
+		if err := mt.ExportVerifiable(nil, &bf); err != nil {
+			t.Fatal(err)
+		}
+		if pairs, err := VerifyBundle(hs, &bf, mt.RootHash(), nil); err != nil || len(pairs) != 6 {
+			t.Errorf("Whole trie must be exported. got = %d pairs, err: %v", len(pairs), err)
+		}
+		empty := NewMerklePatriciaTrie(hs)
+		bf.Reset()
+		if err := empty.ExportVerifiable([]byte("user/"), &bf); err != nil {
+			t.Fatal(err)
+		}
+		if pairs, err := VerifyBundle(hs, &bf, empty.RootHash(), []byte("user/")); err != nil || len(pairs) != 0 {
+			t.Errorf("Empty trie must export no pairs. got = %d pairs, err: %v", len(pairs), err)
+		}
+	}
+
+	{
+		t.Log("Tampered exports are rejected")
+		if _, err := VerifyBundle(hs, bytes.NewReader(export), NewMerklePatriciaTrie(hs).RootHash(), []byte("user/")); err == nil {
+			t.Error("Export of another root must be rejected")
+		}
+		bf.Reset()
+		if err := mt.ExportVerifiable([]byte("user/a"), &bf); err != nil {
+			t.Fatal(err)
+		}
+		narrower := append([]byte(nil), bf.Bytes()...)
+		if _, err := VerifyBundle(hs, bytes.NewReader(narrower), mt.RootHash(), []byte("user/")); err == nil {
+			t.Error("Export of a narrower prefix must be rejected")
+		}
+		if pairs, err := VerifyBundle(hs, bytes.NewReader(narrower), mt.RootHash(), []byte("user/a")); err != nil || len(pairs) != 1 {
+			t.Errorf("Export of the expected prefix must verify. got = %d pairs, err: %v", len(pairs), err)
+		}
+		files := readExport(t, export)
+		tampered := map[string]map[string][]byte{
+			"dropped pair":  {exportData: bytes.SplitAfterN(files[exportData], []byte("\n"), 2)[1]},
+			"changed value": {exportData: bytes.Replace(files[exportData], []byte("616c696365"), []byte("616c696366"), 1)},
+			"no proof":      {exportProof: nil},
+			"other prefix":  {exportManifest: bytes.Replace(files[exportManifest], []byte(`"prefix":"`), []byte(`"prefix":"7573`), 1)},
+		}
+		for name, changes := range tampered {
+			var out bytes.Buffer
+			tw := tar.NewWriter(&out)
+			for _, f := range []string{exportManifest, exportData, exportProof} {
+				data, changed := changes[f]
+				if !changed {
+					data = files[f]
+				} else if data == nil {
+					continue
+				}
+				if err := writeTarFile(tw, f, data); err != nil {
+					t.Fatal(err)
+				}
+			}
+			tw.Close()
+			if _, err := VerifyBundle(hs, &out, mt.RootHash(), []byte("user/")); err == nil {
+				t.Errorf("Export with %s must be rejected", name)
+			}
+		}
+	}
+}
+
+func readExport(t *testing.T, export []byte) map[string][]byte {
+	files := make(map[string][]byte)
+	tr := tar.NewReader(bytes.NewReader(export))
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return files
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if files[h.Name], err = io.ReadAll(tr); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package merkle_patricia_trie
+
+import (
+	"bytes"
+	"hash/fnv"
+	"testing"
+
+	"github.com/example/service/crypto"
+)
+
+// fakeHash is a deterministic 8-byte FNV-1a hash service without registry or dependencies.
+// Tests of higher-level logic use it to run fast and print short hashes on failure. It is not collision resistant.
+type fakeHash struct{}
+
+func (fakeHash) Hash(data []byte) ([]byte, error) {
+	h := fnv.New64a()
+	h.Write(data)
+	return h.Sum(nil), nil
+}
+
+func fakeHashService() crypto.Hash {
+	return fakeHash{}
+}
+
+func TestFakeHash(t *testing.T) {
+	hs := fakeHashService()
+
+	a, _ := hs.Hash([]byte("key"))
+	b, _ := hs.Hash([]byte("key"))
+	c, _ := hs.Hash([]byte("kez"))
+	if len(a) != 8 || !bytes.Equal(a, b) || bytes.Equal(a, c) {
+		t.Errorf("Fake hash must be a deterministic 8-byte digest. got = %x, %x, %x", a, b, c)
+	}
+
+	mt := NewMerklePatriciaTrie(hs)
+	if err := mt.ValidateConfig(); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"key", "key123", "dog"} {
+		if err := mt.Insert([]byte(key), []byte("v"+key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(mt.RootHashHex()) != 16 {
+		t.Errorf("Root must be short. got = %s", mt.RootHashHex())
+	}
+	path, err := mt.FindMerklePath([]byte("key123"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyMerklePath(hs, mt.RootHash(), []byte("key123"), []byte("vkey123"), path); err != nil {
+		t.Errorf("Proofs must verify with the fake hash: %s", err)
+	}
+	if err := mt.VerifySample(10, 1); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,67 @@
+package merkle_patricia_trie
+
+import (
+	"encoding/hex"
+
+	"github.com/pkg/errors"
+)
+
+// The plain methods take raw key bytes and hex-encode them into the trie path themselves.
+// The *HexKey variants take keys which are already hex-encoded, so callers holding hex strings
+// do not end up with double-encoded keys.
+
+func decodeHexKey(hexKey string) ([]byte, error) {
+	key, err := hex.DecodeString(hexKey)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid hex key = <%s>", hexKey)
+	}
+	return key, nil
+}
+
+func (mt *MerklePatriciaTrie) InsertHexKey(hexKey string, value []byte) error {
+	key, err := decodeHexKey(hexKey)
+	if err != nil {
+		return err
+	}
+	return mt.Insert(key, value)
+}
+
+func (mt *MerklePatriciaTrie) PutHexKey(hexKey string, value []byte) (prev []byte, existed bool, err error) {
+	key, err := decodeHexKey(hexKey)
+	if err != nil {
+		return nil, false, err
+	}
+	return mt.Put(key, value)
+}
+
+func (mt *MerklePatriciaTrie) GetHexKey(hexKey string) ([]byte, error) {
+	key, err := decodeHexKey(hexKey)
+	if err != nil {
+		return nil, err
+	}
+	return mt.Get(key)
+}
+
+func (mt *MerklePatriciaTrie) HasHexKey(hexKey string) (bool, error) {
+	key, err := decodeHexKey(hexKey)
+	if err != nil {
+		return false, err
+	}
+	return mt.Has(key)
+}
+
+func (mt *MerklePatriciaTrie) DeleteHexKey(hexKey string) error {
+	key, err := decodeHexKey(hexKey)
+	if err != nil {
+		return err
+	}
+	return mt.Delete(key)
+}
+
+func (mt *MerklePatriciaTrie) FindMerklePathHexKey(hexKey string) (MerklePath, error) {
+	key, err := decodeHexKey(hexKey)
+	if err != nil {
+		return nil, err
+	}
+	return mt.FindMerklePath(key)
+}
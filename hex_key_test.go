@@ -0,0 +1,41 @@
+package merkle_patricia_trie
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestMerklePatriciaTrie_HexKey(t *testing.T) {
+	hs := hashService(t)
+
+	raw := NewMerklePatriciaTrie(hs)
+	if err := raw.Insert([]byte("key"), []byte("value")); err != nil {
+		t.Fatal(err)
+	}
+	hexed := NewMerklePatriciaTrie(hs)
+	if err := hexed.InsertHexKey("6b6579", []byte("value")); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(raw.RootHash(), hexed.RootHash()) {
+		t.Error("Hex key must address the same path as its raw bytes")
+	}
+	if ok, err := hexed.Has([]byte("key")); err != nil || !ok {
+		t.Errorf("Hex key must be readable by its raw bytes. ok: %v, err: %v", ok, err)
+	}
+	if ok, err := hexed.HasHexKey("6B6579"); err != nil || !ok {
+		t.Errorf("Upper case hex key must be accepted. ok: %v, err: %v", ok, err)
+	}
+
+	for _, hexKey := range []string{"6b657", "6b65zz", "0x6b6579"} {
+		if err := hexed.InsertHexKey(hexKey, []byte("value")); err == nil {
+			t.Errorf("Invalid hex key <%s> must be rejected", hexKey)
+		}
+	}
+
+	if _, err := hexed.FindMerklePathHexKey("6b6579"); err != nil {
+		t.Error(err)
+	}
+	if err := hexed.DeleteHexKey("6b6579"); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,66 @@
+package merkle_patricia_trie
+
+import (
+	"fmt"
+
+	"github.com/example/infra/db/merkle_patricia_trie/mptproof"
+)
+
+// Hex-prefix encoding is how Ethereum packs the key fragments of its leaf and extension nodes.
+// The high nibble of the first byte holds the flags: bit 1 for a leaf and bit 0 for an odd number of nibbles.
+// An odd fragment keeps its first nibble in the low nibble of the first byte, an even one pads it with zero.
+// This trie encodes its own nodes with their key fragments as hex strings; the codec is for exchanging
+// key fragments with Ethereum implementations.
+
+const (
+	hexPrefixOdd  = 1
+	hexPrefixLeaf = 2
+)
+
+// HexPrefix encodes nibbles with the leaf flag.
+func HexPrefix(nibbles Nibbles, leaf bool) ([]byte, error) {
+	n := string(nibbles)
+	values := make([]byte, len(n))
+	for i := range n {
+		index, err := mptproof.NibbleIndex(n[i])
+		if err != nil {
+			return nil, err
+		}
+		values[i] = byte(index)
+	}
+
+	var flags byte
+	if leaf {
+		flags |= hexPrefixLeaf
+	}
+	if len(values)%2 == 1 {
+		flags |= hexPrefixOdd
+		values = append([]byte{flags}, values...)
+	} else {
+		values = append([]byte{flags, 0}, values...)
+	}
+	encoded := make([]byte, len(values)/2)
+	for i := range encoded {
+		encoded[i] = values[2*i]<<4 | values[2*i+1]
+	}
+	return encoded, nil
+}
+
+// ParseHexPrefix decodes the encoding of HexPrefix and returns the nibbles and the leaf flag.
+func ParseHexPrefix(encoded []byte) (Nibbles, bool, error) {
+	if len(encoded) == 0 {
+		return "", false, fmt.Errorf("hex prefix is empty")
+	}
+	flags := encoded[0] >> 4
+	if flags&^(hexPrefixOdd|hexPrefixLeaf) != 0 {
+		return "", false, fmt.Errorf("invalid hex prefix flags %x", flags)
+	}
+	hexed := fmt.Sprintf("%x", encoded)
+	if flags&hexPrefixOdd != 0 {
+		return Nibbles(hexed[1:]), flags&hexPrefixLeaf != 0, nil
+	}
+	if hexed[1] != '0' {
+		return "", false, fmt.Errorf("padding nibble of even hex prefix must be zero")
+	}
+	return Nibbles(hexed[2:]), flags&hexPrefixLeaf != 0, nil
+}
@@ -0,0 +1,47 @@
+package merkle_patricia_trie
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestHexPrefix(t *testing.T) {
+	// Examples of the Patricia tree specification of the Ethereum wiki
+	cases := []struct {
+		nibbles Nibbles
+		leaf    bool
+		want    []byte
+	}{
+		{"12345", false, []byte{0x11, 0x23, 0x45}},
+		{"012345", false, []byte{0x00, 0x01, 0x23, 0x45}},
+		{"0f1cb8", true, []byte{0x20, 0x0f, 0x1c, 0xb8}},
+		{"f1cb8", true, []byte{0x3f, 0x1c, 0xb8}},
+		{"", false, []byte{0x00}},
+		{"", true, []byte{0x20}},
+	}
+	for _, c := range cases {
+		got, err := HexPrefix(c.nibbles, c.leaf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, c.want) {
+			t.Errorf("Unexpected encoding of %s.\n  got = %x\n  want = %x", c.nibbles, got, c.want)
+		}
+		nibbles, leaf, err := ParseHexPrefix(got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if nibbles != c.nibbles || leaf != c.leaf {
+			t.Errorf("Encoding of %s does not round trip. got = %s, %t", c.nibbles, nibbles, leaf)
+		}
+	}
+
+	if _, err := HexPrefix("12g", false); err == nil {
+		t.Error("Invalid nibble must be rejected")
+	}
+	for _, bad := range [][]byte{nil, {0x40}, {0x01, 0x23}} {
+		if _, _, err := ParseHexPrefix(bad); err == nil {
+			t.Errorf("Invalid hex prefix %x must be rejected", bad)
+		}
+	}
+}
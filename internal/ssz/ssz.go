@@ -0,0 +1,170 @@
+// Package ssz implements the parts of SimpleSerialize the proof encodings need:
+// containers and lists of variable-size elements, with byte lists, booleans and little-endian integers.
+// Variable-size parts are placed behind 4-byte little-endian offsets, counted from the start of the enclosing object.
+package ssz
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+const offsetSize = 4
+
+// Field is one field of a container. Fixed fields are inlined, variable ones placed behind an offset.
+type Field struct {
+	Bytes    []byte
+	Variable bool
+}
+
+func Fixed(b []byte) Field {
+	return Field{Bytes: b}
+}
+
+func Variable(b []byte) Field {
+	return Field{Bytes: b, Variable: true}
+}
+
+func Uint16(v uint16) []byte {
+	return binary.LittleEndian.AppendUint16(nil, v)
+}
+
+func Bool(v bool) []byte {
+	if v {
+		return []byte{1}
+	}
+	return []byte{0}
+}
+
+// Container serializes fields in order.
+func Container(fields ...Field) []byte {
+	fixedLen := 0
+	for _, f := range fields {
+		if f.Variable {
+			fixedLen += offsetSize
+		} else {
+			fixedLen += len(f.Bytes)
+		}
+	}
+	out := make([]byte, 0, fixedLen)
+	offset := fixedLen
+	for _, f := range fields {
+		if f.Variable {
+			out = binary.LittleEndian.AppendUint32(out, uint32(offset))
+			offset += len(f.Bytes)
+		} else {
+			out = append(out, f.Bytes...)
+		}
+	}
+	for _, f := range fields {
+		if f.Variable {
+			out = append(out, f.Bytes...)
+		}
+	}
+	return out
+}
+
+// List serializes a list of variable-size elements.
+func List(elems [][]byte) []byte {
+	fields := make([]Field, len(elems))
+	for i, e := range elems {
+		fields[i] = Variable(e)
+	}
+	return Container(fields...)
+}
+
+// parts reads the offsets at the given positions of b and returns the parts of b they delimit.
+// The first offset must be first, and the offsets must not decrease or pass the end of b.
+func parts(b []byte, offsets []int, first int) ([][]byte, error) {
+	out := make([][]byte, len(offsets))
+	for i, at := range offsets {
+		start := int(binary.LittleEndian.Uint32(b[at:]))
+		end := len(b)
+		if i+1 < len(offsets) {
+			end = int(binary.LittleEndian.Uint32(b[offsets[i+1]:]))
+		}
+		if (i == 0 && start != first) || start > end || end > len(b) {
+			return nil, fmt.Errorf("invalid offset %d", start)
+		}
+		out[i] = b[start:end]
+	}
+	return out, nil
+}
+
+// SplitContainer splits a container into its fields. sizes holds the size of every fixed field and -1 for variable ones.
+func SplitContainer(b []byte, sizes ...int) ([][]byte, error) {
+	fixedLen := 0
+	for _, size := range sizes {
+		if size < 0 {
+			size = offsetSize
+		}
+		fixedLen += size
+	}
+	if len(b) < fixedLen {
+		return nil, fmt.Errorf("container of %d bytes is shorter than its %d fixed bytes", len(b), fixedLen)
+	}
+	fields := make([][]byte, len(sizes))
+	var offsets []int
+	var variable []int
+	at := 0
+	for i, size := range sizes {
+		if size < 0 {
+			offsets = append(offsets, at)
+			variable = append(variable, i)
+			at += offsetSize
+			continue
+		}
+		fields[i] = b[at : at+size]
+		at += size
+	}
+	if len(offsets) == 0 {
+		if len(b) != fixedLen {
+			return nil, fmt.Errorf("container has %d trailing bytes", len(b)-fixedLen)
+		}
+		return fields, nil
+	}
+	vs, err := parts(b, offsets, fixedLen)
+	if err != nil {
+		return nil, err
+	}
+	for k, i := range variable {
+		fields[i] = vs[k]
+	}
+	return fields, nil
+}
+
+// SplitList splits a list of variable-size elements into the elements, at most max.
+func SplitList(b []byte, max int) ([][]byte, error) {
+	if len(b) == 0 {
+		return nil, nil
+	}
+	if len(b) < offsetSize {
+		return nil, fmt.Errorf("list is too short")
+	}
+	first := int(binary.LittleEndian.Uint32(b))
+	if first%offsetSize != 0 || first == 0 || first > len(b) {
+		return nil, fmt.Errorf("invalid offset %d", first)
+	}
+	count := first / offsetSize
+	if count > max {
+		return nil, fmt.Errorf("list of %d elements exceeds its limit %d", count, max)
+	}
+	offsets := make([]int, count)
+	for i := range offsets {
+		offsets[i] = i * offsetSize
+	}
+	return parts(b, offsets, first)
+}
+
+func ReadUint16(b []byte) uint16 {
+	return binary.LittleEndian.Uint16(b)
+}
+
+func ReadBool(b []byte) (bool, error) {
+	switch b[0] {
+	case 0:
+		return false, nil
+	case 1:
+		return true, nil
+	}
+	return false, fmt.Errorf("invalid boolean %d", b[0])
+}
@@ -0,0 +1,51 @@
+package ssz
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestContainer(t *testing.T) {
+	b := Container(Fixed(Uint16(0x0102)), Variable([]byte("ab")), Fixed(Bool(true)), Variable(List([][]byte{{1}, {}})))
+	want := []byte{
+		0x02, 0x01, // uint16
+		0x0b, 0x00, 0x00, 0x00, // offset of "ab"
+		0x01,                   // boolean
+		0x0d, 0x00, 0x00, 0x00, // offset of the list
+		'a', 'b',
+		0x08, 0x00, 0x00, 0x00, 0x09, 0x00, 0x00, 0x00, 0x01,
+	}
+	if !bytes.Equal(b, want) {
+		t.Fatalf("Unexpected serialization\n  got = %x\n  want = %x", b, want)
+	}
+
+	fields, err := SplitContainer(b, 2, -1, 1, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ReadUint16(fields[0]) != 0x0102 || string(fields[1]) != "ab" {
+		t.Errorf("Unexpected fields %x", fields)
+	}
+	elems, err := SplitList(fields[3], 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(elems) != 2 || !bytes.Equal(elems[0], []byte{1}) || len(elems[1]) != 0 {
+		t.Errorf("Unexpected elements %x", elems)
+	}
+
+	if _, err := SplitList(fields[3], 1); err == nil {
+		t.Error("List over its limit must be rejected")
+	}
+	if _, err := SplitContainer(b[:6], 2, -1, 1, -1); err == nil {
+		t.Error("Truncated container must be rejected")
+	}
+	bad := append([]byte(nil), b...)
+	bad[2] = 0x0c
+	if _, err := SplitContainer(bad, 2, -1, 1, -1); err == nil {
+		t.Error("Container with a misplaced first offset must be rejected")
+	}
+	if _, err := ReadBool([]byte{2}); err == nil {
+		t.Error("Boolean other than 0 and 1 must be rejected")
+	}
+}
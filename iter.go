@@ -0,0 +1,43 @@
+package merkle_patricia_trie
+
+import (
+	"iter"
+)
+
+// The sequences below stop silently on a value transform error.
+// Use the Iterator types directly when Err() needs to be checked.
+// Every range over a sequence starts a new iterator, so a sequence can be ranged again
+// and sees the trie as it is when the range starts.
+
+func seq(newIterator func() *Iterator) iter.Seq2[[]byte, []byte] {
+	return func(yield func([]byte, []byte) bool) {
+		it := newIterator()
+		for it.Next() {
+			if !yield(it.Key(), it.Value()) {
+				return
+			}
+		}
+	}
+}
+
+// All returns every key/value pair in lexicographic key order.
+func (mt *MerklePatriciaTrie) All() iter.Seq2[[]byte, []byte] {
+	return seq(mt.NewIterator)
+}
+
+// Prefix returns the key/value pairs whose key starts with prefix.
+func (mt *MerklePatriciaTrie) Prefix(prefix []byte) iter.Seq2[[]byte, []byte] {
+	prefix = append([]byte{}, prefix...)
+	return seq(func() *Iterator { return mt.NewPrefixIterator(prefix) })
+}
+
+// Range returns the key/value pairs whose key is in [start, end). A nil end means no upper bound.
+func (mt *MerklePatriciaTrie) Range(start, end []byte) iter.Seq2[[]byte, []byte] {
+	if start != nil {
+		start = append([]byte{}, start...)
+	}
+	if end != nil {
+		end = append([]byte{}, end...)
+	}
+	return seq(func() *Iterator { return mt.NewRangeIterator(start, end) })
+}
@@ -0,0 +1,94 @@
+package merkle_patricia_trie
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMerklePatriciaTrie_All(t *testing.T) {
+	hs := hashService(t)
+
+	trie := NewMerklePatriciaTrie(hs)
+	for _, key := range []string{"dog", "cat", "doge", "k\xff", "k\xff\xff", "l"} {
+		if err := trie.Insert([]byte(key), []byte("value-"+key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var got []string
+	for k, v := range trie.All() {
+		if string(v) != "value-"+string(k) {
+			t.Errorf("Unexpected value of <%q>. got = %q", k, v)
+		}
+		got = append(got, string(k))
+	}
+	if want := "cat,dog,doge,k\xff,k\xff\xff,l"; strings.Join(got, ",") != want {
+		t.Errorf("Unexpected keys of All().\n  got = %q\n  want = %q", got, want)
+	}
+
+	got = nil
+	for k := range trie.Prefix([]byte("dog")) {
+		got = append(got, string(k))
+	}
+	if want := "dog,doge"; strings.Join(got, ",") != want {
+		t.Errorf("Unexpected keys of Prefix().\n  got = %q\n  want = %q", got, want)
+	}
+
+	got = nil
+	for k := range trie.Prefix([]byte("k\xff")) {
+		got = append(got, string(k))
+	}
+	if want := "k\xff,k\xff\xff"; strings.Join(got, ",") != want {
+		t.Errorf("Unexpected keys of Prefix() ending with 0xff.\n  got = %q\n  want = %q", got, want)
+	}
+
+	got = nil
+	for k := range trie.Range([]byte("d"), []byte("k")) {
+		got = append(got, string(k))
+		break
+	}
+	if want := "dog"; strings.Join(got, ",") != want {
+		t.Errorf("Unexpected keys of Range() with break.\n  got = %q\n  want = %q", got, want)
+	}
+}
+
+func TestMerklePatriciaTrie_AllTwice(t *testing.T) {
+	hs := hashService(t)
+
+	trie := NewMerklePatriciaTrie(hs)
+	for _, key := range []string{"dog", "cat"} {
+		if err := trie.Insert([]byte(key), []byte("value-"+key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	keys := func(seq func(func([]byte, []byte) bool)) string {
+		var got []string
+		for k := range seq {
+			got = append(got, string(k))
+		}
+		return strings.Join(got, ",")
+	}
+
+	all, prefix, rng := trie.All(), trie.Prefix([]byte("d")), trie.Range([]byte("a"), nil)
+	for i := 0; i < 2; i++ {
+		if got := keys(all); got != "cat,dog" {
+			t.Errorf("Unexpected keys of All() in range #%d. got = %q", i, got)
+		}
+		if got := keys(prefix); got != "dog" {
+			t.Errorf("Unexpected keys of Prefix() in range #%d. got = %q", i, got)
+		}
+		if got := keys(rng); got != "cat,dog" {
+			t.Errorf("Unexpected keys of Range() in range #%d. got = %q", i, got)
+		}
+	}
+
+	{
+		t.Log("A sequence sees the trie as it is when ranged")
+		if err := trie.Insert([]byte("doge"), []byte("value-doge")); err != nil {
+			t.Fatal(err)
+		}
+		if got := keys(all); got != "cat,dog,doge" {
+			t.Errorf("Unexpected keys of All() after an insert. got = %q", got)
+		}
+	}
+}
@@ -0,0 +1,283 @@
+package merkle_patricia_trie
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"github.com/example/infra/db/merkle_patricia_trie/mptproof"
+	"github.com/example/infra/db/merkle_patricia_trie/trie"
+)
+
+type iteratorItem struct {
+	// path is the hex path from the root down to, but not including, node
+	path string
+	node trie.Node
+	// value is set instead of node for a value deferred by reverse iteration; path is then its full key
+	value trie.ValueObject
+}
+
+// Iterator traverses the stored key/value pairs in lexicographic key order, or in descending order if reverse.
+// The trie must not be modified while iterating.
+type Iterator struct {
+	mt    *MerklePatriciaTrie
+	stack []iteratorItem
+	key   []byte
+	value []byte
+	err   error
+	// start and end bound the hex encoded keys to [start, end); empty end means unbounded
+	start   string
+	end     string
+	reverse bool
+	// rangeStart and rangeEnd keep the bounds given on construction while Seek() narrows start and end
+	rangeStart string
+	rangeEnd   string
+	lastKey    []byte
+	// boundErr is the error of transforming the bounds on construction, which Seek() does not clear
+	boundErr error
+}
+
+func (mt *MerklePatriciaTrie) NewIterator() *Iterator {
+	return &Iterator{mt: mt, stack: []iteratorItem{{"", mt.root, nil}}}
+}
+
+// NewReverseIterator iterates all keys from the highest to the lowest.
+func (mt *MerklePatriciaTrie) NewReverseIterator() *Iterator {
+	it := mt.NewIterator()
+	it.reverse = true
+	return it
+}
+
+// NewRangeIterator iterates the keys in [start, end) in order. A nil end means no upper bound.
+// Subtrees outside the range are not visited.
+func (mt *MerklePatriciaTrie) NewRangeIterator(start, end []byte) *Iterator {
+	it := mt.NewIterator()
+	start, end, err := mt.forwardRange(start, end)
+	if err != nil {
+		it.boundErr, it.err = err, err
+		return it
+	}
+	return it.withBounds(start, end)
+}
+
+// withBounds sets the bounds as stored keys.
+func (it *Iterator) withBounds(start, end []byte) *Iterator {
+	it.start = hex.EncodeToString(start)
+	it.end = hex.EncodeToString(end)
+	it.rangeStart, it.rangeEnd = it.start, it.end
+	return it
+}
+
+// NewReverseRangeIterator iterates the keys in [start, end) from the highest to the lowest.
+func (mt *MerklePatriciaTrie) NewReverseRangeIterator(start, end []byte) *Iterator {
+	it := mt.NewRangeIterator(start, end)
+	it.reverse = true
+	return it
+}
+
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
+
+// beforeStart reports whether every key under prefix is less than start.
+func (it *Iterator) beforeStart(prefix string) bool {
+	n := min(len(prefix), len(it.start))
+	return prefix[:n] < it.start[:n]
+}
+
+// atOrAfterEnd reports whether every key under prefix is equal to or greater than end.
+func (it *Iterator) atOrAfterEnd(prefix string) bool {
+	if it.end == "" {
+		return false
+	}
+	p := truncate(prefix, len(it.end))
+	e := truncate(it.end, len(prefix))
+	return p > e || (p == e && len(prefix) >= len(it.end))
+}
+
+// Next advances to the next pair and reports whether there is one.
+func (it *Iterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+	for len(it.stack) > 0 {
+		item := it.stack[len(it.stack)-1]
+		it.stack = it.stack[:len(it.stack)-1]
+
+		if item.value != nil {
+			if item.path < it.start {
+				// Items below are less still
+				it.stack = nil
+				break
+			}
+			if it.load(item.path, item.value) {
+				return true
+			}
+			if it.err != nil {
+				return false
+			}
+			continue
+		}
+
+		it.mt.touch()
+		prefix := item.path
+		if ext, ok := item.node.(trie.NodeExtension); ok {
+			prefix += ext.Key()
+		}
+		if it.reverse {
+			if it.beforeStart(prefix) {
+				it.stack = nil
+				break
+			}
+			if it.atOrAfterEnd(prefix) {
+				continue
+			}
+		} else {
+			if it.atOrAfterEnd(prefix) {
+				it.stack = nil
+				break
+			}
+			if it.beforeStart(prefix) {
+				continue
+			}
+		}
+
+		switch node := item.node.(type) {
+		case trie.NodeExtension:
+			path := item.path + node.Key()
+			if it.reverse {
+				// The value of the node comes after every longer key below it
+				if node.HasValueObject() {
+					it.stack = append(it.stack, iteratorItem{path, nil, node.ValueObject()})
+				}
+				if node.HasNext() {
+					it.stack = append(it.stack, iteratorItem{path, node.Next(), nil})
+				}
+				continue
+			}
+			if node.HasNext() {
+				it.stack = append(it.stack, iteratorItem{path, node.Next(), nil})
+			}
+			if node.HasValueObject() && path >= it.start {
+				if it.load(path, node.ValueObject()) {
+					return true
+				}
+				if it.err != nil {
+					return false
+				}
+			}
+		case trie.NodeBranch:
+			children := node.ListChildren()
+			// Push in the opposite order of the visit
+			for i := range children {
+				index := len(children) - 1 - i
+				if it.reverse {
+					index = i
+				}
+				if children[index] != nil {
+					it.stack = append(it.stack, iteratorItem{item.path, children[index], nil})
+				}
+			}
+		default:
+			panic("Unknown node type")
+		}
+	}
+	it.key, it.value = nil, nil
+	return false
+}
+
+// load makes the value at path the current pair. It returns false on an error,
+// and also without an error for a key which the key transformer did not produce.
+func (it *Iterator) load(path string, vo trie.ValueObject) bool {
+	stored := decodePath(path)
+	key, err := it.mt.inverseKey(stored)
+	if err == ErrForeignKey {
+		return false
+	}
+	var value []byte
+	if err == nil {
+		value, err = it.mt.inverseValue(stored, append([]byte(nil), vo.Value()...))
+	}
+	if err != nil {
+		it.err = err
+		it.key, it.value = nil, nil
+		return false
+	}
+	it.key, it.value = key, value
+	it.lastKey = stored
+	return true
+}
+
+// Key returns the key of the current pair.
+func (it *Iterator) Key() []byte {
+	return it.key
+}
+
+// Value returns a copy of the value of the current pair.
+func (it *Iterator) Value() []byte {
+	return it.value
+}
+
+// Err returns the error which stopped the iteration, if any.
+func (it *Iterator) Err() error {
+	return it.err
+}
+
+// NewPrefixIterator iterates the keys starting with prefix in order.
+func (mt *MerklePatriciaTrie) NewPrefixIterator(prefix []byte) *Iterator {
+	it := mt.NewIterator()
+	prefix, err := mt.forwardBound(append([]byte{}, prefix...))
+	if err != nil {
+		it.boundErr, it.err = err, err
+		return it
+	}
+	return it.withBounds(prefix, mptproof.PrefixEnd(prefix))
+}
+
+// NewReversePrefixIterator iterates the keys starting with prefix from the highest to the lowest.
+func (mt *MerklePatriciaTrie) NewReversePrefixIterator(prefix []byte) *Iterator {
+	it := mt.NewPrefixIterator(prefix)
+	it.reverse = true
+	return it
+}
+
+const cursorVersion = 1
+
+// Cursor returns an opaque token of the position after the last returned pair, or nil before the first Next().
+// Seek() on an iterator with the same bounds and direction resumes from it, even in another process.
+func (it *Iterator) Cursor() []byte {
+	if it.lastKey == nil {
+		return nil
+	}
+	return append([]byte{cursorVersion}, it.lastKey...)
+}
+
+// Seek repositions the iterator right after the pair the cursor was taken at.
+// A nil cursor rewinds to the beginning.
+func (it *Iterator) Seek(cursor []byte) error {
+	if it.boundErr != nil {
+		return it.boundErr
+	}
+	it.start, it.end = it.rangeStart, it.rangeEnd
+	it.stack = []iteratorItem{{"", it.mt.root, nil}}
+	it.key, it.value, it.lastKey, it.err = nil, nil, nil, nil
+	if len(cursor) == 0 {
+		return nil
+	}
+	if cursor[0] != cursorVersion || len(cursor) == 1 {
+		return fmt.Errorf("invalid iterator cursor")
+	}
+	last := hex.EncodeToString(cursor[1:])
+	if it.reverse {
+		if it.end == "" || last < it.end {
+			it.end = last
+		}
+	} else if next := last + "00"; next > it.start {
+		// last + 0x00 is the smallest key greater than last
+		it.start = next
+	}
+	it.lastKey = append([]byte(nil), cursor[1:]...)
+	return nil
+}
@@ -0,0 +1,186 @@
+package merkle_patricia_trie
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestIterator(t *testing.T) {
+	hs := hashService(t)
+
+	want := []string{"dog", "cat", "doge", "k", "kk", "kkk", "k`", "kj", "key12ab", "key123"}
+	trie := NewMerklePatriciaTrie(hs)
+	for _, key := range want {
+		if err := trie.Insert([]byte(key), []byte("value-"+key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sort.Strings(want)
+
+	it := trie.NewIterator()
+	i := 0
+	for ; it.Next(); i++ {
+		if i >= len(want) {
+			t.Fatalf("Too many pairs. got = %q", it.Key())
+		}
+		if !bytes.Equal(it.Key(), []byte(want[i])) {
+			t.Errorf("Unexpected key at #%d.\n  got = %q\n  want = %q", i, it.Key(), want[i])
+		}
+		if !bytes.Equal(it.Value(), []byte("value-"+want[i])) {
+			t.Errorf("Unexpected value at #%d.\n  got = %q\n  want = %q", i, it.Value(), "value-"+want[i])
+		}
+	}
+	if err := it.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if i != len(want) {
+		t.Errorf("Unexpected number of pairs. got = %d, want = %d", i, len(want))
+	}
+	if it.Next() {
+		t.Error("Exhausted iterator must stay exhausted")
+	}
+
+	if NewMerklePatriciaTrie(hs).NewIterator().Next() {
+		t.Error("Empty trie must have no pairs")
+	}
+}
+
+func TestMerklePatriciaTrie_NewRangeIterator(t *testing.T) {
+	hs := hashService(t)
+
+	keys := []string{"dog", "cat", "doge", "k", "kk", "kkk", "k`", "kj", "key12ab", "key123"}
+	trie := NewMerklePatriciaTrie(hs)
+	for _, key := range keys {
+		if err := trie.Insert([]byte(key), []byte("value")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sort.Strings(keys)
+
+	bounds := [][2][]byte{
+		{nil, nil},
+		{[]byte("d"), []byte("k")},
+		{[]byte("dog"), []byte("doge")},
+		{[]byte("doge"), nil},
+		{[]byte("k"), []byte("kk")},
+		{[]byte("ka"), []byte("kkz")},
+		{[]byte("z"), nil},
+		{[]byte("dog"), []byte("dog")},
+	}
+	for _, b := range bounds {
+		var want []string
+		for _, key := range keys {
+			if key >= string(b[0]) && (b[1] == nil || key < string(b[1])) {
+				want = append(want, key)
+			}
+		}
+		var got []string
+		it := trie.NewRangeIterator(b[0], b[1])
+		for it.Next() {
+			got = append(got, string(it.Key()))
+		}
+		if err := it.Err(); err != nil {
+			t.Fatal(err)
+		}
+		if strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Errorf("Unexpected keys in [%q, %q).\n  got = %q\n  want = %q", b[0], b[1], got, want)
+		}
+	}
+}
+
+func TestMerklePatriciaTrie_NewReverseIterator(t *testing.T) {
+	hs := hashService(t)
+
+	keys := []string{"dog", "cat", "doge", "k", "kk", "kkk", "k`", "kj", "key12ab", "key123"}
+	trie := NewMerklePatriciaTrie(hs)
+	for _, key := range keys {
+		if err := trie.Insert([]byte(key), []byte("value-"+key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(keys)))
+
+	for _, b := range [][2][]byte{
+		{nil, nil},
+		{[]byte("d"), []byte("k")},
+		{[]byte("dog"), []byte("doge")},
+		{[]byte("doge"), nil},
+		{[]byte("k"), []byte("kk")},
+		{[]byte("ka"), []byte("kkz")},
+		{nil, []byte("a")},
+	} {
+		var want []string
+		for _, key := range keys {
+			if key >= string(b[0]) && (b[1] == nil || key < string(b[1])) {
+				want = append(want, key)
+			}
+		}
+		var got []string
+		it := trie.NewReverseRangeIterator(b[0], b[1])
+		for it.Next() {
+			if string(it.Value()) != "value-"+string(it.Key()) {
+				t.Errorf("Unexpected value of <%s>. got = %s", it.Key(), it.Value())
+			}
+			got = append(got, string(it.Key()))
+		}
+		if err := it.Err(); err != nil {
+			t.Fatal(err)
+		}
+		if strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Errorf("Unexpected keys in [%q, %q) in reverse.\n  got = %q\n  want = %q", b[0], b[1], got, want)
+		}
+	}
+
+	it := trie.NewReversePrefixIterator([]byte("k"))
+	if !it.Next() || string(it.Key()) != "kkk" {
+		t.Errorf("Latest key under prefix must come first. got = %q", it.Key())
+	}
+}
+
+func TestIterator_Seek(t *testing.T) {
+	hs := hashService(t)
+
+	keys := []string{"dog", "cat", "doge", "k", "kk", "kkk", "k`", "kj", "key12ab", "key123"}
+	trie := NewMerklePatriciaTrie(hs)
+	for _, key := range keys {
+		if err := trie.Insert([]byte(key), []byte("value")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, newIterator := range []func() *Iterator{
+		trie.NewIterator,
+		trie.NewReverseIterator,
+		func() *Iterator { return trie.NewRangeIterator([]byte("d"), []byte("kk")) },
+		func() *Iterator { return trie.NewReverseRangeIterator([]byte("d"), []byte("kk")) },
+	} {
+		var want []string
+		it := newIterator()
+		for it.Next() {
+			want = append(want, string(it.Key()))
+		}
+
+		// Read pages of 3 pairs, each with a fresh iterator resumed from the previous cursor
+		var got []string
+		var cursor []byte
+		for page := 0; page <= len(want)/3; page++ {
+			it := newIterator()
+			if err := it.Seek(cursor); err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < 3 && it.Next(); i++ {
+				got = append(got, string(it.Key()))
+			}
+			cursor = it.Cursor()
+		}
+		if strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Errorf("Paginated keys differ.\n  got = %q\n  want = %q", got, want)
+		}
+	}
+
+	if err := trie.NewIterator().Seek([]byte{0xff, 'k'}); err == nil {
+		t.Error("Cursor of unknown version must be rejected")
+	}
+}
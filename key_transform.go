@@ -0,0 +1,143 @@
+package merkle_patricia_trie
+
+import (
+	"bytes"
+
+	"github.com/example/infra/db/merkle_patricia_trie/mptproof"
+	"github.com/pkg/errors"
+)
+
+// KeyTransformer maps the keys taken by the public methods to the keys stored in the trie.
+// Forward runs on every key, prefix and range bound passed in, and Inverse on every key handed out
+// by iterators and walks, so wrappers can prefix or normalize keys in one place.
+// Proofs and value transforms work on the stored keys.
+type KeyTransformer interface {
+	Forward(key []byte) ([]byte, error)
+	// Inverse returns ErrForeignKey for a stored key the transformer did not produce,
+	// which iterators and walks skip.
+	Inverse(key []byte) ([]byte, error)
+}
+
+var ErrForeignKey = errors.New("key is not produced by the key transformer")
+
+// UseKeys sets the key transformer. Like value transforms, it must be set before the first write.
+func (mt *MerklePatriciaTrie) UseKeys(kt KeyTransformer) {
+	mt.keys = kt
+}
+
+// forwardKey transforms key. An empty key is returned as is, so it is still rejected as one.
+func (mt *MerklePatriciaTrie) forwardKey(key []byte) ([]byte, error) {
+	if mt.keys == nil || len(key) == 0 {
+		return key, nil
+	}
+	k, err := mt.keys.Forward(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "key transformer failed")
+	}
+	return k, nil
+}
+
+// forwardBound transforms a range bound. A nil bound is unbounded and stays so;
+// an empty start is transformed, so it becomes the lowest key the transformer produces.
+func (mt *MerklePatriciaTrie) forwardBound(bound []byte) ([]byte, error) {
+	if mt.keys == nil || bound == nil {
+		return bound, nil
+	}
+	k, err := mt.keys.Forward(bound)
+	if err != nil {
+		return nil, errors.Wrap(err, "key transformer failed")
+	}
+	return k, nil
+}
+
+// KeyRange is implemented by key transformers whose stored keys all lie in [start, end), a nil end
+// leaving it unbounded. Range bounds are clamped to it, so a nil end does not reach the keys of others.
+type KeyRange interface {
+	KeyRange() (start, end []byte)
+}
+
+// forwardRange transforms the bounds of a range and clamps them to the KeyRange of the key transformer.
+func (mt *MerklePatriciaTrie) forwardRange(start, end []byte) ([]byte, []byte, error) {
+	start, err := mt.forwardBound(start)
+	if err != nil {
+		return nil, nil, err
+	}
+	if end, err = mt.forwardBound(end); err != nil {
+		return nil, nil, err
+	}
+	kr, ok := mt.keys.(KeyRange)
+	if !ok {
+		return start, end, nil
+	}
+	krStart, krEnd := kr.KeyRange()
+	if bytes.Compare(start, krStart) < 0 {
+		start = krStart
+	}
+	if krEnd != nil && (end == nil || bytes.Compare(end, krEnd) > 0) {
+		end = krEnd
+	}
+	return start, end, nil
+}
+
+func (mt *MerklePatriciaTrie) inverseKey(key []byte) ([]byte, error) {
+	if mt.keys == nil {
+		return key, nil
+	}
+	k, err := mt.keys.Inverse(key)
+	if err == ErrForeignKey {
+		return nil, err
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "inverse of key transformer failed")
+	}
+	return k, nil
+}
+
+type prefixKeys struct {
+	prefix []byte
+}
+
+// NewPrefixKeyTransformer stores every key under prefix, so tenants sharing a trie keep apart.
+// Ranges are clamped to the keys under prefix, so range proofs and exports hold no keys of other tenants.
+func NewPrefixKeyTransformer(prefix []byte) KeyTransformer {
+	return prefixKeys{append([]byte(nil), prefix...)}
+}
+
+func (t prefixKeys) Forward(key []byte) ([]byte, error) {
+	return append(append([]byte(nil), t.prefix...), key...), nil
+}
+
+// KeyRange is [prefix, end of prefix), so ranges stay within the keys of the tenant.
+func (t prefixKeys) KeyRange() ([]byte, []byte) {
+	return t.prefix, mptproof.PrefixEnd(t.prefix)
+}
+
+func (t prefixKeys) Inverse(key []byte) ([]byte, error) {
+	if len(key) <= len(t.prefix) || !bytes.HasPrefix(key, t.prefix) {
+		return nil, ErrForeignKey
+	}
+	return append([]byte(nil), key[len(t.prefix):]...), nil
+}
+
+type caseFoldKeys struct{}
+
+// NewCaseFoldKeyTransformer stores keys with ASCII letters in lower case, so keys differing only in case are the same.
+// The original case is not recoverable, so keys are handed out in lower case.
+func NewCaseFoldKeyTransformer() KeyTransformer {
+	return caseFoldKeys{}
+}
+
+func (caseFoldKeys) Forward(key []byte) ([]byte, error) {
+	folded := make([]byte, len(key))
+	for i, c := range key {
+		if 'A' <= c && c <= 'Z' {
+			c += 'a' - 'A'
+		}
+		folded[i] = c
+	}
+	return folded, nil
+}
+
+func (caseFoldKeys) Inverse(key []byte) ([]byte, error) {
+	return key, nil
+}
@@ -0,0 +1,183 @@
+package merkle_patricia_trie
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestMerklePatriciaTrie_UseKeys(t *testing.T) {
+	hs := hashService(t)
+
+	mt := NewMerklePatriciaTrie(hs)
+	if err := mt.Insert([]byte("other:key"), []byte("foreign")); err != nil {
+		t.Fatal(err)
+	}
+	mt.UseKeys(NewPrefixKeyTransformer([]byte("tenant:")))
+	for _, key := range []string{"key", "key2", "cat"} {
+		if err := mt.Insert([]byte(key), []byte("value of "+key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	{
+		t.Log("Keys are stored under the prefix")
+		path, err := mt.FindMerklePath([]byte("key"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if key, _ := path.Key(); string(key) != "tenant:key" {
+			t.Errorf("Unexpected stored key.\n  got = %s\n  want = %s", key, "tenant:key")
+		}
+		value, err := mt.Get([]byte("key"))
+		if err != nil || string(value) != "value of key" {
+			t.Errorf("Unexpected value. value: %s, err: %v", value, err)
+		}
+		if ok, _ := mt.Has([]byte("tenant:key")); ok {
+			t.Error("Stored key must not be reachable as a plain key")
+		}
+	}
+
+	{
+		t.Log("Iteration hands out the plain keys and skips the keys of others")
+		var keys []string
+		for key := range mt.All() {
+			keys = append(keys, string(key))
+		}
+		if want := "cat,key,key2"; strings.Join(keys, ",") != want {
+			t.Errorf("Unexpected keys.\n  got = %s\n  want = %s", strings.Join(keys, ","), want)
+		}
+		keys = nil
+		it := mt.NewReversePrefixIterator([]byte("key"))
+		for it.Next() {
+			keys = append(keys, string(it.Key()))
+		}
+		if it.Err() != nil {
+			t.Error(it.Err())
+		}
+		if want := "key2,key"; strings.Join(keys, ",") != want {
+			t.Errorf("Unexpected keys under prefix.\n  got = %s\n  want = %s", strings.Join(keys, ","), want)
+		}
+		keys = nil
+		if err := mt.Walk(func(key, value []byte) bool {
+			keys = append(keys, string(key))
+			return true
+		}); err != nil {
+			t.Error(err)
+		}
+		if want := "cat,key,key2"; strings.Join(keys, ",") != want {
+			t.Errorf("Unexpected walked keys.\n  got = %s\n  want = %s", strings.Join(keys, ","), want)
+		}
+		if n := len(mt.Keys()); n != 3 {
+			t.Errorf("Unexpected number of keys. got = %d, want = %d", n, 3)
+		}
+	}
+
+	{
+		t.Log("Deletes and proofs take the plain keys")
+		if _, err := mt.ProveAbsence([]byte("dog")); err != nil {
+			t.Error(err)
+		}
+		if _, err := mt.ProveKeys([][]byte{[]byte("cat"), []byte("key")}); err != nil {
+			t.Error(err)
+		}
+		if err := mt.Delete([]byte("cat")); err != nil {
+			t.Error(err)
+		}
+		mt.UseKeys(nil)
+		if ok, _ := mt.Has([]byte("tenant:cat")); ok {
+			t.Error("Deleted key must be gone")
+		}
+		if ok, _ := mt.Has([]byte("other:key")); !ok {
+			t.Error("Keys of others must stay")
+		}
+	}
+}
+
+func TestNewPrefixKeyTransformer_Range(t *testing.T) {
+	hs := hashService(t)
+
+	// Both tenants see the same trie
+	alice, bob := NewMerklePatriciaTrie(hs), NewMerklePatriciaTrie(hs)
+	for _, mt := range []*MerklePatriciaTrie{alice, bob} {
+		for _, key := range []string{"alice:a", "alice:b", "bob:a", "bob:c"} {
+			owner, plain, _ := strings.Cut(key, ":")
+			if err := mt.Insert([]byte(key), []byte(owner+"'s "+plain)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	alice.UseKeys(NewPrefixKeyTransformer([]byte("alice:")))
+	bob.UseKeys(NewPrefixKeyTransformer([]byte("bob:")))
+
+	{
+		t.Log("Unbounded range proofs hold only the keys of the tenant")
+		proof, err := alice.ProveRange(nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pairs, err := VerifyRange(hs, alice.RootHash(), []byte("alice:"), []byte("alice;"), proof)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(pairs) != 2 || string(pairs[0].Key) != "alice:a" || string(pairs[1].Key) != "alice:b" {
+			t.Errorf("Unexpected pairs = %q", pairs)
+		}
+		if _, err := VerifyRange(hs, alice.RootHash(), nil, nil, proof); err == nil {
+			t.Error("Proof must not cover the keys of the other tenant")
+		}
+		if proof, err = alice.ProveRange([]byte("b"), []byte("zzz")); err != nil {
+			t.Fatal(err)
+		}
+		if pairs, err := VerifyRange(hs, alice.RootHash(), []byte("alice:b"), []byte("alice;"), proof); err != nil || len(pairs) != 1 {
+			t.Errorf("End beyond the tenant must be clamped. got = %q, err: %v", pairs, err)
+		}
+	}
+
+	{
+		t.Log("Exports and range iterators hold only the keys of the tenant")
+		var bf bytes.Buffer
+		if err := bob.ExportVerifiable(nil, &bf); err != nil {
+			t.Fatal(err)
+		}
+		pairs, err := VerifyBundle(hs, &bf, bob.RootHash(), []byte("bob:"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(pairs) != 2 || string(pairs[1].Value) != "bob's c" {
+			t.Errorf("Unexpected pairs = %q", pairs)
+		}
+		it := bob.NewRangeIterator(nil, nil)
+		n := 0
+		for ; it.Next(); n++ {
+			if !strings.HasPrefix(string(it.Value()), "bob's") {
+				t.Errorf("Unexpected value = <%s>", it.Value())
+			}
+		}
+		if it.Err() != nil || n != 2 {
+			t.Errorf("Unexpected number of pairs = %d, err: %v", n, it.Err())
+		}
+	}
+}
+
+func TestNewCaseFoldKeyTransformer(t *testing.T) {
+	hs := hashService(t)
+
+	mt := NewMerklePatriciaTrie(hs)
+	mt.UseKeys(NewCaseFoldKeyTransformer())
+	if err := mt.Insert([]byte("Key\xff"), []byte("value")); err != nil {
+		t.Fatal(err)
+	}
+	if err := mt.Insert([]byte("KEY\xff"), []byte("value")); err == nil {
+		t.Error("Keys differing only in case must be the same")
+	}
+	value, err := mt.Get([]byte("kEy\xff"))
+	if err != nil || string(value) != "value" {
+		t.Errorf("Unexpected value. value: %s, err: %v", value, err)
+	}
+	for key := range mt.All() {
+		if !bytes.Equal(key, []byte("key\xff")) {
+			t.Errorf("Unexpected key.\n  got = %q\n  want = %q", key, "key\xff")
+		}
+	}
+}
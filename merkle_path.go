@@ -0,0 +1,217 @@
+package merkle_patricia_trie
+
+import (
+	"github.com/example/infra/db/merkle_patricia_trie/mptproof"
+	"github.com/example/infra/db/merkle_patricia_trie/trie"
+	"github.com/example/service/crypto"
+)
+
+// The wire formats and the verification of paths live in the mptproof package,
+// so clients can verify proofs without importing the trie.
+
+// MerkleSet is one level of a MerklePath.
+// Branch levels set bit i of bitmap for each present child i and hold only the present child hashes in index order.
+// Extension and root levels have a zero bitmap and hold the node hash.
+type MerkleSet struct {
+	bitmap uint16
+	hashes []trie.HashBlob
+	// Extension levels also carry the body of the node, so a verifier can recompute its hash.
+	// nextHash is set only on the first level; the next node of an upper level is the level below it.
+	key      string
+	value    trie.ValueObject
+	nextHash trie.HashBlob
+}
+
+func extensionSet(node trie.NodeExtension, withNext bool) MerkleSet {
+	set := MerkleSet{hashes: []trie.HashBlob{node.Hash()}, key: node.Key(), value: node.ValueObject()}
+	if withNext && node.HasNext() {
+		set.nextHash = node.Next().Hash()
+	}
+	return set
+}
+
+func branchSet(node trie.NodeBranch) MerkleSet {
+	var set MerkleSet
+	for i, c := range node.ListChildren() {
+		if c != nil {
+			set.bitmap |= 1 << uint(i)
+			set.hashes = append(set.hashes, c.Hash())
+		}
+	}
+	return set
+}
+
+func (s MerkleSet) isBranch() bool {
+	return s.bitmap != 0
+}
+
+func (s MerkleSet) proofSet() mptproof.Set {
+	set := mptproof.Set{Bitmap: s.bitmap, Key: s.key, Next: s.nextHash}
+	for _, h := range s.hashes {
+		set.Hashes = append(set.Hashes, h)
+	}
+	if s.value != nil {
+		set.Value, set.HasValue = s.value.Value(), true
+	}
+	return set
+}
+
+func merkleSetOf(set mptproof.Set) MerkleSet {
+	s := MerkleSet{bitmap: set.Bitmap, key: set.Key, nextHash: set.Next}
+	for _, h := range set.Hashes {
+		s.hashes = append(s.hashes, h)
+	}
+	if set.HasValue {
+		s.value = trie.NewValueObject(set.Value)
+	}
+	return s
+}
+
+func (s MerkleSet) MarshalJSON() ([]byte, error) {
+	return s.proofSet().MarshalJSON()
+}
+
+// UnmarshalJSON parses the format of MarshalJSON and rejects sets which could not have been produced by it.
+func (s *MerkleSet) UnmarshalJSON(data []byte) error {
+	var set mptproof.Set
+	if err := set.UnmarshalJSON(data); err != nil {
+		return err
+	}
+	*s = merkleSetOf(set)
+	return nil
+}
+
+// MarshalBinary encodes the set as the 2-byte big-endian bitmap, a 1-byte hash size and the hashes.
+// Non-branch levels continue with the uvarint-prefixed key, a flags byte,
+// the uvarint-prefixed value if flagged and the next hash if flagged.
+func (s MerkleSet) MarshalBinary() ([]byte, error) {
+	return s.proofSet().MarshalBinary()
+}
+
+func (s *MerkleSet) UnmarshalBinary(data []byte) error {
+	var set mptproof.Set
+	if err := set.UnmarshalBinary(data); err != nil {
+		return err
+	}
+	*s = merkleSetOf(set)
+	return nil
+}
+
+// Direct path from leaf to root
+type MerklePath []MerkleSet
+
+// ProofPath converts the path for the verifiers of the mptproof package.
+func (mp MerklePath) ProofPath() mptproof.Path {
+	path := make(mptproof.Path, len(mp))
+	for i, s := range mp {
+		path[i] = s.proofSet()
+	}
+	return path
+}
+
+func merklePathOf(path mptproof.Path) MerklePath {
+	mp := make(MerklePath, len(path))
+	for i, set := range path {
+		mp[i] = merkleSetOf(set)
+	}
+	return mp
+}
+
+func (mp MerklePath) MarshalJSON() ([]byte, error) {
+	return mp.ProofPath().MarshalJSON()
+}
+
+func (mp *MerklePath) UnmarshalJSON(data []byte) error {
+	var path mptproof.Path
+	if err := path.UnmarshalJSON(data); err != nil {
+		return err
+	}
+	*mp = merklePathOf(path)
+	return nil
+}
+
+// MarshalBinary encodes the path as a version byte, the uvarint number of sets
+// and every set from the leaf up prefixed with its uvarint length.
+func (mp MerklePath) MarshalBinary() ([]byte, error) {
+	return mp.ProofPath().MarshalBinary()
+}
+
+func (mp *MerklePath) UnmarshalBinary(data []byte) error {
+	var path mptproof.Path
+	if err := path.UnmarshalBinary(data); err != nil {
+		return err
+	}
+	*mp = merklePathOf(path)
+	return nil
+}
+
+// MarshalSSZ encodes the path with the SSZ schema of mptproof.Path.
+func (mp MerklePath) MarshalSSZ() ([]byte, error) {
+	return mp.ProofPath().MarshalSSZ()
+}
+
+func (mp *MerklePath) UnmarshalSSZ(data []byte) error {
+	var path mptproof.Path
+	if err := path.UnmarshalSSZ(data); err != nil {
+		return err
+	}
+	*mp = merklePathOf(path)
+	return nil
+}
+
+// MarshalMerklePaths encodes several paths with the sets they share stored once.
+func MarshalMerklePaths(paths []MerklePath) ([]byte, error) {
+	pps := make([]mptproof.Path, len(paths))
+	for i, mp := range paths {
+		pps[i] = mp.ProofPath()
+	}
+	return mptproof.MarshalPaths(pps)
+}
+
+func UnmarshalMerklePaths(data []byte) ([]MerklePath, error) {
+	pps, err := mptproof.UnmarshalPaths(data)
+	if err != nil {
+		return nil, err
+	}
+	paths := make([]MerklePath, len(pps))
+	for i, path := range pps {
+		paths[i] = merklePathOf(path)
+	}
+	return paths, nil
+}
+
+// Key returns the key proven by the path, reassembled from the key fragments of its extension levels.
+func (mp MerklePath) Key() ([]byte, error) {
+	return mp.ProofPath().Key()
+}
+
+// Value returns the committed value carried by the leaf level of the path.
+func (mp MerklePath) Value() []byte {
+	if len(mp) == 0 || mp[0].value == nil {
+		return nil
+	}
+	return mp[0].value.Value()
+}
+
+// VerifyMerklePath recomputes the node hashes of path from the leaf up to the root
+// and checks that it proves value is stored at key under rootHash.
+// value is the value as committed, i.e. after the value transforms of the trie.
+func VerifyMerklePath(hs crypto.Hash, rootHash trie.HashBlob, key, value []byte, path MerklePath) error {
+	return mptproof.Verify(hs, rootHash, key, value, path.ProofPath())
+}
+
+// ProofItem is one proof checked by VerifyAll.
+type ProofItem struct {
+	Key   []byte
+	Value []byte
+	Path  MerklePath
+}
+
+// VerifyAll verifies every item against the same root, hashing the upper nodes the proofs share once.
+func VerifyAll(hs crypto.Hash, rootHash trie.HashBlob, items []ProofItem) error {
+	proofItems := make([]mptproof.Item, len(items))
+	for i, item := range items {
+		proofItems[i] = mptproof.Item{Key: item.Key, Value: item.Value, Path: item.Path.ProofPath()}
+	}
+	return mptproof.VerifyAll(hs, rootHash, proofItems)
+}
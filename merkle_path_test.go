@@ -0,0 +1,347 @@
+package merkle_patricia_trie
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/example/infra/db/merkle_patricia_trie/trie"
+)
+
+func TestVerifyMerklePath(t *testing.T) {
+	hs := hashService(t)
+
+	mt := NewMerklePatriciaTrie(hs)
+	keys := []string{"key", "key123", "key12ab", "dog", "doge", "cat"}
+	for _, key := range keys {
+		if err := mt.Insert([]byte(key), []byte("v"+key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	{
+		t.Log("Valid paths are accepted")
+
+		for _, key := range keys {
+			path, err := mt.FindMerklePath([]byte(key))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := VerifyMerklePath(hs, mt.RootHash(), []byte(key), []byte("v"+key), path); err != nil {
+				t.Errorf("Valid path of %s is rejected: %s", key, err)
+			}
+		}
+	}
+	{
+		t.Log("Wrong key, value or root is rejected")
+
+		path, err := mt.FindMerklePath([]byte("key123"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := VerifyMerklePath(hs, mt.RootHash(), []byte("key123"), []byte("vkey"), path); err == nil {
+			t.Error("Wrong value must be rejected")
+		}
+		if err := VerifyMerklePath(hs, mt.RootHash(), []byte("key12"), []byte("vkey123"), path); err == nil {
+			t.Error("Wrong key must be rejected")
+		}
+		if err := VerifyMerklePath(hs, mt.RootHash(), []byte("kez123"), []byte("vkey123"), path); err == nil {
+			t.Error("Wrong key must be rejected")
+		}
+		root := append(trie.HashBlob(nil), mt.RootHash()...)
+		root[0] ^= 0xff
+		if err := VerifyMerklePath(hs, root, []byte("key123"), []byte("vkey123"), path); err == nil {
+			t.Error("Wrong root hash must be rejected")
+		}
+	}
+	{
+		t.Log("Tampered path is rejected")
+
+		tampered := func(tamper func(MerklePath)) MerklePath {
+			path, err := mt.FindMerklePath([]byte("key12ab"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			tamper(path)
+			return path
+		}
+		cases := map[string]MerklePath{
+			"sibling hash": tampered(func(path MerklePath) {
+				path[1].hashes[0] = append(trie.HashBlob(nil), path[1].hashes[0]...)
+				path[1].hashes[0][0] ^= 0xff
+			}),
+			"key fragment": tampered(func(path MerklePath) {
+				path[2].key = path[2].key[:len(path[2].key)-1] + "0"
+			}),
+			"intermediate value": tampered(func(path MerklePath) {
+				path[3].value = trie.NewValueObject([]byte("forged"))
+			}),
+			"truncated": tampered(func(path MerklePath) {
+				path[len(path)-1] = path[len(path)-2]
+			}),
+		}
+		for name, path := range cases {
+			if err := VerifyMerklePath(hs, mt.RootHash(), []byte("key12ab"), []byte("vkey12ab"), path); err == nil {
+				t.Errorf("Path with tampered %s must be rejected", name)
+			}
+		}
+	}
+}
+
+func TestMerklePath_KeyValue(t *testing.T) {
+	hs := hashService(t)
+
+	mt := NewMerklePatriciaTrie(hs)
+	keys := []string{"key", "key123", "key12ab", "dog", "doge", "cat"}
+	for _, key := range keys {
+		if err := mt.Insert([]byte(key), []byte("v"+key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, key := range keys {
+		path, err := mt.FindMerklePath([]byte(key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		k, err := path.Key()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(k) != key || string(path.Value()) != "v"+key {
+			t.Errorf("Unexpected key and value of path.\n  got = %s, %s\n  want = %s, %s", k, path.Value(), key, "v"+key)
+		}
+		if err := VerifyMerklePath(hs, mt.RootHash(), k, path.Value(), path); err != nil {
+			t.Errorf("Path of %s must verify against its own key and value: %s", key, err)
+		}
+	}
+}
+
+func TestMerklePath_MarshalBinary(t *testing.T) {
+	hs := hashService(t)
+
+	mt := NewMerklePatriciaTrie(hs)
+	for _, key := range []string{"key", "key123", "key12ab"} {
+		if err := mt.Insert([]byte(key), []byte("v"+key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, key := range []string{"key", "key12ab"} {
+		path, err := mt.FindMerklePath([]byte(key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := path.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		j, err := path.MarshalJSON()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(data) >= len(j) {
+			t.Errorf("Binary path must be smaller than JSON. got = %d bytes, JSON = %d bytes", len(data), len(j))
+		}
+
+		var decoded MerklePath
+		if err := decoded.UnmarshalBinary(data); err != nil {
+			t.Fatal(err)
+		}
+		if err := VerifyMerklePath(hs, mt.RootHash(), []byte(key), []byte("v"+key), decoded); err != nil {
+			t.Errorf("Decoded path of %s does not verify: %s", key, err)
+		}
+
+		for name, bad := range map[string][]byte{
+			"empty":     {},
+			"version":   append([]byte{0x02}, data[1:]...),
+			"truncated": data[:len(data)-1],
+			"trailing":  append(append([]byte(nil), data...), 0x00),
+			"count":     {0x01, 0xff, 0xff, 0xff, 0xff, 0x0f},
+		} {
+			if err := new(MerklePath).UnmarshalBinary(bad); err == nil {
+				t.Errorf("Path with bad %s must be rejected", name)
+			}
+		}
+	}
+}
+
+func TestMerklePath_UnmarshalJSON(t *testing.T) {
+	hs := hashService(t)
+
+	mt := NewMerklePatriciaTrie(hs)
+	for _, key := range []string{"key", "key123", "key12ab"} {
+		if err := mt.Insert([]byte(key), []byte("v"+key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, key := range []string{"key", "key12ab"} {
+		path, err := mt.FindMerklePath([]byte(key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		j, err := json.Marshal(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var decoded MerklePath
+		if err := json.Unmarshal(j, &decoded); err != nil {
+			t.Fatal(err)
+		}
+		if err := VerifyMerklePath(hs, mt.RootHash(), []byte(key), []byte("v"+key), decoded); err != nil {
+			t.Errorf("Decoded path of %s does not verify: %s", key, err)
+		}
+		again, err := json.Marshal(decoded)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(again) != string(j) {
+			t.Errorf("Path of %s does not round trip.\n  got = %s\n  want = %s", key, again, j)
+		}
+	}
+
+	h := strings.Repeat("ab", 32)
+	for _, bad := range []string{
+		`{"hashes":[]}`,
+		`{"hashes":["zz"]}`,
+		`{"hashes":["` + h + `","abab"],"bitmap":"0003"}`,
+		`{"bitmap":"0003","hashes":["` + h + `"]}`,
+		`{"bitmap":"0000","hashes":["` + h + `"]}`,
+		`{"bitmap":"003","hashes":["` + h + `"]}`,
+		`{"bitmap":"0001","hashes":["` + h + `"],"key":"6b"}`,
+		`{"hashes":["` + h + `","` + h + `"]}`,
+		`{"hashes":["` + h + `"],"key":"6B"}`,
+		`{"hashes":["` + h + `"],"key":"6b","next":"abab"}`,
+		`{"hashes":["` + h + `"],"value":"00"}`,
+		`{"hashes":["` + h + `"],"extra":1}`,
+	} {
+		if err := new(MerkleSet).UnmarshalJSON([]byte(bad)); err == nil {
+			t.Errorf("Invalid set must be rejected. set = %s", bad)
+		}
+	}
+}
+
+func TestMarshalMerklePaths(t *testing.T) {
+	hs := hashService(t)
+
+	mt := NewMerklePatriciaTrie(hs)
+	keys := []string{"key", "key123", "key12ab", "key12ac", "dog"}
+	for _, key := range keys {
+		if err := mt.Insert([]byte(key), []byte("v"+key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var paths []MerklePath
+	separate := 0
+	for _, key := range keys {
+		path, err := mt.FindMerklePath([]byte(key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := path.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		separate += len(data)
+		paths = append(paths, path)
+	}
+	paths = append(paths, paths[0])
+
+	data, err := MarshalMerklePaths(paths)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) >= separate {
+		t.Errorf("Shared sets must be stored once. got = %d bytes, separately = %d bytes", len(data), separate)
+	}
+	decoded, err := UnmarshalMerklePaths(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != len(paths) {
+		t.Fatalf("Unexpected number of paths. got = %d, want = %d", len(decoded), len(paths))
+	}
+	for i, path := range decoded {
+		key := keys[i%len(keys)]
+		if err := VerifyMerklePath(hs, mt.RootHash(), []byte(key), []byte("v"+key), path); err != nil {
+			t.Errorf("Decoded path of %s does not verify: %s", key, err)
+		}
+	}
+
+	for name, bad := range map[string][]byte{
+		"empty":     {},
+		"version":   append([]byte{0x02}, data[1:]...),
+		"truncated": data[:len(data)-1],
+		"trailing":  append(append([]byte(nil), data...), 0x00),
+		"index":     append(append([]byte(nil), data[:len(data)-1]...), 0x7f),
+	} {
+		if _, err := UnmarshalMerklePaths(bad); err == nil {
+			t.Errorf("Paths with bad %s must be rejected", name)
+		}
+	}
+}
+
+func TestVerifyAll(t *testing.T) {
+	hs := hashService(t)
+
+	mt := NewMerklePatriciaTrie(hs)
+	keys := []string{"key", "key123", "key12ab", "dog", "doge", "cat"}
+	for _, key := range keys {
+		if err := mt.Insert([]byte(key), []byte("v"+key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var items []ProofItem
+	for _, key := range keys {
+		path, err := mt.FindMerklePath([]byte(key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		items = append(items, ProofItem{Key: []byte(key), Value: []byte("v" + key), Path: path})
+	}
+
+	if err := VerifyAll(hs, mt.RootHash(), items); err != nil {
+		t.Errorf("Valid proofs are rejected: %s", err)
+	}
+	if err := VerifyAll(hs, mt.RootHash(), nil); err != nil {
+		t.Errorf("No proofs must pass: %s", err)
+	}
+
+	items[3].Value = []byte("forged")
+	err := VerifyAll(hs, mt.RootHash(), items)
+	if err == nil {
+		t.Fatal("Forged proof must be rejected")
+	}
+	if !strings.Contains(err.Error(), "proof #3") {
+		t.Errorf("Error must name the rejected proof. got = %s", err)
+	}
+}
+
+func TestMerklePath_MarshalSSZ(t *testing.T) {
+	hs := hashService(t)
+
+	mt := NewMerklePatriciaTrie(hs)
+	for _, key := range []string{"key", "key123", "key12ab", "dog"} {
+		if err := mt.Insert([]byte(key), []byte("v"+key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, key := range []string{"key", "key12ab", "dog"} {
+		path, err := mt.FindMerklePath([]byte(key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := path.MarshalSSZ()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var decoded MerklePath
+		if err := decoded.UnmarshalSSZ(data); err != nil {
+			t.Fatal(err)
+		}
+		if err := VerifyMerklePath(hs, mt.RootHash(), []byte(key), []byte("v"+key), decoded); err != nil {
+			t.Errorf("Decoded path of key = <%s> does not verify: %s", key, err)
+		}
+		if err := decoded.UnmarshalSSZ(data[:len(data)-1]); err == nil {
+			t.Error("Truncated path must be rejected")
+		}
+	}
+}
@@ -1,46 +1,31 @@
 package merkle_patricia_trie
 
 import (
-	"bytes"
 	"encoding/hex"
 	"fmt"
+	"strings"
 
+	"github.com/example/infra/db/merkle_patricia_trie/mptproof"
 	"github.com/example/infra/db/merkle_patricia_trie/trie"
 	"github.com/example/service/crypto"
 	"github.com/pkg/errors"
 )
 
-type MerkleSet struct {
-	hashes []trie.HashBlob
-}
-
-// Direct path from leaf to root
-type MerklePath []MerkleSet
-
-func (mp MerklePath) MarshalJSON() ([]byte, error) {
-	bf := bytes.NewBufferString("[")
-	for mpIndex, s := range mp {
-		if mpIndex > 0 {
-			bf.WriteByte(',')
-		}
-		bf.WriteByte('[')
-		for setIndex, h := range s.hashes {
-			if setIndex > 0 {
-				bf.WriteByte(',')
-			}
-			bf.WriteByte('"')
-			bf.WriteString(hex.EncodeToString(h))
-			bf.WriteByte('"')
-		}
-		bf.WriteByte(']')
-	}
-	bf.WriteByte(']')
-	return bf.Bytes(), nil
-}
+// ErrEmptyKey is returned by every operation given a zero-length key. Keys are never empty:
+// the root is a branch and holds no value, so there is no slot to store one at.
+var ErrEmptyKey = mptproof.ErrEmptyKey
 
 type MerklePatriciaTrie struct {
-	hs   crypto.Hash
-	root trie.NodeBranch
+	hs         crypto.Hash
+	root       trie.NodeBranch
+	transforms []ValueTransform
+	keys       KeyTransformer
+	// deferHash leaves new nodes unhashed and skips rehashing the insert path; InsertBatch hashes once at the end
+	deferHash bool
+	// meter is the innermost measure in progress
+	meter *meter
+	// envelopes wraps every value with its ValueMeta before the value transforms
+	envelopes bool
 }
 
 func min(a, b int) int {
@@ -66,14 +51,46 @@ func (mt *MerklePatriciaTrie) commonPrefix(a, b string) (string, error) {
 	return a[:minLen], nil
 }
 
-func (mt *MerklePatriciaTrie) insertToExtension(key string, valueObject trie.ValueObject, node trie.NodeExtension) error {
+func (mt *MerklePatriciaTrie) updateHash(node trie.Node) error {
+	if mt.deferHash {
+		return nil
+	}
+	return node.UpdateHash(mt.hs)
+}
+
+// newExtension creates an extension node. It is left unhashed while the hash is deferred.
+func (mt *MerklePatriciaTrie) newExtension(key string, next trie.Node, valueObject trie.ValueObject) (trie.NodeExtension, error) {
+	if mt.deferHash {
+		return trie.NewUnhashedNodeExtension(key, next, valueObject), nil
+	}
+	return trie.NewNodeExtension(key, next, valueObject, mt.hs)
+}
+
+// newBranch creates a branch node with two children. It is left unhashed while the hash is deferred.
+func (mt *MerklePatriciaTrie) newBranch(a, b trie.NodeExtension) (trie.NodeBranch, error) {
+	if !mt.deferHash {
+		return trie.NewNodeBranchWithChildren(a, b, mt.hs)
+	}
+	n := trie.NewNodeBranch()
+	if err := n.Append(a); err != nil {
+		return nil, err
+	}
+	if err := n.Append(b); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+func (mt *MerklePatriciaTrie) insertToExtension(key string, valueObject trie.ValueObject, node trie.NodeExtension, overwrite bool) (trie.ValueObject, error) {
+	mt.touch()
 	// Current node key is the end of the inserting key
 	if key == node.Key() {
-		if node.HasValueObject() {
-			return fmt.Errorf("MerklePatriciaTrie.insertKeyToExtension() failed. Key '%s' already exists", key)
+		if node.HasValueObject() && !overwrite {
+			return nil, fmt.Errorf("MerklePatriciaTrie.insertKeyToExtension() failed. Key '%s' already exists", key)
 		}
+		prev := node.ValueObject()
 		node.SetValueObject(valueObject)
-		return node.UpdateHash(mt.hs)
+		return prev, mt.updateHash(node)
 	}
 
 	prefix, err := mt.commonPrefix(node.Key(), key)
@@ -85,116 +102,164 @@ func (mt *MerklePatriciaTrie) insertToExtension(key string, valueObject trie.Val
 	if prefix == node.Key() {
 		keyTail := key[len(prefix):]
 		if !node.HasNext() {
-			newTailNode, err := trie.NewNodeExtension(keyTail, nil, valueObject, mt.hs)
+			newTailNode, err := mt.newExtension(keyTail, nil, valueObject)
 			if err != nil {
-				return err
+				return nil, err
 			}
 			node.SetNext(newTailNode)
-			return node.UpdateHash(mt.hs)
+			return nil, mt.updateHash(node)
 		}
 
 		switch next := node.Next().(type) {
 		case trie.NodeExtension:
 			if keyTail[0] == next.Key()[0] {
-				if err := mt.insertToExtension(keyTail, valueObject, next); err != nil {
-					return err
+				prev, err := mt.insertToExtension(keyTail, valueObject, next, overwrite)
+				if err != nil {
+					return nil, err
 				}
-				return node.UpdateHash(mt.hs)
+				return prev, mt.updateHash(node)
 			}
-			newKeyNode, err := trie.NewNodeExtension(keyTail, nil, valueObject, mt.hs)
+			newKeyNode, err := mt.newExtension(keyTail, nil, valueObject)
 			if err != nil {
-				return err
+				return nil, err
 			}
-			newBranch, err := trie.NewNodeBranchWithChildren(next, newKeyNode, mt.hs)
+			newBranch, err := mt.newBranch(next, newKeyNode)
 			if err != nil {
-				return err
+				return nil, err
 			}
 			node.SetNext(newBranch)
-			return node.UpdateHash(mt.hs)
+			return nil, mt.updateHash(node)
 		case trie.NodeBranch:
-			if err := mt.insertToBranch(keyTail, valueObject, next); err != nil {
-				return err
+			prev, err := mt.insertToBranch(keyTail, valueObject, next, overwrite)
+			if err != nil {
+				return nil, err
 			}
-			return node.UpdateHash(mt.hs)
+			return prev, mt.updateHash(node)
 		default:
 			panic("Unknown node type")
 		}
 	}
 	if prefix == key {
 		keyTail := node.Key()[len(prefix):]
-		tailNode, err := trie.NewNodeExtension(keyTail, node.Next(), node.ValueObject(), mt.hs)
+		tailNode, err := mt.newExtension(keyTail, node.Next(), node.ValueObject())
 		if err != nil {
-			return err
+			return nil, err
 		}
 
 		node.SetKey(prefix)
 		node.SetNext(tailNode)
 		node.SetValueObject(valueObject)
-		return node.UpdateHash(mt.hs)
+		return nil, mt.updateHash(node)
 	}
 
 	// 2. Divide (Ext + Branch + Ext * 2)
 	nodeKeyTail := node.Key()[len(prefix):]
-	nodeTailNode, err := trie.NewNodeExtension(nodeKeyTail, node.Next(), node.ValueObject(), mt.hs)
+	nodeTailNode, err := mt.newExtension(nodeKeyTail, node.Next(), node.ValueObject())
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	newKeyTail := key[len(prefix):]
-	newTailNode, err := trie.NewNodeExtension(newKeyTail, nil, valueObject, mt.hs)
+	newTailNode, err := mt.newExtension(newKeyTail, nil, valueObject)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	newBranch, err := trie.NewNodeBranchWithChildren(nodeTailNode, newTailNode, mt.hs)
+	newBranch, err := mt.newBranch(nodeTailNode, newTailNode)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	node.SetKey(prefix)
 	node.SetNext(newBranch)
 	node.SetValueObject(nil)
 
-	return node.UpdateHash(mt.hs)
+	return nil, mt.updateHash(node)
 }
 
-func (mt *MerklePatriciaTrie) insertToBranch(key string, valueObject trie.ValueObject, node trie.NodeBranch) error {
+func (mt *MerklePatriciaTrie) insertToBranch(key string, valueObject trie.ValueObject, node trie.NodeBranch, overwrite bool) (trie.ValueObject, error) {
+	mt.touch()
 	if node.HasChildAt(key[0]) {
-		if err := mt.insertToExtension(key, valueObject, node.ChildAt(key[0])); err != nil {
-			return err
+		prev, err := mt.insertToExtension(key, valueObject, node.ChildAt(key[0]), overwrite)
+		if err != nil {
+			return nil, err
 		}
-		return node.UpdateHash(mt.hs)
+		return prev, mt.updateHash(node)
 	}
-	n, err := trie.NewNodeExtension(key, nil, valueObject, mt.hs)
+	n, err := mt.newExtension(key, nil, valueObject)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if err := node.Append(n); err != nil {
-		return err
+		return nil, err
 	}
-	return node.UpdateHash(mt.hs)
+	return nil, mt.updateHash(node)
 }
 
-func (mt *MerklePatriciaTrie) Insert(key []byte, value []byte) error {
+func (mt *MerklePatriciaTrie) insert(key []byte, value []byte, meta ValueMeta, overwrite bool) (trie.ValueObject, error) {
 	if len(key) == 0 {
-		return fmt.Errorf("length of key must be positive")
+		return nil, ErrEmptyKey
 	}
 	ek := hex.EncodeToString(key)
+	value, err := mt.forwardValue(key, value, meta)
+	if err != nil {
+		return nil, err
+	}
 	vo := trie.NewValueObject(value)
-	if err := mt.insertToBranch(ek, vo, mt.root); err != nil {
+	root := copyPathsInBranch([]string{ek}, mt.root)
+	prev, err := mt.insertToBranch(ek, vo, root, overwrite)
+	if err != nil {
+		return nil, err
+	}
+	if err := root.UpdateHash(mt.hs); err != nil {
+		return nil, err
+	}
+	mt.root = root
+	return prev, nil
+}
+
+// Insert stores value at key. It fails if key already exists.
+func (mt *MerklePatriciaTrie) Insert(key []byte, value []byte) (err error) {
+	key, err = mt.forwardKey(key)
+	if err != nil {
 		return err
 	}
-	return mt.root.UpdateHash(mt.hs)
+	_, err = mt.insert(key, value, ValueMeta{}, false)
+	return err
+}
+
+// Put stores value at key, overwriting the existing value if any.
+// It returns the previous value and whether key existed, since an existing value may be empty.
+func (mt *MerklePatriciaTrie) Put(key []byte, value []byte) (prev []byte, existed bool, err error) {
+	return mt.put(key, value, ValueMeta{})
 }
 
-func (mt *MerklePatriciaTrie) deleteKeyInExtension(key string, node trie.NodeExtension) (shouldDelete bool, err error) {
+func (mt *MerklePatriciaTrie) put(key, value []byte, meta ValueMeta) ([]byte, bool, error) {
+	key, err := mt.forwardKey(key)
+	if err != nil {
+		return nil, false, err
+	}
+	prev, err := mt.insert(key, value, meta, true)
+	if err != nil || prev == nil {
+		return nil, false, err
+	}
+	value, err = mt.inverseValue(key, prev.Value())
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+func (mt *MerklePatriciaTrie) deleteKeyInExtension(key string, node trie.NodeExtension) (removed trie.ValueObject, shouldDelete bool, err error) {
+	mt.touch()
 	// Current node key is the end of the deleting key
 	if key == node.Key() {
 		if !node.HasValueObject() {
-			return false, fmt.Errorf("deleteKey is not found")
+			return nil, false, fmt.Errorf("deleteKey is not found")
 		}
+		removed = node.ValueObject()
 		if !node.HasNext() {
-			return true, nil
+			return removed, true, nil
 		}
 		// HasValueObject() && HasNext()
 		switch next := node.Next().(type) {
@@ -202,9 +267,10 @@ func (mt *MerklePatriciaTrie) deleteKeyInExtension(key string, node trie.NodeExt
 			node.SetKey(node.Key() + next.Key())
 			node.SetValueObject(next.ValueObject())
 			node.SetNext(next.Next())
-			return false, node.UpdateHash(mt.hs)
+			return removed, false, node.UpdateHash(mt.hs)
 		case trie.NodeBranch:
-			return false, node.UpdateHash(mt.hs)
+			node.SetValueObject(nil)
+			return removed, false, node.UpdateHash(mt.hs)
 		default:
 			panic("Unknown node type")
 		}
@@ -215,48 +281,48 @@ func (mt *MerklePatriciaTrie) deleteKeyInExtension(key string, node trie.NodeExt
 		panic(err)
 	}
 	if prefix == key {
-		return false, fmt.Errorf("ValueObject not found")
+		return nil, false, fmt.Errorf("ValueObject not found")
 	}
 
 	if prefix != node.Key() {
-		return false, fmt.Errorf("ValueObject not found")
+		return nil, false, fmt.Errorf("ValueObject not found")
 	}
 
 	keyTail := key[len(prefix):]
 	if !node.HasNext() {
-		return false, fmt.Errorf("ValueObject not found")
+		return nil, false, fmt.Errorf("ValueObject not found")
 	}
 
 	switch next := node.Next().(type) {
 	case trie.NodeExtension:
 		if keyTail[0] != next.Key()[0] {
-			return false, fmt.Errorf("ValueObject not found")
+			return nil, false, fmt.Errorf("ValueObject not found")
 		}
-		sd, err := mt.deleteKeyInExtension(keyTail, next)
+		removed, sd, err := mt.deleteKeyInExtension(keyTail, next)
 		if err != nil {
-			return false, err
+			return nil, false, err
 		}
 		if !sd {
-			return false, node.UpdateHash(mt.hs)
+			return removed, false, node.UpdateHash(mt.hs)
 		}
 		node.SetNext(nil)
 		if node.HasValueObject() {
-			return false, node.UpdateHash(mt.hs)
+			return removed, false, node.UpdateHash(mt.hs)
 		} else {
-			return true, nil
+			return removed, true, nil
 		}
 	case trie.NodeBranch:
-		sd, err := mt.deleteKeyInBranch(keyTail, next)
+		removed, sd, err := mt.deleteKeyInBranch(keyTail, next)
 		if err != nil {
-			return false, err
+			return nil, false, err
 		}
 		if !sd {
-			return false, node.UpdateHash(mt.hs)
+			return removed, false, node.UpdateHash(mt.hs)
 		}
 		newNext := next.First()
 		if node.HasValueObject() {
 			node.SetNext(newNext)
-			return false, node.UpdateHash(mt.hs)
+			return removed, false, node.UpdateHash(mt.hs)
 		}
 		if newNext == nil {
 			panic("newNext must not be nil because the deleting branch must have one child.")
@@ -264,51 +330,81 @@ func (mt *MerklePatriciaTrie) deleteKeyInExtension(key string, node trie.NodeExt
 		node.SetKey(node.Key() + newNext.Key())
 		node.SetValueObject(newNext.ValueObject())
 		node.SetNext(newNext.Next())
-		return false, node.UpdateHash(mt.hs)
+		return removed, false, node.UpdateHash(mt.hs)
 	default:
 		panic("Unknown node type")
 	}
 }
 
-func (mt *MerklePatriciaTrie) deleteKeyInBranch(key string, node trie.NodeBranch) (shouldDelete bool, err error) {
+func (mt *MerklePatriciaTrie) deleteKeyInBranch(key string, node trie.NodeBranch) (removed trie.ValueObject, shouldDelete bool, err error) {
+	mt.touch()
 	c := key[0]
 	if !node.HasChildAt(c) {
-		return false, fmt.Errorf("ValueObject not found under branch = <%c>", c)
+		return nil, false, fmt.Errorf("ValueObject not found under branch = <%c>", c)
 	}
-	sd, err := mt.deleteKeyInExtension(key, node.ChildAt(c))
+	removed, sd, err := mt.deleteKeyInExtension(key, node.ChildAt(c))
 	if err != nil {
-		return false, err
+		return nil, false, err
 	}
 	if !sd {
-		return false, node.UpdateHash(mt.hs)
+		return removed, false, node.UpdateHash(mt.hs)
 	}
 	if err := node.Delete(c); err != nil {
-		return false, err
+		return nil, false, err
 	}
 	if node.Count() == 1 {
-		return true, nil
+		return removed, true, nil
+	}
+	return removed, false, node.UpdateHash(mt.hs)
+}
+
+func (mt *MerklePatriciaTrie) Delete(key []byte) (err error) {
+	key, err = mt.forwardKey(key)
+	if err != nil {
+		return err
+	}
+	_, err = mt.delete(key)
+	return err
+}
+
+// Remove deletes key like Delete and returns the removed value.
+func (mt *MerklePatriciaTrie) Remove(key []byte) ([]byte, error) {
+	key, err := mt.forwardKey(key)
+	if err != nil {
+		return nil, err
 	}
-	return false, node.UpdateHash(mt.hs)
+	removed, err := mt.delete(key)
+	if err != nil {
+		return nil, err
+	}
+	return mt.inverseValue(key, removed.Value())
 }
 
-func (mt *MerklePatriciaTrie) Delete(key []byte) error {
+func (mt *MerklePatriciaTrie) delete(key []byte) (trie.ValueObject, error) {
 	if len(key) == 0 {
-		return fmt.Errorf("length of key must be positive")
+		return nil, ErrEmptyKey
 	}
 	ek := hex.EncodeToString(key)
+	root := copyPathsInBranch([]string{ek}, mt.root)
 	// shouldDelete is ignored if branch node is root
-	if _, err := mt.deleteKeyInBranch(ek, mt.root); err != nil {
-		return errors.Wrapf(err, "failed to delete key = <%s>", ek)
+	removed, _, err := mt.deleteKeyInBranch(ek, root)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to delete key = <%s>", ek)
 	}
-	return mt.root.UpdateHash(mt.hs)
+	if err := root.UpdateHash(mt.hs); err != nil {
+		return nil, err
+	}
+	mt.root = root
+	return removed, nil
 }
 
 func (mt *MerklePatriciaTrie) merklePathInExtension(key string, node trie.NodeExtension) (MerklePath, error) {
+	mt.touch()
 	if key == node.Key() {
 		if !node.HasValueObject() {
 			return nil, fmt.Errorf("ValueObject not found")
 		}
-		return MerklePath{MerkleSet{[]trie.HashBlob{node.Hash()}}}, nil
+		return MerklePath{extensionSet(node, true)}, nil
 	}
 
 	prefix, err := mt.commonPrefix(node.Key(), key)
@@ -337,19 +433,20 @@ func (mt *MerklePatriciaTrie) merklePathInExtension(key string, node trie.NodeEx
 		if err != nil {
 			return nil, err
 		}
-		return append(path, MerkleSet{[]trie.HashBlob{node.Hash()}}), nil
+		return append(path, extensionSet(node, false)), nil
 	case trie.NodeBranch:
 		path, err := mt.merklePathInBranch(keyTail, next)
 		if err != nil {
 			return nil, err
 		}
-		return append(path, MerkleSet{[]trie.HashBlob{node.Hash()}}), nil
+		return append(path, extensionSet(node, false)), nil
 	default:
 		panic("Unknown node type")
 	}
 }
 
 func (mt *MerklePatriciaTrie) merklePathInBranch(key string, node trie.NodeBranch) (MerklePath, error) {
+	mt.touch()
 	c := key[0]
 	if !node.HasChildAt(c) {
 		return nil, fmt.Errorf("ValueObject not found under branch = <%c>", c)
@@ -358,27 +455,108 @@ func (mt *MerklePatriciaTrie) merklePathInBranch(key string, node trie.NodeBranc
 	if err != nil {
 		return nil, err
 	}
-	var hs []trie.HashBlob
-	for _, c := range node.ListChildren() {
-		if c != nil {
-			hs = append(hs, c.Hash())
-		} else {
-			hs = append(hs, nil)
-		}
+	return append(path, branchSet(node)), nil
+}
+
+func (mt *MerklePatriciaTrie) FindMerklePath(key []byte) (path MerklePath, err error) {
+	key, err = mt.forwardKey(key)
+	if err != nil {
+		return nil, err
 	}
-	return append(path, MerkleSet{hs}), nil
+	return mt.findMerklePath(key)
 }
 
-func (mt *MerklePatriciaTrie) FindMerklePath(key []byte) (MerklePath, error) {
+func (mt *MerklePatriciaTrie) findMerklePath(key []byte) (MerklePath, error) {
 	if len(key) == 0 {
-		return nil, fmt.Errorf("length of key must be positive")
+		return nil, ErrEmptyKey
 	}
 	ek := hex.EncodeToString(key)
 	path, err := mt.merklePathInBranch(ek, mt.root)
 	if err != nil {
 		return nil, err
 	}
-	return append(path, MerkleSet{[]trie.HashBlob{mt.root.Hash()}}), nil
+	return append(path, MerkleSet{hashes: []trie.HashBlob{mt.root.Hash()}}), nil
+}
+
+func (mt *MerklePatriciaTrie) valueObjectInExtension(key string, node trie.NodeExtension) trie.ValueObject {
+	mt.touch()
+	if key == node.Key() {
+		return node.ValueObject()
+	}
+	if !strings.HasPrefix(key, node.Key()) || !node.HasNext() {
+		return nil
+	}
+
+	keyTail := key[len(node.Key()):]
+	switch next := node.Next().(type) {
+	case trie.NodeExtension:
+		if keyTail[0] != next.Key()[0] {
+			return nil
+		}
+		return mt.valueObjectInExtension(keyTail, next)
+	case trie.NodeBranch:
+		return mt.valueObjectInBranch(keyTail, next)
+	default:
+		panic("Unknown node type")
+	}
+}
+
+func (mt *MerklePatriciaTrie) valueObjectInBranch(key string, node trie.NodeBranch) trie.ValueObject {
+	mt.touch()
+	c := key[0]
+	if !node.HasChildAt(c) {
+		return nil
+	}
+	return mt.valueObjectInExtension(key, node.ChildAt(c))
+}
+
+// Get returns a copy of the value stored at key.
+func (mt *MerklePatriciaTrie) Get(key []byte) ([]byte, error) {
+	key, err := mt.forwardKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(key) == 0 {
+		return nil, ErrEmptyKey
+	}
+	ek := hex.EncodeToString(key)
+	vo := mt.valueObjectInBranch(ek, mt.root)
+	if vo == nil {
+		return nil, fmt.Errorf("ValueObject not found for key = <%s>", ek)
+	}
+	return mt.inverseValue(key, append([]byte(nil), vo.Value()...))
+}
+
+// Has reports whether key is stored without copying its value.
+func (mt *MerklePatriciaTrie) Has(key []byte) (bool, error) {
+	key, err := mt.forwardKey(key)
+	if err != nil {
+		return false, err
+	}
+	if len(key) == 0 {
+		return false, ErrEmptyKey
+	}
+	return mt.valueObjectInBranch(hex.EncodeToString(key), mt.root) != nil, nil
+}
+
+// RootHash returns the hash of the root node, which commits to the whole trie.
+func (mt *MerklePatriciaTrie) RootHash() trie.HashBlob {
+	return append(trie.HashBlob(nil), mt.root.Hash()...)
+}
+
+// RootHashHex returns RootHash() as a hex string.
+func (mt *MerklePatriciaTrie) RootHashHex() string {
+	return hex.EncodeToString(mt.root.Hash())
+}
+
+// Reset drops all nodes and restores the empty root, keeping the hash service and the key and value transforms.
+func (mt *MerklePatriciaTrie) Reset() error {
+	root := trie.NewNodeBranch()
+	if err := root.UpdateHash(mt.hs); err != nil {
+		return errors.Wrap(err, "failed to reset the root hash")
+	}
+	mt.root = root
+	return nil
 }
 
 func NewMerklePatriciaTrie(hs crypto.Hash) *MerklePatriciaTrie {
@@ -386,5 +564,5 @@ func NewMerklePatriciaTrie(hs crypto.Hash) *MerklePatriciaTrie {
 	if err := root.UpdateHash(hs); err != nil {
 		panic("Cannot initialize the root hash. Error of nodeBranch.UpdateHash(): " + err.Error())
 	}
-	return &MerklePatriciaTrie{hs, root}
+	return &MerklePatriciaTrie{hs: hs, root: root}
 }
@@ -2,11 +2,16 @@ package merkle_patricia_trie
 
 import (
 	"bytes"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"math/rand"
 	"regexp"
+	"strings"
 	"testing"
 
 	"github.com/example/entity"
+	"github.com/example/infra/db/merkle_patricia_trie/trie"
 	"github.com/example/service/crypto"
 	"github.com/example/service/crypto/sha256"
 )
@@ -119,6 +124,29 @@ func TestMerklePatriciaTrie_Insert(t *testing.T) {
 	}
 }
 
+func TestMerklePatriciaTrie_ChildIndex(t *testing.T) {
+	hs := hashService(t)
+
+	t.Log("Nibbles 'a'-'f' and '0'-'5' take distinct children of a branch")
+	trie := NewMerklePatriciaTrie(hs)
+	keys := []string{"\x0a", "\x00", "\xa0", "\x0f", "\x05"}
+	for _, key := range keys {
+		if err := trie.Insert([]byte(key), []byte("value-"+key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, key := range keys {
+		value, err := trie.Get([]byte(key))
+		if err != nil {
+			t.Errorf("Key <%x> is lost: %s", key, err)
+			continue
+		}
+		if string(value) != "value-"+key {
+			t.Errorf("Unexpected value of key <%x>.\n  got = %x\n  want = %x", key, value, "value-"+key)
+		}
+	}
+}
+
 func TestMerklePatriciaTrie_Delete(t *testing.T) {
 	hs := hashService(t)
 
@@ -251,11 +279,82 @@ func TestMerklePatriciaTrie_Delete(t *testing.T) {
 			t.Error("Cannot delete non-existent key")
 		}
 	}
+	{
+		t.Log("Delete the value of E whose next node is B")
+
+		trie := NewMerklePatriciaTrie(hs)
+		for _, key := range []string{"dog", "doge", "dogs"} {
+			if err := trie.Insert([]byte(key), []byte("value")); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := trie.Delete([]byte("dog")); err != nil {
+			t.Fatal(err)
+		}
+		if ok, err := trie.Has([]byte("dog")); err != nil || ok {
+			t.Errorf("Deleted key must not exist. ok: %v, err: %v", ok, err)
+		}
+		want := NewMerklePatriciaTrie(hs)
+		for _, key := range []string{"doge", "dogs"} {
+			if err := want.Insert([]byte(key), []byte("value")); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if !bytes.Equal(trie.root.Hash(), want.root.Hash()) {
+			t.Error("Inconsistent root hash after deleting the value of E->B")
+		}
+	}
+}
+
+// Delete merges the nodes it leaves with a single child, so any sequence of inserts and deletes
+// must end in the trie that inserting the remaining keys alone builds.
+func TestMerklePatriciaTrie_DeleteKeepsCanonicalShape(t *testing.T) {
+	hs := hashService(t)
+
+	rnd := rand.New(rand.NewSource(3))
+	for trial := 0; trial < 100; trial++ {
+		trie := NewMerklePatriciaTrie(hs)
+		live := make(map[string]bool)
+		for op := 0; op < 60; op++ {
+			// Few distinct bytes give long shared prefixes and many merges
+			key := make([]byte, 1+rnd.Intn(3))
+			for i := range key {
+				key[i] = byte(rnd.Intn(4))
+			}
+			switch {
+			case live[string(key)] && rnd.Intn(2) == 0:
+				if err := trie.Delete(key); err != nil {
+					t.Fatal(err)
+				}
+				delete(live, string(key))
+			case !live[string(key)]:
+				if err := trie.Insert(key, []byte("value")); err != nil {
+					t.Fatal(err)
+				}
+				live[string(key)] = true
+			}
+		}
+
+		want := NewMerklePatriciaTrie(hs)
+		for key := range live {
+			if err := want.Insert([]byte(key), []byte("value")); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if !bytes.Equal(trie.root.Hash(), want.root.Hash()) {
+			t.Fatalf("Trial #%d leaves a shape which inserting the remaining keys does not build", trial)
+		}
+	}
 }
 
 func TestMerklePatriciaTrie_FindMerklePath(t *testing.T) {
 	hs := hashService(t)
 
+	ext := `\{"hashes":\["[\d\w]{64}"\],"key":"[\da-f]+"(,"value":"[\da-f]*")?(,"next":"[\da-f]{64}")?\}`
+	branch1 := `\{"bitmap":"[\da-f]{4}","hashes":\["[\d\w]{64}"\]\}`
+	branch2 := `\{"bitmap":"[\da-f]{4}","hashes":\["[\d\w]{64}","[\d\w]{64}"\]\}`
+	root := `\{"hashes":\["[\d\w]{64}"\]\}`
+
 	{
 		trie := NewMerklePatriciaTrie(hs)
 		if err := trie.Insert([]byte("key"), []byte("value")); err != nil {
@@ -266,7 +365,7 @@ func TestMerklePatriciaTrie_FindMerklePath(t *testing.T) {
 			t.Error(err)
 		}
 		j, err := json.Marshal(path)
-		r := regexp.MustCompile(`^\[\["[\d\w]{64}"\],\[[",]*"[\d\w]{64}"[",]*\],\["[\d|\w]{64}"\]\]$`)
+		r := regexp.MustCompile(`^\[` + ext + `,` + branch1 + `,` + root + `\]$`)
 		if !r.MatchString(string(j)) {
 			t.Errorf("Merkle path is invalid.\n  got = %s\n  want = %s", j, r.String())
 		}
@@ -282,7 +381,7 @@ func TestMerklePatriciaTrie_FindMerklePath(t *testing.T) {
 			t.Error(err)
 		}
 		j, err = json.Marshal(path)
-		r = regexp.MustCompile(`^\[\["[\d\w]{64}"\],\["[\d\w]{64}"\],\[[",]*"[\d\w]{64}"[",]*\],\["[\d|\w]{64}"\]\]$`)
+		r = regexp.MustCompile(`^\[` + ext + `,` + ext + `,` + branch1 + `,` + root + `\]$`)
 		if !r.MatchString(string(j)) {
 			t.Errorf("Merkle path is invalid.\n  got = %s\n  want = %s", j, r.String())
 		}
@@ -298,7 +397,7 @@ func TestMerklePatriciaTrie_FindMerklePath(t *testing.T) {
 			t.Error(err)
 		}
 		j, err = json.Marshal(path)
-		r = regexp.MustCompile(`^\[\["[\d\w]{64}"\],\[[",]*"[\d\w]{64}"[",]*[\d\w]{64}"[",]*\],\["[\d\w]{64}"\],\["[\d\w]{64}"\],\[[",]*"[\d\w]{64}"[",]*\],\["[\d|\w]{64}"\]\]$`)
+		r = regexp.MustCompile(`^\[` + ext + `,` + branch2 + `,` + ext + `,` + ext + `,` + branch1 + `,` + root + `\]$`)
 		if !r.MatchString(string(j)) {
 			t.Errorf("Merkle path is invalid.\n  got = %s\n  want = %s", j, r.String())
 		}
@@ -321,3 +420,301 @@ func TestMerklePatriciaTrie_FindMerklePath(t *testing.T) {
 		}
 	}
 }
+
+func TestMerklePatriciaTrie_Get(t *testing.T) {
+	hs := hashService(t)
+
+	trie := NewMerklePatriciaTrie(hs)
+	for _, key := range []string{"dog", "cat", "doge", "k", "kk"} {
+		if err := trie.Insert([]byte(key), []byte("value-"+key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, key := range []string{"dog", "cat", "doge", "k", "kk"} {
+		value, err := trie.Get([]byte(key))
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if string(value) != "value-"+key {
+			t.Errorf("Unexpected value.\n  got = %s\n  want = %s", value, "value-"+key)
+		}
+	}
+	for _, key := range []string{"do", "dogs", "kkk", "x"} {
+		if _, err := trie.Get([]byte(key)); err == nil {
+			t.Errorf("Cannot get non-existent key <%s>", key)
+		}
+	}
+}
+
+func TestMerklePatriciaTrie_Has(t *testing.T) {
+	hs := hashService(t)
+
+	trie := NewMerklePatriciaTrie(hs)
+	for _, key := range []string{"key", "key123", "keyxyz"} {
+		if err := trie.Insert([]byte(key), []byte("value")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for key, want := range map[string]bool{"key": true, "key123": true, "keyxyz": true, "ke": false, "key1": false, "key1234": false} {
+		got, err := trie.Has([]byte(key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("Has(%s) = %v, want %v", key, got, want)
+		}
+	}
+	if _, err := trie.Has(nil); err == nil {
+		t.Error("Empty key must be rejected")
+	}
+}
+
+func TestMerklePatriciaTrie_Put(t *testing.T) {
+	hs := hashService(t)
+
+	{
+		t.Log("Put() overwrites the existing value and recomputes the root hash")
+
+		trie := NewMerklePatriciaTrie(hs)
+		for _, key := range []string{"dog", "cat", "doge"} {
+			prev, existed, err := trie.Put([]byte(key), []byte("value"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if prev != nil || existed {
+				t.Errorf("New key must not have existed. got = %s, %v", prev, existed)
+			}
+		}
+		if err := trie.Insert([]byte("dog"), []byte("value2")); err == nil {
+			t.Error("Insert() must reject the existing key")
+		}
+		prev, existed, err := trie.Put([]byte("dog"), []byte("value2"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(prev) != "value" || !existed {
+			t.Errorf("Previous value is not returned.\n  got = %s\n  want = value", prev)
+		}
+		value, err := trie.Get([]byte("dog"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(value) != "value2" {
+			t.Errorf("Value is not overwritten.\n  got = %s\n  want = value2", value)
+		}
+
+		want := NewMerklePatriciaTrie(hs)
+		for key, value := range map[string]string{"dog": "value2", "cat": "value", "doge": "value"} {
+			if err := want.Insert([]byte(key), []byte(value)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if !bytes.Equal(trie.root.Hash(), want.root.Hash()) {
+			t.Error("Root hash must equal the one of the trie built with the new value")
+		}
+	}
+
+	{
+		t.Log("Put() tells an existing empty value from an absent key")
+
+		trie := NewMerklePatriciaTrie(hs)
+		if _, existed, err := trie.Put([]byte("dog"), nil); err != nil || existed {
+			t.Errorf("New key must not have existed. existed: %v, err: %v", existed, err)
+		}
+		prev, existed, err := trie.Put([]byte("dog"), []byte("value"))
+		if err != nil || !existed || len(prev) != 0 {
+			t.Errorf("Key with an empty value must have existed. prev: %s, existed: %v, err: %v", prev, existed, err)
+		}
+	}
+}
+
+func TestMerklePatriciaTrie_Remove(t *testing.T) {
+	hs := hashService(t)
+
+	trie := NewMerklePatriciaTrie(hs)
+	for _, key := range []string{"dog", "cat", "doge"} {
+		if err := trie.Insert([]byte(key), []byte("value-"+key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	removed, err := trie.Remove([]byte("dog"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(removed) != "value-dog" {
+		t.Errorf("Removed value is not returned.\n  got = %s\n  want = value-dog", removed)
+	}
+	if ok, err := trie.Has([]byte("dog")); err != nil || ok {
+		t.Errorf("Removed key must not exist. ok: %v, err: %v", ok, err)
+	}
+	if _, err := trie.Remove([]byte("dog")); err == nil {
+		t.Error("Cannot remove non-existent key")
+	}
+}
+
+func TestMerklePatriciaTrie_RootHash(t *testing.T) {
+	hs := hashService(t)
+
+	trie := NewMerklePatriciaTrie(hs)
+	empty := trie.RootHash()
+	if err := trie.Insert([]byte("key"), []byte("value")); err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(empty, trie.RootHash()) {
+		t.Error("Root hash must change after Insert()")
+	}
+	if !bytes.Equal(trie.root.Hash(), trie.RootHash()) {
+		t.Error("RootHash() must return the root node hash")
+	}
+	if trie.RootHashHex() != hex.EncodeToString(trie.root.Hash()) {
+		t.Errorf("Unexpected hex root hash: %s", trie.RootHashHex())
+	}
+}
+
+func TestMerkleSet_MarshalBinary(t *testing.T) {
+	hs := hashService(t)
+
+	trie := NewMerklePatriciaTrie(hs)
+	for _, key := range []string{"key", "key123", "key12ab"} {
+		if err := trie.Insert([]byte(key), []byte("value")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path, err := trie.FindMerklePath([]byte("key12ab"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, set := range path {
+		data, err := set.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := 3 + 32*len(set.hashes); set.isBranch() && len(data) != want {
+			t.Errorf("Unexpected binary size of set #%d. got = %d, want = %d", i, len(data), want)
+		}
+		var decoded MerkleSet
+		if err := decoded.UnmarshalBinary(data); err != nil {
+			t.Fatal(err)
+		}
+		if decoded.bitmap != set.bitmap || len(decoded.hashes) != len(set.hashes) {
+			t.Errorf("Set #%d does not round trip", i)
+			continue
+		}
+		for j := range set.hashes {
+			if !bytes.Equal(decoded.hashes[j], set.hashes[j]) {
+				t.Errorf("Hash #%d of set #%d does not round trip", j, i)
+			}
+		}
+		if decoded.key != set.key || !bytes.Equal(decoded.nextHash, set.nextHash) {
+			t.Errorf("Extension of set #%d does not round trip", i)
+		}
+		if (decoded.value == nil) != (set.value == nil) || (set.value != nil && !bytes.Equal(decoded.value.Value(), set.value.Value())) {
+			t.Errorf("Value of set #%d does not round trip", i)
+		}
+	}
+	if err := new(MerkleSet).UnmarshalBinary([]byte{0x00, 0x03, 32}); err == nil {
+		t.Error("Set with missing hashes must be rejected")
+	}
+}
+
+func TestMerklePatriciaTrie_Reset(t *testing.T) {
+	hs := hashService(t)
+
+	trie := NewMerklePatriciaTrie(hs)
+	empty := trie.RootHash()
+	for _, key := range []string{"dog", "cat", "doge"} {
+		if err := trie.Insert([]byte(key), []byte("value")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := trie.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(empty, trie.RootHash()) {
+		t.Error("Reset() must restore the empty root hash")
+	}
+	if ok, err := trie.Has([]byte("dog")); err != nil || ok {
+		t.Errorf("Reset() must drop all keys. ok: %v, err: %v", ok, err)
+	}
+	if err := trie.Insert([]byte("dog"), []byte("value")); err != nil {
+		t.Errorf("Trie must be reusable after Reset(). err: %v", err)
+	}
+}
+
+func TestMerklePatriciaTrie_EmptyKey(t *testing.T) {
+	hs := hashService(t)
+
+	trie := NewMerklePatriciaTrie(hs)
+	trie.UseEnvelopes()
+	if err := trie.Insert([]byte("key"), []byte("value")); err != nil {
+		t.Fatal(err)
+	}
+	secure := NewSecureMerklePatriciaTrie(hs)
+	erasable := NewErasableTrie(NewMerklePatriciaTrie(hs), NewMemoryKeyStore())
+	operations := map[string]func() error{
+		"Insert":         func() error { return trie.Insert(nil, []byte("value")) },
+		"Put":            func() error { _, _, err := trie.Put([]byte{}, []byte("value")); return err },
+		"PutWithMeta":    func() error { _, _, err := trie.PutWithMeta(nil, []byte("value"), ValueMeta{}); return err },
+		"InsertBatch":    func() error { return trie.InsertBatch([]KV{{nil, []byte("value")}}) },
+		"Get":            func() error { _, err := trie.Get(nil); return err },
+		"Has":            func() error { _, err := trie.Has(nil); return err },
+		"ValueMeta":      func() error { _, err := trie.ValueMeta(nil); return err },
+		"Delete":         func() error { return trie.Delete(nil) },
+		"Remove":         func() error { _, err := trie.Remove(nil); return err },
+		"FindMerklePath": func() error { _, err := trie.FindMerklePath(nil); return err },
+		"ProveAbsence":   func() error { _, err := trie.ProveAbsence(nil); return err },
+		"ProveKeys":      func() error { _, err := trie.ProveKeys([][]byte{[]byte("key"), nil}); return err },
+		"VerifyMerklePath": func() error {
+			return VerifyMerklePath(hs, trie.RootHash(), nil, []byte("value"), MerklePath{})
+		},
+		"VerifyAbsence":       func() error { return VerifyAbsence(hs, trie.RootHash(), nil, MerklePath{}) },
+		"SecureTrie.Put":      func() error { _, _, err := secure.Put(nil, []byte("value")); return err },
+		"ErasableTrie.Insert": func() error { return erasable.Insert(nil, []byte("value")) },
+	}
+	for name, op := range operations {
+		if err := op(); !errors.Is(err, ErrEmptyKey) {
+			t.Errorf("%s() must fail with ErrEmptyKey for an empty key, got %v", name, err)
+		}
+	}
+}
+
+func TestMerklePatriciaTrie_Warnings(t *testing.T) {
+	hs := hashService(t)
+
+	var warnings []trie.Warning
+	previous := trie.SetWarningHandler(func(w trie.Warning) { warnings = append(warnings, w) })
+	defer trie.SetWarningHandler(previous)
+
+	mt := NewMerklePatriciaTrie(hs)
+	if err := mt.Insert([]byte("short"), []byte("value")); err != nil {
+		t.Fatal(err)
+	}
+	if err := mt.Insert([]byte("long"), bytes.Repeat([]byte{1}, 200)); err != nil {
+		t.Fatal(err)
+	}
+
+	{
+		t.Log("MarshalJSON() reports truncated values to the handler")
+		if _, err := mt.root.MarshalJSON(); err != nil {
+			t.Fatal(err)
+		}
+		if len(warnings) != 1 || warnings[0].Kind != trie.WarningValueTruncated {
+			t.Fatalf("Unexpected warnings = %v", warnings)
+		}
+		if key := hex.EncodeToString([]byte("long")); warnings[0].Key == "" || !strings.HasSuffix(key, warnings[0].Key) {
+			t.Errorf("Warning must carry the key fragment of the node\n  got = %s\n  want = suffix of %s", warnings[0].Key, key)
+		}
+	}
+
+	{
+		t.Log("A nil handler suppresses warnings")
+		trie.SetWarningHandler(nil)
+		if _, err := mt.root.MarshalJSON(); err != nil {
+			t.Fatal(err)
+		}
+		if len(warnings) != 1 {
+			t.Errorf("Warnings must be suppressed, got %v", warnings)
+		}
+	}
+}
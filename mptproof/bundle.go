@@ -0,0 +1,117 @@
+package mptproof
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// bundleVersion is the version byte of the encoding of MarshalPaths.
+const bundleVersion = 1
+
+// MarshalPaths encodes several paths with every distinct set stored once, so proofs of keys
+// sharing a prefix carry their common upper levels and the root level only once.
+// The encoding is a version byte, the uvarint number of distinct sets, every set prefixed with its uvarint length,
+// the uvarint number of paths and every path as its uvarint number of levels followed by the uvarint set indices.
+func MarshalPaths(paths []Path) ([]byte, error) {
+	var sets [][]byte
+	indices := make(map[string]int)
+	refs := make([][]int, len(paths))
+	for i, p := range paths {
+		refs[i] = make([]int, len(p))
+		for level, s := range p {
+			data, err := s.MarshalBinary()
+			if err != nil {
+				return nil, fmt.Errorf("failed to encode set #%d of path #%d: %w", level, i, err)
+			}
+			index, ok := indices[string(data)]
+			if !ok {
+				index = len(sets)
+				indices[string(data)] = index
+				sets = append(sets, data)
+			}
+			refs[i][level] = index
+		}
+	}
+
+	bf := bytes.NewBuffer([]byte{bundleVersion})
+	bf.Write(binary.AppendUvarint(nil, uint64(len(sets))))
+	for _, data := range sets {
+		bf.Write(binary.AppendUvarint(nil, uint64(len(data))))
+		bf.Write(data)
+	}
+	bf.Write(binary.AppendUvarint(nil, uint64(len(refs))))
+	for _, r := range refs {
+		bf.Write(binary.AppendUvarint(nil, uint64(len(r))))
+		for _, index := range r {
+			bf.Write(binary.AppendUvarint(nil, uint64(index)))
+		}
+	}
+	return bf.Bytes(), nil
+}
+
+// UnmarshalPaths decodes the encoding of MarshalPaths.
+// Paths referring to the same set share the decoded Set, so they must not be modified in place.
+func UnmarshalPaths(data []byte) ([]Path, error) {
+	r := bytes.NewReader(data)
+	version, err := r.ReadByte()
+	if err != nil {
+		return nil, fmt.Errorf("paths are empty")
+	}
+	if version != bundleVersion {
+		return nil, fmt.Errorf("unsupported paths version %d", version)
+	}
+
+	count, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the number of sets: %w", err)
+	}
+	// Every set takes at least 4 bytes
+	if count > uint64(r.Len()/4) {
+		return nil, fmt.Errorf("paths claim %d sets in %d bytes", count, r.Len())
+	}
+	sets := make([]Set, count)
+	for i := range sets {
+		data, err := readUvarintBytes(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read set #%d: %w", i, err)
+		}
+		if err := sets[i].UnmarshalBinary(data); err != nil {
+			return nil, fmt.Errorf("failed to decode set #%d: %w", i, err)
+		}
+	}
+
+	count, err = binary.ReadUvarint(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the number of paths: %w", err)
+	}
+	// Every path takes at least 1 byte
+	if count > uint64(r.Len()) {
+		return nil, fmt.Errorf("paths claim %d paths in %d bytes", count, r.Len())
+	}
+	paths := make([]Path, count)
+	for i := range paths {
+		levels, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the length of path #%d: %w", i, err)
+		}
+		if levels > uint64(r.Len()) {
+			return nil, fmt.Errorf("path #%d claims %d levels in %d bytes", i, levels, r.Len())
+		}
+		paths[i] = make(Path, levels)
+		for level := range paths[i] {
+			index, err := binary.ReadUvarint(r)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read set index of path #%d: %w", i, err)
+			}
+			if index >= uint64(len(sets)) {
+				return nil, fmt.Errorf("path #%d refers to set #%d of %d", i, index, len(sets))
+			}
+			paths[i][level] = sets[index]
+		}
+	}
+	if r.Len() != 0 {
+		return nil, fmt.Errorf("paths have %d trailing bytes", r.Len())
+	}
+	return paths, nil
+}
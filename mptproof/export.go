@@ -0,0 +1,143 @@
+package mptproof
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/example/service/crypto"
+)
+
+// A verifiable export is a tar archive of three files:
+// manifest.json binds the export to a root and a key prefix,
+// data.jsonl holds the key/value pairs under the prefix as hex, one object per line in key order,
+// and proof.bin is the range proof of the prefix, which shows the pairs are all the pairs under it.
+// Keys are the stored keys and values are the committed values, i.e. after the key and value transforms.
+
+const (
+	ExportVersion      = 1
+	ExportManifestFile = "manifest.json"
+	ExportDataFile     = "data.jsonl"
+	ExportProofFile    = "proof.bin"
+)
+
+type ExportManifest struct {
+	Version int    `json:"version"`
+	Root    string `json:"root"`
+	Prefix  string `json:"prefix"`
+	Count   int    `json:"count"`
+}
+
+type ExportPair struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// ParseRoot parses a root as hex, with or without the 0x prefix, in any case.
+func ParseRoot(s string) ([]byte, error) {
+	digits := s
+	if len(digits) >= 2 && strings.EqualFold(digits[:2], "0x") {
+		digits = digits[2:]
+	}
+	if digits == "" {
+		return nil, fmt.Errorf("empty root")
+	}
+	root, err := hex.DecodeString(digits)
+	if err != nil {
+		return nil, fmt.Errorf("invalid root = <%s>: %w", s, err)
+	}
+	return root, nil
+}
+
+// VerifyBundle reads an export and checks that it is bound to rootHash and prefix and that its data
+// are exactly the pairs under prefix proven by its range proof. It returns the pairs.
+// prefix is a stored key prefix, i.e. after the key transforms; an empty prefix expects the whole trie.
+func VerifyBundle(hs crypto.Hash, r io.Reader, rootHash, prefix []byte) ([]KV, error) {
+	files := make(map[string][]byte)
+	tr := tar.NewReader(r)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read export: %w", err)
+		}
+		if h.Name != ExportManifestFile && h.Name != ExportDataFile && h.Name != ExportProofFile {
+			return nil, fmt.Errorf("unexpected file %s in export", h.Name)
+		}
+		if _, ok := files[h.Name]; ok {
+			return nil, fmt.Errorf("file %s appears twice in export", h.Name)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", h.Name, err)
+		}
+		files[h.Name] = data
+	}
+	for _, name := range []string{ExportManifestFile, ExportDataFile, ExportProofFile} {
+		if _, ok := files[name]; !ok {
+			return nil, fmt.Errorf("export has no %s", name)
+		}
+	}
+
+	var m ExportManifest
+	if err := json.Unmarshal(files[ExportManifestFile], &m); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %w", err)
+	}
+	if m.Version != ExportVersion {
+		return nil, fmt.Errorf("unsupported export version %d", m.Version)
+	}
+	root, err := ParseRoot(m.Root)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(root, rootHash) {
+		return nil, fmt.Errorf("export is of another root = <%s>", m.Root)
+	}
+	start, err := hex.DecodeString(m.Prefix)
+	if err != nil {
+		return nil, fmt.Errorf("invalid prefix in manifest: %w", err)
+	}
+	// The manifest is written by the producer, so a narrower prefix would pass as a complete export
+	if !bytes.Equal(start, prefix) {
+		return nil, fmt.Errorf("export is of another prefix = <%s>", m.Prefix)
+	}
+
+	var proof MultiProof
+	if err := proof.UnmarshalBinary(files[ExportProofFile]); err != nil {
+		return nil, err
+	}
+	pairs, err := VerifyRange(hs, rootHash, start, PrefixEnd(start), proof)
+	if err != nil {
+		return nil, err
+	}
+	if len(pairs) != m.Count {
+		return nil, fmt.Errorf("manifest counts %d pairs, proof holds %d", m.Count, len(pairs))
+	}
+
+	sc := bufio.NewScanner(bytes.NewReader(files[ExportDataFile]))
+	sc.Buffer(nil, len(files[ExportDataFile])+1)
+	i := 0
+	for ; sc.Scan(); i++ {
+		var p ExportPair
+		if err := json.Unmarshal(sc.Bytes(), &p); err != nil {
+			return nil, fmt.Errorf("invalid pair #%d: %w", i, err)
+		}
+		if i >= len(pairs) || p.Key != hex.EncodeToString(pairs[i].Key) || p.Value != hex.EncodeToString(pairs[i].Value) {
+			return nil, fmt.Errorf("pair #%d does not match the proof", i)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if i != len(pairs) {
+		return nil, fmt.Errorf("data holds %d pairs, proof holds %d", i, len(pairs))
+	}
+	return pairs, nil
+}
@@ -0,0 +1,448 @@
+package mptproof
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math/bits"
+	"sort"
+	"strings"
+
+	"github.com/example/service/crypto"
+)
+
+// KV is a key/value pair proven by a MultiProof.
+type KV struct {
+	Key   []byte
+	Value []byte
+}
+
+// MultiNode is one node of a MultiProof.
+// Branch nodes set bit i of Bitmap for each present child i and bit i of Expanded for each child
+// given as a following node. Hashes holds the hashes of the other present children in index order.
+// Extension nodes have a zero Bitmap and carry their key and value. Their next node either follows
+// or is given by NextHash.
+type MultiNode struct {
+	Bitmap      uint16
+	Expanded    uint16
+	Hashes      [][]byte
+	Key         string
+	Value       []byte
+	HasValue    bool
+	NextFollows bool
+	NextHash    []byte
+}
+
+func (n MultiNode) IsBranch() bool {
+	return n.Bitmap != 0
+}
+
+// MultiProof holds the nodes on the paths of several keys in pre-order from the root branch,
+// so the ancestors the keys share appear only once.
+type MultiProof []MultiNode
+
+// OutsideRange reports whether no key with the hex prefix can be in [start, end) of hex keys.
+// An empty end leaves the range unbounded.
+func OutsideRange(prefix, start, end string) bool {
+	if prefix < start && !strings.HasPrefix(start, prefix) {
+		return true
+	}
+	return end != "" && prefix >= end
+}
+
+func inRange(ek, start, end string) bool {
+	return ek >= start && (end == "" || ek < end)
+}
+
+type multiProofVerifier struct {
+	hs     crypto.Hash
+	proof  MultiProof
+	values map[string][]byte
+	// Prefixes of the subtrees given by their hash only
+	opaque []string
+}
+
+// multiProofSlot is a node the proof has yet to give, under the hex prefix.
+// A nonzero first is the branch index the node must be an extension at.
+type multiProofSlot struct {
+	prefix string
+	branch bool
+	first  byte
+}
+
+// walk checks the shape of the proof and collects its values and opaque subtrees.
+// It keeps the nodes still to come on a stack instead of recursing, so a long chain of nodes
+// costs heap and not goroutine stack.
+func (v *multiProofVerifier) walk() error {
+	slots := []multiProofSlot{{branch: true}}
+	for i, node := range v.proof {
+		if len(slots) == 0 {
+			return fmt.Errorf("multi proof has %d trailing nodes", len(v.proof)-i)
+		}
+		slot := slots[len(slots)-1]
+		slots = slots[:len(slots)-1]
+
+		if node.IsBranch() {
+			if !slot.branch && slot.first != 0 {
+				return fmt.Errorf("node #%d must be an extension", i)
+			}
+			if node.Expanded&^node.Bitmap != 0 {
+				return fmt.Errorf("branch #%d expands absent children", i)
+			}
+			if len(node.Hashes) != bits.OnesCount16(node.Bitmap&^node.Expanded) {
+				return fmt.Errorf("bitmap of branch #%d does not match %d hashes", i, len(node.Hashes))
+			}
+			// Children are pushed in reverse, so they are popped in index order
+			for c := ChildCount - 1; c >= 0; c-- {
+				bit := uint16(1) << uint(c)
+				switch {
+				case node.Expanded&bit != 0:
+					slots = append(slots, multiProofSlot{prefix: slot.prefix, first: "0123456789abcdef"[c]})
+				case node.Bitmap&bit != 0:
+					v.opaque = append(v.opaque, slot.prefix+"0123456789abcdef"[c:c+1])
+				}
+			}
+			continue
+		}
+
+		if slot.branch {
+			return fmt.Errorf("node #%d must be a branch", i)
+		}
+		if node.Key == "" {
+			return fmt.Errorf("node #%d must be an extension", i)
+		}
+		if slot.first != 0 && node.Key[0] != slot.first {
+			return fmt.Errorf("extension #%d is not under its branch index", i)
+		}
+		ek := slot.prefix + node.Key
+		if node.HasValue {
+			v.values[ek] = node.Value
+		}
+		if node.NextHash != nil {
+			if node.NextFollows {
+				return fmt.Errorf("extension #%d has both a next hash and a following node", i)
+			}
+			v.opaque = append(v.opaque, ek)
+		}
+		if node.NextFollows {
+			slots = append(slots, multiProofSlot{prefix: ek})
+		}
+	}
+	if len(slots) != 0 {
+		return fmt.Errorf("multi proof ends unexpectedly")
+	}
+	return nil
+}
+
+// rootHash hashes the nodes of a proof accepted by walk from the last one back to the root.
+// Every node follows its expanded children, so their hashes are on top of the stack when it is reached.
+func (v *multiProofVerifier) rootHash() ([]byte, error) {
+	var hashes [][]byte
+	pop := func() []byte {
+		h := hashes[len(hashes)-1]
+		hashes = hashes[:len(hashes)-1]
+		return h
+	}
+	for i := len(v.proof) - 1; i >= 0; i-- {
+		node := v.proof[i]
+		var blob []byte
+		var err error
+		if node.IsBranch() {
+			children := make([][]byte, ChildCount)
+			next := 0
+			for c := range children {
+				bit := uint16(1) << uint(c)
+				switch {
+				case node.Expanded&bit != 0:
+					children[c] = pop()
+				case node.Bitmap&bit != 0:
+					children[c] = node.Hashes[next]
+					next++
+				}
+			}
+			blob, err = EncodeBranch(children)
+		} else {
+			nextHash := node.NextHash
+			if node.NextFollows {
+				nextHash = pop()
+			}
+			blob, err = EncodeExtension(node.Key, nextHash, node.Value, node.HasValue)
+		}
+		if err != nil {
+			return nil, err
+		}
+		h, err := v.hs.Hash(blob)
+		if err != nil {
+			return nil, err
+		}
+		hashes = append(hashes, h)
+	}
+	return pop(), nil
+}
+
+// verifyMultiProof recomputes the root hash from proof and checks it against root.
+func verifyMultiProof(hs crypto.Hash, root []byte, proof MultiProof) (*multiProofVerifier, error) {
+	v := &multiProofVerifier{hs: hs, proof: proof, values: make(map[string][]byte)}
+	if err := v.walk(); err != nil {
+		return nil, err
+	}
+	h, err := v.rootHash()
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(root, h) {
+		return nil, fmt.Errorf("root hash does not match")
+	}
+	return v, nil
+}
+
+// VerifyKeys recomputes the root hash from proof and checks that every pair is stored under root.
+// Values are the values as committed, i.e. after the value transforms of the trie.
+func VerifyKeys(hs crypto.Hash, root []byte, pairs []KV, proof MultiProof) error {
+	v, err := verifyMultiProof(hs, root, proof)
+	if err != nil {
+		return err
+	}
+	for _, kv := range pairs {
+		value, ok := v.values[hex.EncodeToString(kv.Key)]
+		if !ok {
+			return fmt.Errorf("key = <%x> is not covered by the multi proof", kv.Key)
+		}
+		if !bytes.Equal(value, kv.Value) {
+			return fmt.Errorf("value of key = <%x> does not match the multi proof", kv.Key)
+		}
+	}
+	return nil
+}
+
+// A range proof is a MultiProof which expands every node that may hold keys in [start, end)
+// and gives every other subtree by its hash. The verifier checks that each subtree given by hash
+// lies outside the range, so the values in the proof are all the values of the range.
+
+// VerifyRange checks that proof holds every key in [start, end) under root and returns those keys
+// in order with their values as committed, i.e. after the value transforms of the trie.
+// A nil end leaves the range unbounded. A trie without keys is proven by an empty proof.
+func VerifyRange(hs crypto.Hash, root, start, end []byte, proof MultiProof) ([]KV, error) {
+	if end != nil && bytes.Compare(start, end) >= 0 {
+		return nil, fmt.Errorf("start of range must be less than its end")
+	}
+	if len(proof) == 0 {
+		h, err := EmptyRoot(hs)
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(root, h) {
+			return nil, fmt.Errorf("root hash is not the one of an empty trie")
+		}
+		return nil, nil
+	}
+
+	v, err := verifyMultiProof(hs, root, proof)
+	if err != nil {
+		return nil, err
+	}
+	es, ee := hex.EncodeToString(start), hex.EncodeToString(end)
+	for _, prefix := range v.opaque {
+		if !OutsideRange(prefix, es, ee) {
+			return nil, fmt.Errorf("subtree = <%s> in the range is not expanded", prefix)
+		}
+	}
+	var eks []string
+	for ek := range v.values {
+		if inRange(ek, es, ee) {
+			eks = append(eks, ek)
+		}
+	}
+	sort.Strings(eks)
+	pairs := make([]KV, len(eks))
+	for i, ek := range eks {
+		key, err := hex.DecodeString(ek)
+		if err != nil {
+			return nil, err
+		}
+		pairs[i] = KV{Key: key, Value: v.values[ek]}
+	}
+	return pairs, nil
+}
+
+// PrefixEnd returns the smallest key greater than every key with prefix, or nil if there is none.
+func PrefixEnd(prefix []byte) []byte {
+	end := append([]byte(nil), prefix...)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	return nil
+}
+
+const multiProofVersion = 1
+
+const (
+	multiProofFlagValue = 1 << iota
+	multiProofFlagNextFollows
+	multiProofFlagNextHash
+)
+
+// MarshalBinary encodes the proof as a version byte, a 1-byte hash size, the uvarint number of nodes and the nodes.
+// A node starts with its 2-byte big-endian bitmap. Branches continue with the 2-byte expanded bitmap and their hashes.
+// Extensions continue with the uvarint-prefixed key, a flags byte, the uvarint-prefixed value if flagged
+// and the next hash if flagged.
+func (mp MultiProof) MarshalBinary() ([]byte, error) {
+	hashSize := 0
+	checkSize := func(h []byte) error {
+		if hashSize == 0 {
+			hashSize = len(h)
+		}
+		if len(h) == 0 || len(h) != hashSize || hashSize > 255 {
+			return fmt.Errorf("invalid hash size %d in multi proof", len(h))
+		}
+		return nil
+	}
+
+	body := binary.AppendUvarint(nil, uint64(len(mp)))
+	for i, n := range mp {
+		body = binary.BigEndian.AppendUint16(body, n.Bitmap)
+		if n.IsBranch() {
+			if len(n.Hashes) != bits.OnesCount16(n.Bitmap&^n.Expanded) {
+				return nil, fmt.Errorf("bitmap of branch #%d does not match %d hashes", i, len(n.Hashes))
+			}
+			body = binary.BigEndian.AppendUint16(body, n.Expanded)
+			for _, h := range n.Hashes {
+				if err := checkSize(h); err != nil {
+					return nil, err
+				}
+				body = append(body, h...)
+			}
+			continue
+		}
+
+		body = binary.AppendUvarint(body, uint64(len(n.Key)))
+		body = append(body, n.Key...)
+		var flags byte
+		if n.HasValue {
+			flags |= multiProofFlagValue
+		}
+		if n.NextFollows {
+			flags |= multiProofFlagNextFollows
+		}
+		if n.NextHash != nil {
+			flags |= multiProofFlagNextHash
+		}
+		body = append(body, flags)
+		if n.HasValue {
+			body = binary.AppendUvarint(body, uint64(len(n.Value)))
+			body = append(body, n.Value...)
+		}
+		if n.NextHash != nil {
+			if err := checkSize(n.NextHash); err != nil {
+				return nil, err
+			}
+			body = append(body, n.NextHash...)
+		}
+	}
+	return append([]byte{multiProofVersion, byte(hashSize)}, body...), nil
+}
+
+func readProofBytes(r *bytes.Reader, n int) ([]byte, error) {
+	if n > r.Len() {
+		return nil, fmt.Errorf("multi proof ends unexpectedly")
+	}
+	b := make([]byte, n)
+	r.Read(b)
+	return b, nil
+}
+
+func (mp *MultiProof) UnmarshalBinary(data []byte) error {
+	if len(data) < 2 {
+		return fmt.Errorf("multi proof is too short")
+	}
+	if data[0] != multiProofVersion {
+		return fmt.Errorf("unsupported multi proof version %d", data[0])
+	}
+	hashSize := int(data[1])
+	r := bytes.NewReader(data[2:])
+	count, err := binary.ReadUvarint(r)
+	if err != nil {
+		return fmt.Errorf("failed to read the number of nodes: %w", err)
+	}
+	// Every node takes at least 4 bytes
+	if count > uint64(r.Len()/4) {
+		return fmt.Errorf("multi proof claims %d nodes in %d bytes", count, r.Len())
+	}
+	readHash := func() ([]byte, error) {
+		if hashSize == 0 {
+			return nil, fmt.Errorf("multi proof without hash size holds a hash")
+		}
+		return readProofBytes(r, hashSize)
+	}
+
+	proof := make(MultiProof, count)
+	for i := range proof {
+		head, err := readProofBytes(r, 2)
+		if err != nil {
+			return err
+		}
+		n := MultiNode{Bitmap: binary.BigEndian.Uint16(head)}
+		if n.IsBranch() {
+			expanded, err := readProofBytes(r, 2)
+			if err != nil {
+				return err
+			}
+			n.Expanded = binary.BigEndian.Uint16(expanded)
+			if n.Expanded&^n.Bitmap != 0 {
+				return fmt.Errorf("branch #%d expands absent children", i)
+			}
+			for k := bits.OnesCount16(n.Bitmap &^ n.Expanded); k > 0; k-- {
+				h, err := readHash()
+				if err != nil {
+					return err
+				}
+				n.Hashes = append(n.Hashes, h)
+			}
+			proof[i] = n
+			continue
+		}
+
+		keyLen, err := binary.ReadUvarint(r)
+		if err != nil || keyLen == 0 || keyLen > uint64(r.Len()) {
+			return fmt.Errorf("invalid key of extension #%d", i)
+		}
+		key, _ := readProofBytes(r, int(keyLen))
+		for _, c := range key {
+			if _, err := NibbleIndex(c); err != nil {
+				return fmt.Errorf("invalid key of extension #%d: %w", i, err)
+			}
+		}
+		n.Key = string(key)
+		flags, err := r.ReadByte()
+		if err != nil {
+			return fmt.Errorf("multi proof ends unexpectedly")
+		}
+		if flags&^(multiProofFlagValue|multiProofFlagNextFollows|multiProofFlagNextHash) != 0 {
+			return fmt.Errorf("unknown flags %02x of extension #%d", flags, i)
+		}
+		if flags&multiProofFlagValue != 0 {
+			valueLen, err := binary.ReadUvarint(r)
+			if err != nil || valueLen > uint64(r.Len()) {
+				return fmt.Errorf("invalid value of extension #%d", i)
+			}
+			n.Value, _ = readProofBytes(r, int(valueLen))
+			n.HasValue = true
+		}
+		n.NextFollows = flags&multiProofFlagNextFollows != 0
+		if flags&multiProofFlagNextHash != 0 {
+			if n.NextHash, err = readHash(); err != nil {
+				return err
+			}
+		}
+		proof[i] = n
+	}
+	if r.Len() != 0 {
+		return fmt.Errorf("multi proof has %d trailing bytes", r.Len())
+	}
+	*mp = proof
+	return nil
+}
@@ -0,0 +1,107 @@
+package mptproof
+
+import (
+	"fmt"
+
+	"github.com/example/infra/db/merkle_patricia_trie/internal/ssz"
+)
+
+// The SSZ encoding of a MultiProof follows the schema
+//
+//	class Node(Container):
+//	    bitmap: uint16
+//	    expanded: uint16
+//	    hashes: List[ByteList[255], 16]
+//	    key: ByteList[MAX_KEY]     # ASCII hex nibbles
+//	    value: ByteList[MAX_VALUE]
+//	    has_value: boolean
+//	    next_follows: boolean
+//	    next_hash: ByteList[255]   # empty if none
+//
+//	MultiProof = List[Node, MAX_NODES]
+//
+// with the fields of the binary encoding.
+
+// sszMaxMultiProofNodes is the limit of the SSZ list of nodes.
+const sszMaxMultiProofNodes = 1 << 20
+
+func (mp MultiProof) MarshalSSZ() ([]byte, error) {
+	// The binary encoding checks the nodes
+	if _, err := mp.MarshalBinary(); err != nil {
+		return nil, err
+	}
+	if len(mp) > sszMaxMultiProofNodes {
+		return nil, fmt.Errorf("multi proof of %d nodes exceeds %d", len(mp), sszMaxMultiProofNodes)
+	}
+	nodes := make([][]byte, len(mp))
+	for i, n := range mp {
+		nodes[i] = ssz.Container(
+			ssz.Fixed(ssz.Uint16(n.Bitmap)),
+			ssz.Fixed(ssz.Uint16(n.Expanded)),
+			ssz.Variable(ssz.List(n.Hashes)),
+			ssz.Variable([]byte(n.Key)),
+			ssz.Variable(n.Value),
+			ssz.Fixed(ssz.Bool(n.HasValue)),
+			ssz.Fixed(ssz.Bool(n.NextFollows)),
+			ssz.Variable(n.NextHash),
+		)
+	}
+	return ssz.List(nodes), nil
+}
+
+// UnmarshalSSZ decodes a multi proof and checks it like UnmarshalBinary.
+func (mp *MultiProof) UnmarshalSSZ(data []byte) error {
+	nodes, err := ssz.SplitList(data, sszMaxMultiProofNodes)
+	if err != nil {
+		return err
+	}
+	proof := make(MultiProof, len(nodes))
+	for i, b := range nodes {
+		fields, err := ssz.SplitContainer(b, 2, 2, -1, -1, -1, 1, 1, -1)
+		if err != nil {
+			return fmt.Errorf("invalid node #%d: %w", i, err)
+		}
+		n := MultiNode{Bitmap: ssz.ReadUint16(fields[0]), Expanded: ssz.ReadUint16(fields[1]), Key: string(fields[3])}
+		hashes, err := ssz.SplitList(fields[2], ChildCount)
+		if err != nil {
+			return fmt.Errorf("invalid hashes of node #%d: %w", i, err)
+		}
+		for _, h := range hashes {
+			n.Hashes = append(n.Hashes, append([]byte(nil), h...))
+		}
+		hasValue, err := ssz.ReadBool(fields[5])
+		if err != nil {
+			return fmt.Errorf("invalid node #%d: %w", i, err)
+		}
+		if hasValue {
+			n.Value, n.HasValue = append([]byte{}, fields[4]...), true
+		} else if len(fields[4]) > 0 {
+			return fmt.Errorf("node #%d without value carries %d value bytes", i, len(fields[4]))
+		}
+		if n.NextFollows, err = ssz.ReadBool(fields[6]); err != nil {
+			return fmt.Errorf("invalid node #%d: %w", i, err)
+		}
+		if len(fields[7]) > 0 {
+			n.NextHash = append([]byte(nil), fields[7]...)
+		}
+		if n.IsBranch() && (n.Key != "" || hasValue || n.NextFollows || n.NextHash != nil) {
+			return fmt.Errorf("branch #%d must not carry extension fields", i)
+		}
+		if !n.IsBranch() && (n.Expanded != 0 || len(n.Hashes) > 0) {
+			return fmt.Errorf("extension #%d must not carry branch fields", i)
+		}
+		proof[i] = n
+	}
+
+	// The binary encoding holds the same fields, so its checks apply
+	b, err := proof.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	var checked MultiProof
+	if err := checked.UnmarshalBinary(b); err != nil {
+		return err
+	}
+	*mp = proof
+	return nil
+}
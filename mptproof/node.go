@@ -0,0 +1,247 @@
+// Package mptproof verifies proofs of the merkle patricia trie.
+// It depends only on the hash function, so clients can verify proofs without importing the trie.
+// The node encodings here are the ones the trie hashes its nodes with.
+package mptproof
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+
+	"github.com/example/service/crypto"
+)
+
+// Nodes are encoded as a sequence of gob messages of basic types, one per part, so other implementations
+// can reproduce the node hashes without gob itself. Each part is uint(n) followed by n bytes holding
+// the type id (0x0c for a string, 0x0a for a byte slice), 0x00, uint(len(data)) and data.
+// uint(x) is one byte for x < 128 and otherwise the negated count of bytes of x followed by x in big-endian.
+// An extension is the string "E" and its key, then either the string "C" and the next hash as bytes or the bytes "NC",
+// then either the string "V" and the value as bytes or the string "NV".
+// A branch is the string "B" and, for each of its ChildCount slots, either the string "C" and the child hash as bytes
+// or the string "NC".
+
+// ChildCount is the number of child slots of a branch node.
+const ChildCount = 16
+
+// EncodeExtension encodes an extension node from its parts.
+// next is nil if the node has no next node. value is ignored unless hasValue is set.
+func EncodeExtension(key string, next []byte, value []byte, hasValue bool) ([]byte, error) {
+	w := new(bytes.Buffer)
+	encoder := gob.NewEncoder(w)
+	if err := encoder.Encode("E"); err != nil {
+		return nil, err
+	}
+	if err := encoder.Encode(key); err != nil {
+		return nil, err
+	}
+	if next != nil {
+		if err := encoder.Encode("C"); err != nil {
+			return nil, err
+		}
+		if err := encoder.Encode(next); err != nil {
+			return nil, err
+		}
+	} else {
+		if err := encoder.Encode([]byte("NC")); err != nil {
+			return nil, err
+		}
+	}
+	if hasValue {
+		if err := encoder.Encode("V"); err != nil {
+			return nil, err
+		}
+		if err := encoder.Encode(value); err != nil {
+			return nil, err
+		}
+	} else {
+		if err := encoder.Encode("NV"); err != nil {
+			return nil, err
+		}
+	}
+	return w.Bytes(), nil
+}
+
+// EncodeBranch encodes a branch node from the hashes of its ChildCount children, nil for no child.
+func EncodeBranch(children [][]byte) ([]byte, error) {
+	if len(children) != ChildCount {
+		return nil, fmt.Errorf("branch must have %d child slots, got %d", ChildCount, len(children))
+	}
+	w := new(bytes.Buffer)
+	encoder := gob.NewEncoder(w)
+	if err := encoder.Encode("B"); err != nil {
+		return nil, err
+	}
+	for _, h := range children {
+		if h != nil {
+			if err := encoder.Encode("C"); err != nil {
+				return nil, err
+			}
+			if err := encoder.Encode(h); err != nil {
+				return nil, err
+			}
+		} else {
+			if err := encoder.Encode("NC"); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return w.Bytes(), nil
+}
+
+// Node is a node decoded by DecodeNode. Extensions hold Key, Next, Value and HasValue,
+// and branches hold the hashes of their ChildCount Children, nil for no child.
+type Node struct {
+	Branch   bool
+	Key      string
+	Next     []byte
+	Value    []byte
+	HasValue bool
+	Children [][]byte
+}
+
+const (
+	gobString = 0x0c
+	gobBytes  = 0x0a
+)
+
+// readUint reads uint(x) of the gob encoding.
+func readUint(r *bytes.Reader) (uint64, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	if b < 0x80 {
+		return uint64(b), nil
+	}
+	n := int(-int8(b))
+	if n > 8 {
+		return 0, fmt.Errorf("uint of %d bytes", n)
+	}
+	var x uint64
+	for i := 0; i < n; i++ {
+		c, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		x = x<<8 | uint64(c)
+	}
+	return x, nil
+}
+
+// readPart reads one part of a node encoding and returns its type id and data.
+func readPart(r *bytes.Reader) (byte, []byte, error) {
+	n, err := readUint(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	if n < 3 || n > uint64(r.Len()) {
+		return 0, nil, fmt.Errorf("part of %d bytes in %d bytes", n, r.Len())
+	}
+	part := make([]byte, n)
+	if _, err := r.Read(part); err != nil {
+		return 0, nil, err
+	}
+	pr := bytes.NewReader(part[2:])
+	if part[1] != 0 {
+		return 0, nil, fmt.Errorf("part is not a single value")
+	}
+	size, err := readUint(pr)
+	if err != nil {
+		return 0, nil, err
+	}
+	if size != uint64(pr.Len()) {
+		return 0, nil, fmt.Errorf("part holds %d bytes, want %d", pr.Len(), size)
+	}
+	return part[0], part[len(part)-pr.Len():], nil
+}
+
+// DecodeNode decodes a node encoded by EncodeExtension or EncodeBranch.
+// It rejects blobs which do not follow the layout of the encoders.
+func DecodeNode(blob []byte) (Node, error) {
+	r := bytes.NewReader(blob)
+	expect := func(typ byte) ([]byte, error) {
+		t, data, err := readPart(r)
+		if err != nil {
+			return nil, fmt.Errorf("invalid node encoding: %w", err)
+		}
+		if t != typ {
+			return nil, fmt.Errorf("invalid node encoding: part of type %02x, want %02x", t, typ)
+		}
+		return data, nil
+	}
+	marker := func(typ byte, choices ...string) (string, error) {
+		data, err := expect(typ)
+		if err != nil {
+			return "", err
+		}
+		for _, c := range choices {
+			if string(data) == c {
+				return c, nil
+			}
+		}
+		return "", fmt.Errorf("invalid node encoding: unexpected marker <%s>", data)
+	}
+
+	kind, err := marker(gobString, "E", "B")
+	if err != nil {
+		return Node{}, err
+	}
+	var n Node
+	if kind == "B" {
+		n.Branch = true
+		n.Children = make([][]byte, ChildCount)
+		for i := range n.Children {
+			c, err := marker(gobString, "C", "NC")
+			if err != nil {
+				return Node{}, err
+			}
+			if c == "C" {
+				if n.Children[i], err = expect(gobBytes); err != nil {
+					return Node{}, err
+				}
+			}
+		}
+	} else {
+		key, err := expect(gobString)
+		if err != nil {
+			return Node{}, err
+		}
+		n.Key = string(key)
+		t, data, err := readPart(r)
+		if err != nil {
+			return Node{}, fmt.Errorf("invalid node encoding: %w", err)
+		}
+		switch {
+		case t == gobString && string(data) == "C":
+			if n.Next, err = expect(gobBytes); err != nil {
+				return Node{}, err
+			}
+		case t == gobBytes && string(data) == "NC":
+		default:
+			return Node{}, fmt.Errorf("invalid node encoding: unexpected marker <%s>", data)
+		}
+		v, err := marker(gobString, "V", "NV")
+		if err != nil {
+			return Node{}, err
+		}
+		if v == "V" {
+			if n.Value, err = expect(gobBytes); err != nil {
+				return Node{}, err
+			}
+			n.HasValue = true
+		}
+	}
+	if r.Len() != 0 {
+		return Node{}, fmt.Errorf("invalid node encoding: %d trailing bytes", r.Len())
+	}
+	return n, nil
+}
+
+// EmptyRoot returns the root hash of a trie without keys.
+func EmptyRoot(hs crypto.Hash) ([]byte, error) {
+	blob, err := EncodeBranch(make([][]byte, ChildCount))
+	if err != nil {
+		return nil, err
+	}
+	return hs.Hash(blob)
+}
@@ -0,0 +1,378 @@
+package mptproof
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/bits"
+	"strings"
+)
+
+// Set is one level of a Path.
+// Branch levels set bit i of Bitmap for each present child i and hold only the present child hashes in index order.
+// Extension and root levels have a zero Bitmap and hold the node hash.
+type Set struct {
+	Bitmap uint16
+	Hashes [][]byte
+	// Extension levels also carry the body of the node, so a verifier can recompute its hash.
+	// Next is set only on the first level; the next node of an upper level is the level below it.
+	Key      string
+	Value    []byte
+	HasValue bool
+	Next     []byte
+}
+
+func (s Set) IsBranch() bool {
+	return s.Bitmap != 0
+}
+
+// Path is a proof from its first level up to the root.
+type Path []Set
+
+// NibbleIndex returns the index of the hex nibble c, the child index of a branch.
+func NibbleIndex(c byte) (int, error) {
+	index := strings.IndexByte("0123456789abcdef", c)
+	if index < 0 {
+		return 0, fmt.Errorf("invalid nibble '%c'", c)
+	}
+	return index, nil
+}
+
+func (s Set) MarshalJSON() ([]byte, error) {
+	bf := bytes.NewBufferString("{")
+	if s.IsBranch() {
+		bf.WriteString(fmt.Sprintf("\"bitmap\":\"%04x\",", s.Bitmap))
+	}
+	bf.WriteString("\"hashes\":[")
+	for setIndex, h := range s.Hashes {
+		if setIndex > 0 {
+			bf.WriteByte(',')
+		}
+		bf.WriteByte('"')
+		bf.WriteString(hex.EncodeToString(h))
+		bf.WriteByte('"')
+	}
+	bf.WriteByte(']')
+	if s.Key != "" {
+		bf.WriteString(",\"key\":\"" + s.Key + "\"")
+	}
+	if s.HasValue {
+		bf.WriteString(",\"value\":\"" + hex.EncodeToString(s.Value) + "\"")
+	}
+	if s.Next != nil {
+		bf.WriteString(",\"next\":\"" + hex.EncodeToString(s.Next) + "\"")
+	}
+	bf.WriteByte('}')
+	return bf.Bytes(), nil
+}
+
+type setJSON struct {
+	Bitmap *string  `json:"bitmap"`
+	Hashes []string `json:"hashes"`
+	Key    *string  `json:"key"`
+	Value  *string  `json:"value"`
+	Next   *string  `json:"next"`
+}
+
+func decodeHashHex(s string) ([]byte, error) {
+	h, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid hash = <%s>: %w", s, err)
+	}
+	if len(h) == 0 {
+		return nil, fmt.Errorf("empty hash")
+	}
+	return h, nil
+}
+
+// UnmarshalJSON parses the format of MarshalJSON and rejects sets which could not have been produced by it.
+func (s *Set) UnmarshalJSON(data []byte) error {
+	var j setJSON
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&j); err != nil {
+		return err
+	}
+	if len(j.Hashes) == 0 {
+		return fmt.Errorf("set has no hashes")
+	}
+
+	var set Set
+	for _, hh := range j.Hashes {
+		h, err := decodeHashHex(hh)
+		if err != nil {
+			return err
+		}
+		if len(set.Hashes) > 0 && len(h) != len(set.Hashes[0]) {
+			return fmt.Errorf("set mixes hash sizes %d and %d", len(set.Hashes[0]), len(h))
+		}
+		set.Hashes = append(set.Hashes, h)
+	}
+
+	if j.Bitmap != nil {
+		bitmap, err := hex.DecodeString(*j.Bitmap)
+		if err != nil || len(bitmap) != 2 {
+			return fmt.Errorf("invalid bitmap = <%s>", *j.Bitmap)
+		}
+		set.Bitmap = binary.BigEndian.Uint16(bitmap)
+		if set.Bitmap == 0 {
+			return fmt.Errorf("bitmap of a branch must not be empty")
+		}
+		if len(set.Hashes) != bits.OnesCount16(set.Bitmap) {
+			return fmt.Errorf("set bitmap does not match %d hashes", len(set.Hashes))
+		}
+		if j.Key != nil || j.Value != nil || j.Next != nil {
+			return fmt.Errorf("branch set must not carry a key, value or next hash")
+		}
+		*s = set
+		return nil
+	}
+
+	if len(set.Hashes) != 1 {
+		return fmt.Errorf("set without bitmap must have exactly 1 hash, got %d", len(set.Hashes))
+	}
+	if j.Key != nil {
+		for i := range *j.Key {
+			if _, err := NibbleIndex((*j.Key)[i]); err != nil {
+				return fmt.Errorf("invalid key = <%s>: %w", *j.Key, err)
+			}
+		}
+		set.Key = *j.Key
+	}
+	if j.Value != nil {
+		value, err := hex.DecodeString(*j.Value)
+		if err != nil {
+			return fmt.Errorf("invalid value = <%s>: %w", *j.Value, err)
+		}
+		set.Value, set.HasValue = value, true
+	}
+	if j.Next != nil {
+		next, err := decodeHashHex(*j.Next)
+		if err != nil {
+			return err
+		}
+		if len(next) != len(set.Hashes[0]) {
+			return fmt.Errorf("set mixes hash sizes %d and %d", len(set.Hashes[0]), len(next))
+		}
+		set.Next = next
+	}
+	if set.Key == "" && (set.HasValue || set.Next != nil) {
+		return fmt.Errorf("set with a value or next hash must have a key")
+	}
+	*s = set
+	return nil
+}
+
+const (
+	setFlagValue = 1 << iota
+	setFlagNext
+)
+
+// MarshalBinary encodes the set as the 2-byte big-endian bitmap, a 1-byte hash size and the hashes.
+// Non-branch levels continue with the uvarint-prefixed key, a flags byte,
+// the uvarint-prefixed value if flagged and the next hash if flagged.
+func (s Set) MarshalBinary() ([]byte, error) {
+	if len(s.Hashes) == 0 {
+		return nil, fmt.Errorf("set has no hashes")
+	}
+	if s.IsBranch() && len(s.Hashes) != bits.OnesCount16(s.Bitmap) {
+		return nil, fmt.Errorf("set bitmap does not match %d hashes", len(s.Hashes))
+	}
+	hashSize := len(s.Hashes[0])
+	if hashSize == 0 || hashSize > 255 {
+		return nil, fmt.Errorf("invalid hash size %d", hashSize)
+	}
+	bf := new(bytes.Buffer)
+	if err := binary.Write(bf, binary.BigEndian, s.Bitmap); err != nil {
+		return nil, err
+	}
+	bf.WriteByte(byte(hashSize))
+	for _, h := range s.Hashes {
+		if len(h) != hashSize {
+			return nil, fmt.Errorf("set mixes hash sizes %d and %d", hashSize, len(h))
+		}
+		bf.Write(h)
+	}
+	if s.IsBranch() {
+		return bf.Bytes(), nil
+	}
+
+	bf.Write(binary.AppendUvarint(nil, uint64(len(s.Key))))
+	bf.WriteString(s.Key)
+	var flags byte
+	if s.HasValue {
+		flags |= setFlagValue
+	}
+	if s.Next != nil {
+		flags |= setFlagNext
+	}
+	bf.WriteByte(flags)
+	if s.HasValue {
+		bf.Write(binary.AppendUvarint(nil, uint64(len(s.Value))))
+		bf.Write(s.Value)
+	}
+	if s.Next != nil {
+		if len(s.Next) != hashSize {
+			return nil, fmt.Errorf("set mixes hash sizes %d and %d", hashSize, len(s.Next))
+		}
+		bf.Write(s.Next)
+	}
+	return bf.Bytes(), nil
+}
+
+func readUvarintBytes(r *bytes.Reader) ([]byte, error) {
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	if n > uint64(r.Len()) {
+		return nil, fmt.Errorf("length %d exceeds the remaining %d bytes", n, r.Len())
+	}
+	b := make([]byte, n)
+	if _, err := r.Read(b); err != nil && n > 0 {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (s *Set) UnmarshalBinary(data []byte) error {
+	if len(data) < 3 {
+		return fmt.Errorf("set is too short")
+	}
+	bitmap := binary.BigEndian.Uint16(data)
+	hashSize := int(data[2])
+	if hashSize == 0 {
+		return fmt.Errorf("invalid hash size 0")
+	}
+	count := 1
+	if bitmap != 0 {
+		count = bits.OnesCount16(bitmap)
+	}
+	body := data[3:]
+	if len(body) < count*hashSize || (bitmap != 0 && len(body) != count*hashSize) {
+		return fmt.Errorf("set has %d bytes of hashes, want %d", len(body), count*hashSize)
+	}
+	hashes := make([][]byte, count)
+	for i := range hashes {
+		hashes[i] = append([]byte(nil), body[i*hashSize:(i+1)*hashSize]...)
+	}
+	set := Set{Bitmap: bitmap, Hashes: hashes}
+
+	if bitmap == 0 {
+		r := bytes.NewReader(body[count*hashSize:])
+		key, err := readUvarintBytes(r)
+		if err != nil {
+			return fmt.Errorf("failed to read set key: %w", err)
+		}
+		set.Key = string(key)
+		flags, err := r.ReadByte()
+		if err != nil {
+			return fmt.Errorf("failed to read set flags: %w", err)
+		}
+		if flags&^(setFlagValue|setFlagNext) != 0 {
+			return fmt.Errorf("unknown set flags %02x", flags)
+		}
+		if flags&setFlagValue != 0 {
+			value, err := readUvarintBytes(r)
+			if err != nil {
+				return fmt.Errorf("failed to read set value: %w", err)
+			}
+			set.Value, set.HasValue = value, true
+		}
+		if flags&setFlagNext != 0 {
+			if r.Len() < hashSize {
+				return fmt.Errorf("set next hash is too short")
+			}
+			set.Next = make([]byte, hashSize)
+			if _, err := r.Read(set.Next); err != nil {
+				return err
+			}
+		}
+		if r.Len() != 0 {
+			return fmt.Errorf("set has %d trailing bytes", r.Len())
+		}
+	}
+
+	*s = set
+	return nil
+}
+
+func (p Path) MarshalJSON() ([]byte, error) {
+	bf := bytes.NewBufferString("[")
+	for i, s := range p {
+		if i > 0 {
+			bf.WriteByte(',')
+		}
+		j, err := s.MarshalJSON()
+		if err != nil {
+			return nil, err
+		}
+		bf.Write(j)
+	}
+	bf.WriteByte(']')
+	return bf.Bytes(), nil
+}
+
+func (p *Path) UnmarshalJSON(data []byte) error {
+	var sets []Set
+	if err := json.Unmarshal(data, &sets); err != nil {
+		return err
+	}
+	*p = sets
+	return nil
+}
+
+// PathVersion is the version byte of the binary encoding of a Path.
+const PathVersion = 1
+
+// MarshalBinary encodes the path as a version byte, the uvarint number of sets
+// and every set from the first level up prefixed with its uvarint length.
+func (p Path) MarshalBinary() ([]byte, error) {
+	bf := bytes.NewBuffer([]byte{PathVersion})
+	bf.Write(binary.AppendUvarint(nil, uint64(len(p))))
+	for i, s := range p {
+		data, err := s.MarshalBinary()
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode set #%d: %w", i, err)
+		}
+		bf.Write(binary.AppendUvarint(nil, uint64(len(data))))
+		bf.Write(data)
+	}
+	return bf.Bytes(), nil
+}
+
+func (p *Path) UnmarshalBinary(data []byte) error {
+	r := bytes.NewReader(data)
+	version, err := r.ReadByte()
+	if err != nil {
+		return fmt.Errorf("path is empty")
+	}
+	if version != PathVersion {
+		return fmt.Errorf("unsupported path version %d", version)
+	}
+	count, err := binary.ReadUvarint(r)
+	if err != nil {
+		return fmt.Errorf("failed to read the number of sets: %w", err)
+	}
+	// Every set takes at least 4 bytes, so a larger count cannot be satisfied
+	if count > uint64(r.Len()/4) {
+		return fmt.Errorf("path claims %d sets in %d bytes", count, r.Len())
+	}
+	path := make(Path, count)
+	for i := range path {
+		set, err := readUvarintBytes(r)
+		if err != nil {
+			return fmt.Errorf("failed to read set #%d: %w", i, err)
+		}
+		if err := path[i].UnmarshalBinary(set); err != nil {
+			return fmt.Errorf("failed to decode set #%d: %w", i, err)
+		}
+	}
+	if r.Len() != 0 {
+		return fmt.Errorf("path has %d trailing bytes", r.Len())
+	}
+	*p = path
+	return nil
+}
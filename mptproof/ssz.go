@@ -0,0 +1,128 @@
+package mptproof
+
+import (
+	"fmt"
+
+	"github.com/example/infra/db/merkle_patricia_trie/internal/ssz"
+)
+
+// The SSZ encoding of a Path, for consumers standardized on SimpleSerialize, follows the schema
+//
+//	class Set(Container):
+//	    bitmap: uint16
+//	    hashes: List[ByteList[255], 16]
+//	    key: ByteList[MAX_KEY]     # ASCII hex nibbles
+//	    value: ByteList[MAX_VALUE]
+//	    has_value: boolean
+//	    next: ByteList[255]        # empty if none
+//
+//	Path = List[Set, SSZMaxLevels]
+//
+// Only serialization is defined; proofs are still verified by rehashing the node encodings.
+
+// SSZMaxLevels is the limit of the SSZ list of sets.
+const SSZMaxLevels = 4096
+
+// splitHashList splits a List[ByteList[255], 16] of hashes.
+func splitHashList(b []byte) ([][]byte, error) {
+	hashes, err := ssz.SplitList(b, ChildCount)
+	if err != nil {
+		return nil, fmt.Errorf("invalid hashes: %w", err)
+	}
+	for i := range hashes {
+		if len(hashes[i]) == 0 || len(hashes[i]) > 255 {
+			return nil, fmt.Errorf("invalid hash size %d", len(hashes[i]))
+		}
+		hashes[i] = append([]byte(nil), hashes[i]...)
+	}
+	return hashes, nil
+}
+
+func (s Set) MarshalSSZ() ([]byte, error) {
+	if _, err := s.MarshalBinary(); err != nil {
+		return nil, err
+	}
+	return ssz.Container(
+		ssz.Fixed(ssz.Uint16(s.Bitmap)),
+		ssz.Variable(ssz.List(s.Hashes)),
+		ssz.Variable([]byte(s.Key)),
+		ssz.Variable(s.Value),
+		ssz.Fixed(ssz.Bool(s.HasValue)),
+		ssz.Variable(s.Next),
+	), nil
+}
+
+// UnmarshalSSZ decodes a set and checks it like UnmarshalBinary.
+func (s *Set) UnmarshalSSZ(data []byte) error {
+	fields, err := ssz.SplitContainer(data, 2, -1, -1, -1, 1, -1)
+	if err != nil {
+		return err
+	}
+	set := Set{Bitmap: ssz.ReadUint16(fields[0]), Key: string(fields[2])}
+	if set.Hashes, err = splitHashList(fields[1]); err != nil {
+		return err
+	}
+	if set.HasValue, err = ssz.ReadBool(fields[4]); err != nil {
+		return err
+	}
+	if set.HasValue {
+		set.Value = append([]byte{}, fields[3]...)
+	} else if len(fields[3]) > 0 {
+		return fmt.Errorf("set without value carries %d value bytes", len(fields[3]))
+	}
+	if len(fields[5]) > 0 {
+		set.Next = append([]byte(nil), fields[5]...)
+	}
+	for i := range set.Key {
+		if _, err := NibbleIndex(set.Key[i]); err != nil {
+			return fmt.Errorf("invalid key = <%s>: %w", set.Key, err)
+		}
+	}
+	// The binary encoding holds the same fields, so its checks apply
+	b, err := set.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	var checked Set
+	if err := checked.UnmarshalBinary(b); err != nil {
+		return err
+	}
+	if set.IsBranch() && (set.Key != "" || set.HasValue || set.Next != nil) {
+		return fmt.Errorf("branch set must not carry a key, value or next hash")
+	}
+	if set.Key == "" && (set.HasValue || set.Next != nil) {
+		return fmt.Errorf("set with a value or next hash must have a key")
+	}
+	*s = set
+	return nil
+}
+
+func (p Path) MarshalSSZ() ([]byte, error) {
+	if len(p) > SSZMaxLevels {
+		return nil, fmt.Errorf("path of %d sets exceeds %d", len(p), SSZMaxLevels)
+	}
+	sets := make([][]byte, len(p))
+	for i, s := range p {
+		b, err := s.MarshalSSZ()
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode set #%d: %w", i, err)
+		}
+		sets[i] = b
+	}
+	return ssz.List(sets), nil
+}
+
+func (p *Path) UnmarshalSSZ(data []byte) error {
+	sets, err := ssz.SplitList(data, SSZMaxLevels)
+	if err != nil {
+		return err
+	}
+	path := make(Path, len(sets))
+	for i, b := range sets {
+		if err := path[i].UnmarshalSSZ(b); err != nil {
+			return fmt.Errorf("failed to decode set #%d: %w", i, err)
+		}
+	}
+	*p = path
+	return nil
+}
@@ -0,0 +1,180 @@
+package mptproof
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+
+	"github.com/example/service/crypto"
+)
+
+// A trace renders a proof as the hash computations a circuit has to check, from the first level up to the root.
+// Each step hashes one node encoding; the output of a step appears in the input of the next one at ChildOffset,
+// and the output of the last step is the root. The committed value is the tail of the first input.
+
+// TraceLayout fixes the widths of a trace so a circuit can take it as fixed-size input.
+type TraceLayout struct {
+	// ChunkSize is the size of the input chunks in bytes.
+	ChunkSize int
+	// MaxChunks is the number of input chunks of every step. Inputs are zero padded to it.
+	MaxChunks int
+	// MaxSteps is the number of steps of an encoded trace. Traces are padded with zero steps to it.
+	MaxSteps int
+}
+
+type TraceStep struct {
+	// Chunks is the node encoding in ChunkSize-byte chunks, zero padded to MaxChunks chunks.
+	Chunks [][]byte
+	// Len is the length of the node encoding in bytes.
+	Len int
+	// Output is the hash of the node encoding.
+	Output []byte
+	// ChildOffset is the offset in the node encoding of the output of the step before, -1 for the first step.
+	ChildOffset int
+}
+
+type Trace struct {
+	Steps []TraceStep
+	// ValueOffset is the offset of the value in the input of the first step. The value ends the input.
+	ValueOffset int
+	ValueLen    int
+}
+
+// encodedLen returns the length of parts encoded like the parts of a node.
+func encodedLen(parts ...interface{}) (int, error) {
+	w := new(bytes.Buffer)
+	encoder := gob.NewEncoder(w)
+	for _, p := range parts {
+		if err := encoder.Encode(p); err != nil {
+			return 0, err
+		}
+	}
+	return w.Len(), nil
+}
+
+// childOffset returns the offset of the hash of child index in the encoding of a branch.
+func childOffset(children [][]byte, index int) (int, error) {
+	parts := []interface{}{"B"}
+	for _, h := range children[:index] {
+		if h != nil {
+			parts = append(parts, "C", h)
+		} else {
+			parts = append(parts, "NC")
+		}
+	}
+	n, err := encodedLen(append(parts, "C", children[index])...)
+	return n - len(children[index]), err
+}
+
+// TraceProof verifies that path proves value is stored at key under root and renders it as a trace.
+func TraceProof(hs crypto.Hash, layout TraceLayout, root, key, value []byte, path Path) (Trace, error) {
+	if layout.ChunkSize <= 0 || layout.MaxChunks <= 0 {
+		return Trace{}, fmt.Errorf("invalid trace layout %+v", layout)
+	}
+	if err := Verify(hs, root, key, value, path); err != nil {
+		return Trace{}, err
+	}
+
+	var trace Trace
+	var h []byte
+	for i, set := range path[:len(path)-1] {
+		var blob []byte
+		var err error
+		offset := -1
+		if set.IsBranch() {
+			children := make([][]byte, ChildCount)
+			next := 0
+			for c := range children {
+				if set.Bitmap&(1<<uint(c)) != 0 {
+					children[c] = set.Hashes[next]
+					next++
+				}
+			}
+			// Verify has checked the extension below is a child of the branch
+			var index int
+			if index, err = NibbleIndex(path[i-1].Key[0]); err != nil {
+				return Trace{}, err
+			}
+			if offset, err = childOffset(children, index); err != nil {
+				return Trace{}, err
+			}
+			blob, err = EncodeBranch(children)
+		} else {
+			next := h
+			if i == 0 {
+				next = set.Next
+			} else if offset, err = encodedLen("E", set.Key, "C", next); err == nil {
+				offset -= len(next)
+			}
+			if err == nil {
+				blob, err = EncodeExtension(set.Key, next, set.Value, set.HasValue)
+			}
+		}
+		if err != nil {
+			return Trace{}, err
+		}
+		if h, err = hs.Hash(blob); err != nil {
+			return Trace{}, fmt.Errorf("failed to hash level %d: %w", i, err)
+		}
+
+		chunks := (len(blob) + layout.ChunkSize - 1) / layout.ChunkSize
+		if chunks > layout.MaxChunks {
+			return Trace{}, fmt.Errorf("node at level %d takes %d chunks, max %d", i, chunks, layout.MaxChunks)
+		}
+		padded := make([]byte, layout.ChunkSize*layout.MaxChunks)
+		copy(padded, blob)
+		step := TraceStep{Len: len(blob), Output: h, ChildOffset: offset}
+		for c := 0; c < layout.MaxChunks; c++ {
+			step.Chunks = append(step.Chunks, padded[c*layout.ChunkSize:(c+1)*layout.ChunkSize])
+		}
+		if i == 0 {
+			trace.ValueOffset, trace.ValueLen = len(blob)-len(value), len(value)
+		}
+		trace.Steps = append(trace.Steps, step)
+	}
+	return trace, nil
+}
+
+// noChild is the encoded ChildOffset of the first step and of padding steps.
+const noChild = 0xffffffff
+
+// MarshalBinary encodes the trace with fixed-width fields: the uint32 number of steps, ValueOffset and ValueLen,
+// then MaxSteps steps of MaxChunks*ChunkSize input bytes, the uint32 Len, the output and the uint32 ChildOffset.
+// Padding steps are zero but for a ChildOffset of 0xffffffff. Integers are big-endian.
+func (t Trace) MarshalBinary(layout TraceLayout) ([]byte, error) {
+	if len(t.Steps) == 0 || len(t.Steps) > layout.MaxSteps {
+		return nil, fmt.Errorf("trace has %d steps, max %d", len(t.Steps), layout.MaxSteps)
+	}
+	hashSize := len(t.Steps[0].Output)
+	bf := new(bytes.Buffer)
+	for _, v := range []int{len(t.Steps), t.ValueOffset, t.ValueLen} {
+		bf.Write(binary.BigEndian.AppendUint32(nil, uint32(v)))
+	}
+	for i := 0; i < layout.MaxSteps; i++ {
+		step := TraceStep{Chunks: make([][]byte, layout.MaxChunks), Output: make([]byte, hashSize), ChildOffset: -1}
+		if i < len(t.Steps) {
+			step = t.Steps[i]
+		}
+		if len(step.Chunks) != layout.MaxChunks || len(step.Output) != hashSize {
+			return nil, fmt.Errorf("step %d does not fit the layout", i)
+		}
+		for _, c := range step.Chunks {
+			if c == nil {
+				c = make([]byte, layout.ChunkSize)
+			}
+			if len(c) != layout.ChunkSize {
+				return nil, fmt.Errorf("step %d does not fit the layout", i)
+			}
+			bf.Write(c)
+		}
+		bf.Write(binary.BigEndian.AppendUint32(nil, uint32(step.Len)))
+		bf.Write(step.Output)
+		offset := uint32(noChild)
+		if step.ChildOffset >= 0 {
+			offset = uint32(step.ChildOffset)
+		}
+		bf.Write(binary.BigEndian.AppendUint32(nil, offset))
+	}
+	return bf.Bytes(), nil
+}
@@ -0,0 +1,99 @@
+package mptproof_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"testing"
+
+	mpt "github.com/example/infra/db/merkle_patricia_trie"
+	"github.com/example/infra/db/merkle_patricia_trie/mptproof"
+)
+
+func TestTraceProof(t *testing.T) {
+	hs := hashService(t)
+
+	trie := mpt.NewMerklePatriciaTrie(hs)
+	for i := 0; i < 300; i++ {
+		if err := trie.Insert([]byte(fmt.Sprintf("key%d", i)), []byte(fmt.Sprintf("value%d", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Identical subtrees under a branch give it duplicate child hashes
+	for _, key := range []string{"dup\x10x", "dup\x20x"} {
+		if err := trie.Insert([]byte(key), []byte("same")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	root := trie.RootHash()
+	layout := mptproof.TraceLayout{ChunkSize: 32, MaxChunks: 24, MaxSteps: 16}
+
+	for _, key := range []string{"key7", "key123", "dup\x20x"} {
+		path, err := trie.FindMerklePath([]byte(key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		value := path.Value()
+		trace, err := mptproof.TraceProof(hs, layout, root, []byte(key), value, path.ProofPath())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		{
+			t.Log("Steps chain up to the root")
+			for i, step := range trace.Steps {
+				input := bytes.Join(step.Chunks, nil)
+				if len(input) != layout.ChunkSize*layout.MaxChunks {
+					t.Fatalf("Step %d is not padded to the layout", i)
+				}
+				h, err := hs.Hash(input[:step.Len])
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(h, step.Output) {
+					t.Errorf("Output of step %d is not the hash of its input", i)
+				}
+				if i == 0 {
+					if step.ChildOffset != -1 || !bytes.Equal(input[trace.ValueOffset:trace.ValueOffset+trace.ValueLen], value) || trace.ValueOffset+trace.ValueLen != step.Len {
+						t.Errorf("First step of key = <%s> must end with the value", key)
+					}
+					continue
+				}
+				prev := trace.Steps[i-1].Output
+				if !bytes.Equal(input[step.ChildOffset:step.ChildOffset+len(prev)], prev) {
+					t.Errorf("Step %d of key = <%s> does not hold the output of the step before at its ChildOffset", i, key)
+				}
+			}
+			if !bytes.Equal(trace.Steps[len(trace.Steps)-1].Output, root) {
+				t.Error("Last step must output the root")
+			}
+		}
+
+		{
+			t.Log("Encoded traces have a fixed size")
+			b, err := trace.MarshalBinary(layout)
+			if err != nil {
+				t.Fatal(err)
+			}
+			stepSize := layout.ChunkSize*layout.MaxChunks + 4 + len(root) + 4
+			if len(b) != 12+layout.MaxSteps*stepSize || int(binary.BigEndian.Uint32(b)) != len(trace.Steps) {
+				t.Errorf("Unexpected size %d of the encoded trace", len(b))
+			}
+		}
+	}
+
+	{
+		t.Log("Invalid proofs and oversized nodes are rejected")
+		path, err := trie.FindMerklePath([]byte("key7"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := mptproof.TraceProof(hs, layout, root, []byte("key7"), []byte("forged"), path.ProofPath()); err == nil {
+			t.Error("Trace of an invalid proof must fail")
+		}
+		small := mptproof.TraceLayout{ChunkSize: 32, MaxChunks: 2, MaxSteps: 16}
+		if _, err := mptproof.TraceProof(hs, small, root, []byte("key7"), path.Value(), path.ProofPath()); err == nil {
+			t.Error("Branch must not fit into 2 chunks")
+		}
+	}
+}
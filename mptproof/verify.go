@@ -0,0 +1,284 @@
+package mptproof
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/bits"
+	"strings"
+
+	"github.com/example/service/crypto"
+)
+
+// Key returns the key proven by the path, reassembled from the key fragments of its extension levels.
+func (p Path) Key() ([]byte, error) {
+	var ek strings.Builder
+	for i := len(p) - 1; i >= 0; i-- {
+		ek.WriteString(p[i].Key)
+	}
+	key, err := hex.DecodeString(ek.String())
+	if err != nil {
+		return nil, fmt.Errorf("invalid key fragments in path: %w", err)
+	}
+	return key, nil
+}
+
+// Value returns the committed value carried by the first level of the path, nil if none.
+func (p Path) Value() []byte {
+	if len(p) == 0 || !p[0].HasValue {
+		return nil
+	}
+	return p[0].Value
+}
+
+// SubtreeHash returns the hash of the subtree root proven by a subtree proof.
+func (p Path) SubtreeHash() []byte {
+	if len(p) == 0 || len(p[0].Hashes) != 1 {
+		return nil
+	}
+	return p[0].Hashes[0]
+}
+
+// ErrEmptyKey is returned for a zero-length key, which can be neither stored nor proven.
+var ErrEmptyKey = errors.New("length of key must be positive")
+
+type hashFunc func([]byte) ([]byte, error)
+
+// walk recomputes the node hashes of path from its first level up to the root
+// and returns the root hash together with the hex-encoded key the path leads to.
+// The first level is either an extension or, for absence proofs, a branch.
+func walk(hash hashFunc, path Path) ([]byte, string, error) {
+	// At least the root branch and the root
+	if len(path) < 2 {
+		return nil, "", fmt.Errorf("path is too short")
+	}
+
+	var h []byte
+	var childKey string
+	fragments := make([]string, 0, len(path))
+	for i, set := range path[:len(path)-1] {
+		if set.IsBranch() {
+			if i > 0 && path[i-1].IsBranch() {
+				return nil, "", fmt.Errorf("branch at level %d must be above an extension", i)
+			}
+			if len(set.Hashes) != bits.OnesCount16(set.Bitmap) {
+				return nil, "", fmt.Errorf("bitmap at level %d does not match %d hashes", i, len(set.Hashes))
+			}
+			children := make([][]byte, ChildCount)
+			next := 0
+			for c := range children {
+				if set.Bitmap&(1<<uint(c)) != 0 {
+					children[c] = set.Hashes[next]
+					next++
+				}
+			}
+			if i > 0 {
+				index, err := NibbleIndex(childKey[0])
+				if err != nil {
+					return nil, "", err
+				}
+				if !bytes.Equal(children[index], h) {
+					return nil, "", fmt.Errorf("branch at level %d does not contain the level below", i)
+				}
+			}
+			blob, err := EncodeBranch(children)
+			if err != nil {
+				return nil, "", err
+			}
+			if h, err = hash(blob); err != nil {
+				return nil, "", fmt.Errorf("failed to hash branch at level %d: %w", i, err)
+			}
+			continue
+		}
+
+		if set.Key == "" {
+			return nil, "", fmt.Errorf("extension at level %d has no key", i)
+		}
+		next := h
+		if i == 0 {
+			next = set.Next
+		} else if set.Next != nil {
+			return nil, "", fmt.Errorf("extension at level %d must not carry a next hash", i)
+		}
+		blob, err := EncodeExtension(set.Key, next, set.Value, set.HasValue)
+		if err != nil {
+			return nil, "", err
+		}
+		if h, err = hash(blob); err != nil {
+			return nil, "", fmt.Errorf("failed to hash extension at level %d: %w", i, err)
+		}
+		if len(set.Hashes) != 1 || !bytes.Equal(set.Hashes[0], h) {
+			return nil, "", fmt.Errorf("hash of extension at level %d does not match", i)
+		}
+		childKey = set.Key
+		fragments = append(fragments, set.Key)
+	}
+
+	if !path[len(path)-2].IsBranch() {
+		return nil, "", fmt.Errorf("path must end with the root branch")
+	}
+	root := path[len(path)-1]
+	if len(root.Hashes) != 1 || !bytes.Equal(root.Hashes[0], h) {
+		return nil, "", fmt.Errorf("root level of path does not match")
+	}
+
+	var ek strings.Builder
+	for i := len(fragments) - 1; i >= 0; i-- {
+		ek.WriteString(fragments[i])
+	}
+	return h, ek.String(), nil
+}
+
+// Verify recomputes the node hashes of path from the leaf up to the root
+// and checks that it proves value is stored at key under root.
+// value is the value as committed, i.e. after the value transforms of the trie.
+func Verify(hs crypto.Hash, root, key, value []byte, path Path) error {
+	return verify(hs.Hash, root, key, value, path)
+}
+
+func verify(hash hashFunc, root, key, value []byte, path Path) error {
+	if len(key) == 0 {
+		return ErrEmptyKey
+	}
+	if len(path) == 0 || path[0].IsBranch() || !path[0].HasValue || !bytes.Equal(path[0].Value, value) {
+		return fmt.Errorf("value does not match the path")
+	}
+	h, ek, err := walk(hash, path)
+	if err != nil {
+		return err
+	}
+	if ek != hex.EncodeToString(key) {
+		return fmt.Errorf("key does not match the path")
+	}
+	if !bytes.Equal(root, h) {
+		return fmt.Errorf("root hash does not match")
+	}
+	return nil
+}
+
+// VerifySubtree checks that path proves subtreeHash is the root of the subtree holding every key under prefix
+// and that it is committed under root.
+// The subtree root is the shallowest extension whose key reaches past the prefix.
+func VerifySubtree(hs crypto.Hash, root, prefix, subtreeHash []byte, path Path) error {
+	if len(prefix) == 0 {
+		return ErrEmptyKey
+	}
+	if len(path) == 0 || path[0].IsBranch() {
+		return fmt.Errorf("subtree proof must start with an extension")
+	}
+	h, ek, err := walk(hs.Hash, path)
+	if err != nil {
+		return err
+	}
+	ep := hex.EncodeToString(prefix)
+	if !strings.HasPrefix(ek, ep) {
+		return fmt.Errorf("subtree is not under the prefix")
+	}
+	// A deeper node would commit to only a part of the keys under the prefix
+	if len(ek)-len(path[0].Key) >= len(ep) {
+		return fmt.Errorf("subtree root is below the prefix")
+	}
+	if !bytes.Equal(path.SubtreeHash(), subtreeHash) {
+		return fmt.Errorf("subtree hash does not match")
+	}
+	if !bytes.Equal(root, h) {
+		return fmt.Errorf("root hash does not match")
+	}
+	return nil
+}
+
+// VerifyAbsence checks that path proves key is not stored under root.
+// The path starts at the node where the lookup of the key terminates: either a branch without a child
+// for the next nibble, or an extension which diverges from the key, ends before it without a next node,
+// or matches it without holding a value. A trie without keys is proven by its root level alone.
+func VerifyAbsence(hs crypto.Hash, root, key []byte, path Path) error {
+	if len(key) == 0 {
+		return ErrEmptyKey
+	}
+	if len(path) == 1 {
+		h, err := EmptyRoot(hs)
+		if err != nil {
+			return err
+		}
+		if len(path[0].Hashes) != 1 || !bytes.Equal(path[0].Hashes[0], h) || !bytes.Equal(root, h) {
+			return fmt.Errorf("root hash is not the one of an empty trie")
+		}
+		return nil
+	}
+
+	h, ek, err := walk(hs.Hash, path)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(root, h) {
+		return fmt.Errorf("root hash does not match")
+	}
+
+	target := hex.EncodeToString(key)
+	end := path[0]
+	if end.IsBranch() {
+		if len(target) <= len(ek) || !strings.HasPrefix(target, ek) {
+			return fmt.Errorf("key does not pass through the branch of the path")
+		}
+		index, err := NibbleIndex(target[len(ek)])
+		if err != nil {
+			return err
+		}
+		if end.Bitmap&(1<<uint(index)) != 0 {
+			return fmt.Errorf("branch of the path has a child for the key")
+		}
+		return nil
+	}
+
+	above := ek[:len(ek)-len(end.Key)]
+	// The key has to reach past the node above, whose own value would decide otherwise
+	if len(target) <= len(above) || !strings.HasPrefix(target, above) {
+		return fmt.Errorf("key does not pass through the extension of the path")
+	}
+	rest := target[len(above):]
+	// Below a branch, the first nibble of the extension is its index, so a key diverging there
+	// takes another child and only the branch can prove it absent
+	if path[1].IsBranch() && rest[0] != end.Key[0] {
+		return fmt.Errorf("key does not pass through the extension of the path")
+	}
+	switch {
+	case !strings.HasPrefix(rest, end.Key):
+		return nil
+	case rest == end.Key && !end.HasValue:
+		return nil
+	case len(rest) > len(end.Key) && end.Next == nil:
+		return nil
+	}
+	return fmt.Errorf("extension of the path does not exclude the key")
+}
+
+// Item is one proof checked by VerifyAll.
+type Item struct {
+	Key   []byte
+	Value []byte
+	Path  Path
+}
+
+// VerifyAll verifies every item against the same root.
+// Node hashes are memoized by encoding, so the upper nodes the proofs share are hashed once.
+func VerifyAll(hs crypto.Hash, root []byte, items []Item) error {
+	hashes := make(map[string][]byte)
+	hash := func(blob []byte) ([]byte, error) {
+		if h, ok := hashes[string(blob)]; ok {
+			return h, nil
+		}
+		h, err := hs.Hash(blob)
+		if err != nil {
+			return nil, err
+		}
+		hashes[string(blob)] = h
+		return h, nil
+	}
+	for i, item := range items {
+		if err := verify(hash, root, item.Key, item.Value, item.Path); err != nil {
+			return fmt.Errorf("proof #%d: %w", i, err)
+		}
+	}
+	return nil
+}
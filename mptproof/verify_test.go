@@ -0,0 +1,250 @@
+package mptproof_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/example/entity"
+	mpt "github.com/example/infra/db/merkle_patricia_trie"
+	"github.com/example/infra/db/merkle_patricia_trie/mptproof"
+	"github.com/example/service/crypto"
+	"github.com/example/service/crypto/sha256"
+)
+
+func hashService(t *testing.T) crypto.Hash {
+	sha256.NewSha256()
+	hs, err := crypto.GetHashService(entity.HashSha256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hs
+}
+
+func TestEncodeExtension(t *testing.T) {
+	blob, err := mptproof.EncodeExtension("61", nil, []byte("xy"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{
+		0x04, 0x0c, 0x00, 0x01, 'E',
+		0x05, 0x0c, 0x00, 0x02, '6', '1',
+		0x05, 0x0a, 0x00, 0x02, 'N', 'C',
+		0x04, 0x0c, 0x00, 0x01, 'V',
+		0x05, 0x0a, 0x00, 0x02, 'x', 'y',
+	}
+	if !bytes.Equal(blob, want) {
+		t.Errorf("Encoding must follow the documented layout.\n  got = %x\n  want = %x", blob, want)
+	}
+
+	long, err := mptproof.EncodeExtension("61", nil, bytes.Repeat([]byte{1}, 300), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 300 = 0x012c takes 2 bytes, so the value part is 0xfe 0x01 0x31, 0x0a 0x00 0xfe 0x01 0x2c and the value
+	if tail := long[len(long)-308 : len(long)-300]; !bytes.Equal(tail, []byte{0xfe, 0x01, 0x31, 0x0a, 0x00, 0xfe, 0x01, 0x2c}) {
+		t.Errorf("Long value must have a multi-byte length. got = %x", tail)
+	}
+}
+
+func TestDecodeNode(t *testing.T) {
+	{
+		t.Log("Decoding reverses the encoders")
+		blob, err := mptproof.EncodeExtension("61", bytes.Repeat([]byte{2}, 32), bytes.Repeat([]byte{1}, 300), true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		n, err := mptproof.DecodeNode(blob)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n.Branch || n.Key != "61" || !bytes.Equal(n.Next, bytes.Repeat([]byte{2}, 32)) || !n.HasValue || len(n.Value) != 300 {
+			t.Errorf("Unexpected extension = %+v", n)
+		}
+
+		children := make([][]byte, mptproof.ChildCount)
+		children[3] = []byte{3}
+		if blob, err = mptproof.EncodeBranch(children); err != nil {
+			t.Fatal(err)
+		}
+		if n, err = mptproof.DecodeNode(blob); err != nil {
+			t.Fatal(err)
+		}
+		if !n.Branch || len(n.Children) != mptproof.ChildCount || !bytes.Equal(n.Children[3], []byte{3}) || n.Children[4] != nil {
+			t.Errorf("Unexpected branch = %+v", n)
+		}
+	}
+
+	{
+		t.Log("Truncated and extended blobs are rejected")
+		blob, err := mptproof.EncodeExtension("61", nil, nil, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, b := range [][]byte{blob[:len(blob)-1], append(blob, 0), nil} {
+			if _, err := mptproof.DecodeNode(b); err == nil {
+				t.Errorf("Blob = %x must be rejected", b)
+			}
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	hs := hashService(t)
+
+	mt := mpt.NewMerklePatriciaTrie(hs)
+	for _, key := range []string{"key", "key123", "key12ab", "dog", "doge"} {
+		if err := mt.Insert([]byte(key), []byte("v"+key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	root := mt.RootHash()
+
+	{
+		t.Log("Proofs received as binary or JSON verify with the hash alone")
+
+		path, err := mt.FindMerklePath([]byte("key12ab"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := path.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var fromBinary mptproof.Path
+		if err := fromBinary.UnmarshalBinary(data); err != nil {
+			t.Fatal(err)
+		}
+		j, err := path.MarshalJSON()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var fromJSON mptproof.Path
+		if err := fromJSON.UnmarshalJSON(j); err != nil {
+			t.Fatal(err)
+		}
+		for _, p := range []mptproof.Path{fromBinary, fromJSON} {
+			if err := mptproof.Verify(hs, root, []byte("key12ab"), []byte("vkey12ab"), p); err != nil {
+				t.Errorf("Valid proof is rejected: %s", err)
+			}
+			if err := mptproof.Verify(hs, root, []byte("key12ab"), []byte("forged"), p); err == nil {
+				t.Error("Wrong value must be rejected")
+			}
+		}
+	}
+	{
+		t.Log("Absence and subtree proofs")
+
+		absence, err := mt.ProveAbsence([]byte("dogs"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := mptproof.VerifyAbsence(hs, root, []byte("dogs"), absence.ProofPath()); err != nil {
+			t.Errorf("Valid absence proof is rejected: %s", err)
+		}
+		subtree, err := mt.ProveSubtree([]byte("do"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		p := subtree.ProofPath()
+		if err := mptproof.VerifySubtree(hs, root, []byte("do"), p.SubtreeHash(), p); err != nil {
+			t.Errorf("Valid subtree proof is rejected: %s", err)
+		}
+	}
+	{
+		t.Log("Empty root")
+
+		empty, err := mptproof.EmptyRoot(hs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(empty, mpt.NewMerklePatriciaTrie(hs).RootHash()) {
+			t.Error("EmptyRoot must match the root of a new trie")
+		}
+	}
+}
+
+func TestVerifyKeys(t *testing.T) {
+	hs := hashService(t)
+
+	mt := mpt.NewMerklePatriciaTrie(hs)
+	for _, key := range []string{"key", "key123", "dog", "doge", "cat"} {
+		if err := mt.Insert([]byte(key), []byte("v"+key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	proof, err := mt.ProveKeys([][]byte{[]byte("key123"), []byte("doge")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := proof.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	{
+		t.Log("Decoded multi and range proofs verify without the trie")
+		var decoded mptproof.MultiProof
+		if err := decoded.UnmarshalBinary(data); err != nil {
+			t.Fatal(err)
+		}
+		pairs := []mptproof.KV{{Key: []byte("key123"), Value: []byte("vkey123")}, {Key: []byte("doge"), Value: []byte("vdoge")}}
+		if err := mptproof.VerifyKeys(hs, mt.RootHash(), pairs, decoded); err != nil {
+			t.Errorf("Valid multi proof is rejected: %s", err)
+		}
+		pairs[1].Value = []byte("forged")
+		if err := mptproof.VerifyKeys(hs, mt.RootHash(), pairs, decoded); err == nil {
+			t.Error("Forged value must be rejected")
+		}
+
+		rangeProof, err := mt.ProveRange([]byte("d"), []byte("e"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := mptproof.VerifyRange(hs, mt.RootHash(), []byte("d"), []byte("e"), rangeProof)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 2 || string(got[0].Key) != "dog" || string(got[1].Key) != "doge" {
+			t.Errorf("Unexpected pairs of the range = %q", got)
+		}
+	}
+
+	{
+		t.Log("Long chains of nodes verify without recursion")
+		const depth = 20000
+		proof := make(mptproof.MultiProof, depth+1)
+		proof[0] = mptproof.MultiNode{Bitmap: 1, Expanded: 1}
+		for i := 1; i <= depth; i++ {
+			proof[i] = mptproof.MultiNode{Key: "0", NextFollows: i < depth}
+		}
+		proof[depth].Value, proof[depth].HasValue = []byte("value"), true
+
+		var h []byte
+		for i := depth; i >= 1; i-- {
+			blob, err := mptproof.EncodeExtension("0", h, proof[i].Value, proof[i].HasValue)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if h, err = hs.Hash(blob); err != nil {
+				t.Fatal(err)
+			}
+		}
+		children := make([][]byte, mptproof.ChildCount)
+		children[0] = h
+		blob, err := mptproof.EncodeBranch(children)
+		if err != nil {
+			t.Fatal(err)
+		}
+		root, err := hs.Hash(blob)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pairs := []mptproof.KV{{Key: make([]byte, depth/2), Value: []byte("value")}}
+		if err := mptproof.VerifyKeys(hs, root, pairs, proof); err != nil {
+			t.Errorf("Valid deep multi proof is rejected: %s", err)
+		}
+		if err := mptproof.VerifyKeys(hs, root, pairs, proof[:depth]); err == nil {
+			t.Error("Truncated multi proof must be rejected")
+		}
+	}
+}
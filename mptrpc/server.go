@@ -0,0 +1,207 @@
+// Package mptrpc serves a trie over JSON-RPC 2.0 in the manner of eth_getProof,
+// so wallets and verifier tools can be tested end to end against this implementation.
+//
+// Keys, values and roots are 0x-prefixed hex. The methods are
+//
+//	getRoot()       the root hash
+//	getValue(key)   the value stored at key, null if none
+//	getProof(key)   {"root", "key", "value", "proof"}: a proof of the committed value at key,
+//	                or with a null value a proof of its absence
+//
+// Requests without an id are notifications. They are run but get no response, only HTTP 204 No Content.
+// Request bodies over MaxRequestSize are refused with HTTP 413. Batch requests are not supported.
+package mptrpc
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	mpt "github.com/example/infra/db/merkle_patricia_trie"
+)
+
+// Error codes of JSON-RPC 2.0.
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeServerError    = -32000
+)
+
+// MaxRequestSize is the largest request body in bytes the server reads.
+const MaxRequestSize = 1 << 20
+
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("json-rpc error %d: %s", e.Code, e.Message)
+}
+
+type request struct {
+	Version string            `json:"jsonrpc"`
+	ID      json.RawMessage   `json:"id"`
+	Method  string            `json:"method"`
+	Params  []json.RawMessage `json:"params"`
+}
+
+type response struct {
+	Version string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+// Proof is the result of getProof. Value is the value as committed, i.e. after the value transforms of the trie,
+// which is what mpt.VerifyMerklePath takes. A nil Value makes Proof an absence proof for mpt.VerifyAbsence.
+type Proof struct {
+	Root  string         `json:"root"`
+	Key   string         `json:"key"`
+	Value *string        `json:"value"`
+	Proof mpt.MerklePath `json:"proof"`
+}
+
+// Server answers JSON-RPC requests from the trie. It is safe for concurrent use;
+// the trie must only be modified through Update.
+type Server struct {
+	mu sync.RWMutex
+	mt *mpt.MerklePatriciaTrie
+}
+
+func NewServer(mt *mpt.MerklePatriciaTrie) *Server {
+	return &Server{mt: mt}
+}
+
+// Update runs fn with the trie while no request is answered.
+func (s *Server) Update(fn func(mt *mpt.MerklePatriciaTrie) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return fn(s.mt)
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "JSON-RPC requests must be POSTed", http.StatusMethodNotAllowed)
+		return
+	}
+	res := response{Version: "2.0", ID: json.RawMessage("null")}
+	var req request
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, MaxRequestSize)).Decode(&req); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+		res.Error = &Error{CodeParseError, err.Error()}
+	} else {
+		res.Result, res.Error = s.call(req)
+		// An invalid request is answered even without an id, since it is no notification
+		if len(req.ID) == 0 && (res.Error == nil || res.Error.Code != CodeInvalidRequest) {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if len(req.ID) > 0 {
+			res.ID = req.ID
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (s *Server) call(req request) (interface{}, *Error) {
+	if req.Version != "2.0" || req.Method == "" {
+		return nil, &Error{CodeInvalidRequest, "not a JSON-RPC 2.0 request"}
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	switch req.Method {
+	case "getRoot":
+		if len(req.Params) != 0 {
+			return nil, &Error{CodeInvalidParams, "getRoot takes no params"}
+		}
+		return mpt.FormatRoot(s.mt.RootHash()), nil
+	case "getValue":
+		key, rpcErr := keyParam(req.Params)
+		if rpcErr != nil {
+			return nil, rpcErr
+		}
+		ok, err := s.mt.Has(key)
+		if err != nil {
+			return nil, &Error{CodeServerError, err.Error()}
+		}
+		if !ok {
+			return json.RawMessage("null"), nil
+		}
+		value, err := s.mt.Get(key)
+		if err != nil {
+			return nil, &Error{CodeServerError, err.Error()}
+		}
+		return encodeHex(value), nil
+	case "getProof":
+		key, rpcErr := keyParam(req.Params)
+		if rpcErr != nil {
+			return nil, rpcErr
+		}
+		return s.proof(key)
+	default:
+		return nil, &Error{CodeMethodNotFound, fmt.Sprintf("method %q not found", req.Method)}
+	}
+}
+
+func (s *Server) proof(key []byte) (interface{}, *Error) {
+	ok, err := s.mt.Has(key)
+	if err != nil {
+		return nil, &Error{CodeServerError, err.Error()}
+	}
+	p := Proof{Root: mpt.FormatRoot(s.mt.RootHash()), Key: encodeHex(key)}
+	if ok {
+		p.Proof, err = s.mt.FindMerklePath(key)
+		if err == nil {
+			value := encodeHex(p.Proof.Value())
+			p.Value = &value
+		}
+	} else {
+		p.Proof, err = s.mt.ProveAbsence(key)
+	}
+	if err != nil {
+		return nil, &Error{CodeServerError, err.Error()}
+	}
+	return p, nil
+}
+
+func keyParam(params []json.RawMessage) ([]byte, *Error) {
+	if len(params) != 1 {
+		return nil, &Error{CodeInvalidParams, "expected the key as the only param"}
+	}
+	var s string
+	if err := json.Unmarshal(params[0], &s); err != nil {
+		return nil, &Error{CodeInvalidParams, "key must be a hex string"}
+	}
+	key, err := DecodeHex(s)
+	if err != nil || len(key) == 0 {
+		return nil, &Error{CodeInvalidParams, fmt.Sprintf("invalid key = <%s>", s)}
+	}
+	return key, nil
+}
+
+func encodeHex(b []byte) string {
+	return "0x" + hex.EncodeToString(b)
+}
+
+// DecodeHex decodes 0x-prefixed hex as used by the server.
+func DecodeHex(s string) ([]byte, error) {
+	if !strings.HasPrefix(s, "0x") {
+		return nil, fmt.Errorf("hex = <%s> has no 0x prefix", s)
+	}
+	return hex.DecodeString(s[2:])
+}
@@ -0,0 +1,182 @@
+package mptrpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/example/entity"
+	mpt "github.com/example/infra/db/merkle_patricia_trie"
+	"github.com/example/service/crypto"
+	"github.com/example/service/crypto/sha256"
+)
+
+func hashService(t *testing.T) crypto.Hash {
+	sha256.NewSha256()
+	hs, err := crypto.GetHashService(entity.HashSha256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hs
+}
+
+type rpcResponse struct {
+	ID     json.RawMessage `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *Error          `json:"error"`
+}
+
+func call(t *testing.T, url, body string) rpcResponse {
+	res, err := http.Post(url, "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	var r rpcResponse
+	if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestServer(t *testing.T) {
+	hs := hashService(t)
+
+	mt := mpt.NewMerklePatriciaTrie(hs)
+	for _, key := range []string{"key1", "key2", "other"} {
+		if err := mt.Insert([]byte(key), []byte("value of "+key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := NewServer(mt)
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	{
+		t.Log("getRoot returns the root hash")
+		r := call(t, ts.URL, `{"jsonrpc":"2.0","id":7,"method":"getRoot"}`)
+		var root string
+		if err := json.Unmarshal(r.Result, &root); err != nil || r.Error != nil {
+			t.Fatalf("Unexpected response: %+v", r)
+		}
+		if root != mpt.FormatRoot(mt.RootHash()) || string(r.ID) != "7" {
+			t.Errorf("Unexpected root\n  got = %s\n  want = %s", root, mpt.FormatRoot(mt.RootHash()))
+		}
+	}
+
+	{
+		t.Log("getValue returns the value or null")
+		r := call(t, ts.URL, `{"jsonrpc":"2.0","id":1,"method":"getValue","params":["0x6b657931"]}`)
+		var value string
+		if err := json.Unmarshal(r.Result, &value); err != nil {
+			t.Fatalf("Unexpected response: %+v", r)
+		}
+		if got, _ := DecodeHex(value); string(got) != "value of key1" {
+			t.Errorf("Unexpected value = <%s>", got)
+		}
+		r = call(t, ts.URL, `{"jsonrpc":"2.0","id":1,"method":"getValue","params":["0x6b657933"]}`)
+		if r.Error != nil || string(r.Result) != "null" {
+			t.Errorf("Missing key must give null. response: %+v", r)
+		}
+	}
+
+	{
+		t.Log("getProof proves presence and absence")
+		for key, present := range map[string]bool{"key2": true, "key3": false} {
+			r := call(t, ts.URL, `{"jsonrpc":"2.0","id":1,"method":"getProof","params":["`+encodeHex([]byte(key))+`"]}`)
+			var p Proof
+			if err := json.Unmarshal(r.Result, &p); err != nil || r.Error != nil {
+				t.Fatalf("Unexpected response: %+v", r)
+			}
+			root, err := mpt.ParseRootFor(hs, p.Root)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !present {
+				if p.Value != nil {
+					t.Errorf("Absent key = <%s> must have no value", key)
+				}
+				if err := mpt.VerifyAbsence(hs, root, []byte(key), p.Proof); err != nil {
+					t.Errorf("Absence proof of key = <%s> is rejected: %s", key, err)
+				}
+				continue
+			}
+			value, err := DecodeHex(*p.Value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := mpt.VerifyMerklePath(hs, root, []byte(key), value, p.Proof); err != nil {
+				t.Errorf("Proof of key = <%s> is rejected: %s", key, err)
+			}
+		}
+	}
+
+	{
+		t.Log("Updates are served")
+		if err := s.Update(func(mt *mpt.MerklePatriciaTrie) error { return mt.Insert([]byte("key3"), []byte("v")) }); err != nil {
+			t.Fatal(err)
+		}
+		r := call(t, ts.URL, `{"jsonrpc":"2.0","id":1,"method":"getValue","params":["0x6b657933"]}`)
+		if !bytes.Equal(r.Result, []byte(`"0x76"`)) {
+			t.Errorf("Unexpected response after Update(): %+v", r)
+		}
+	}
+
+	{
+		t.Log("Invalid requests are answered with errors")
+		for body, code := range map[string]int{
+			`{"jsonrpc":"2.0","id":1,"method":"getValue","params":["6b"]}`:   CodeInvalidParams,
+			`{"jsonrpc":"2.0","id":1,"method":"getValue","params":["0x"]}`:   CodeInvalidParams,
+			`{"jsonrpc":"2.0","id":1,"method":"getRoot","params":["0x6b"]}`:  CodeInvalidParams,
+			`{"jsonrpc":"2.0","id":1,"method":"eth_getBalance","params":[]}`: CodeMethodNotFound,
+			`{"jsonrpc":"1.0","id":1,"method":"getRoot"}`:                    CodeInvalidRequest,
+			`{"jsonrpc":"2.0","id":1,"method":`:                              CodeParseError,
+		} {
+			r := call(t, ts.URL, body)
+			if r.Error == nil || r.Error.Code != code {
+				t.Errorf("Unexpected response to %s: %+v", body, r)
+			}
+		}
+		if r := call(t, ts.URL, `{"jsonrpc":"1.0","method":"getRoot"}`); r.Error == nil || r.Error.Code != CodeInvalidRequest {
+			t.Errorf("Invalid request without an id must be answered: %+v", r)
+		}
+	}
+
+	{
+		t.Log("Notifications get no response")
+		for _, body := range []string{
+			`{"jsonrpc":"2.0","method":"getRoot"}`,
+			`{"jsonrpc":"2.0","method":"eth_getBalance","params":[]}`,
+		} {
+			res, err := http.Post(ts.URL, "application/json", strings.NewReader(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, _ := io.ReadAll(res.Body)
+			res.Body.Close()
+			if res.StatusCode != http.StatusNoContent || len(data) != 0 {
+				t.Errorf("Unexpected response to %s: %d %s", body, res.StatusCode, data)
+			}
+		}
+		if r := call(t, ts.URL, `{"jsonrpc":"2.0","id":null,"method":"getRoot"}`); r.Error != nil || string(r.ID) != "null" {
+			t.Errorf("Request with a null id must be answered: %+v", r)
+		}
+	}
+
+	{
+		t.Log("Oversized requests are refused")
+		body := `{"jsonrpc":"2.0","id":1,"method":"getRoot","params":["` + strings.Repeat("0", MaxRequestSize) + `"]}`
+		res, err := http.Post(ts.URL, "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != http.StatusRequestEntityTooLarge {
+			t.Errorf("Unexpected status of an oversized request. got = %d", res.StatusCode)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package merkle_patricia_trie
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/example/infra/db/merkle_patricia_trie/mptproof"
+	"github.com/example/infra/db/merkle_patricia_trie/trie"
+	"github.com/example/service/crypto"
+)
+
+// MultiProof holds the nodes on the paths of several keys in pre-order from the root branch,
+// so the ancestors the keys share appear only once. Its encodings and verification live in mptproof.
+type MultiProof = mptproof.MultiProof
+
+// extensionNode is the MultiProof node of an extension without its next node.
+func extensionNode(node trie.NodeExtension) mptproof.MultiNode {
+	n := mptproof.MultiNode{Key: node.Key()}
+	if node.HasValueObject() {
+		n.Value, n.HasValue = node.ValueObject().Value(), true
+	}
+	return n
+}
+
+func (mt *MerklePatriciaTrie) multiProofInExtension(keys []string, node trie.NodeExtension) (MultiProof, error) {
+	mt.touch()
+	var tails []string
+	for _, key := range keys {
+		if key == node.Key() {
+			if !node.HasValueObject() {
+				return nil, fmt.Errorf("ValueObject not found")
+			}
+			continue
+		}
+		if !strings.HasPrefix(key, node.Key()) || !node.HasNext() {
+			return nil, fmt.Errorf("ValueObject not found")
+		}
+		tails = append(tails, key[len(node.Key()):])
+	}
+
+	proofNode := extensionNode(node)
+	if len(tails) == 0 {
+		if node.HasNext() {
+			proofNode.NextHash = node.Next().Hash()
+		}
+		return MultiProof{proofNode}, nil
+	}
+
+	proofNode.NextFollows = true
+	var rest MultiProof
+	var err error
+	switch next := node.Next().(type) {
+	case trie.NodeExtension:
+		rest, err = mt.multiProofInExtension(tails, next)
+	case trie.NodeBranch:
+		rest, err = mt.multiProofInBranch(tails, next)
+	default:
+		panic("Unknown node type")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return append(MultiProof{proofNode}, rest...), nil
+}
+
+func (mt *MerklePatriciaTrie) multiProofInBranch(keys []string, node trie.NodeBranch) (MultiProof, error) {
+	mt.touch()
+	groups := make(map[int][]string)
+	for _, key := range keys {
+		c := key[0]
+		if !node.HasChildAt(c) {
+			return nil, fmt.Errorf("ValueObject not found under branch = <%c>", c)
+		}
+		index, err := mptproof.NibbleIndex(c)
+		if err != nil {
+			return nil, err
+		}
+		groups[index] = append(groups[index], key)
+	}
+
+	var proofNode mptproof.MultiNode
+	var rest MultiProof
+	for i, c := range node.ListChildren() {
+		if c == nil {
+			continue
+		}
+		proofNode.Bitmap |= 1 << uint(i)
+		group, ok := groups[i]
+		if !ok {
+			proofNode.Hashes = append(proofNode.Hashes, c.Hash())
+			continue
+		}
+		proofNode.Expanded |= 1 << uint(i)
+		sub, err := mt.multiProofInExtension(group, c)
+		if err != nil {
+			return nil, err
+		}
+		rest = append(rest, sub...)
+	}
+	return append(MultiProof{proofNode}, rest...), nil
+}
+
+// ProveKeys returns a single proof of all keys. It fails if any of them is not in the trie.
+func (mt *MerklePatriciaTrie) ProveKeys(keys [][]byte) (MultiProof, error) {
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no keys to prove")
+	}
+	eks := make([]string, 0, len(keys))
+	seen := make(map[string]struct{}, len(keys))
+	for _, key := range keys {
+		key, err := mt.forwardKey(key)
+		if err != nil {
+			return nil, err
+		}
+		if len(key) == 0 {
+			return nil, ErrEmptyKey
+		}
+		ek := hex.EncodeToString(key)
+		if _, ok := seen[ek]; ok {
+			continue
+		}
+		seen[ek] = struct{}{}
+		eks = append(eks, ek)
+	}
+	sort.Strings(eks)
+	return mt.multiProofInBranch(eks, mt.root)
+}
+
+// VerifyKeys recomputes the root hash from proof and checks that every pair is stored under rootHash.
+// Values are the values as committed, i.e. after the value transforms of the trie.
+func VerifyKeys(hs crypto.Hash, rootHash trie.HashBlob, pairs []KV, proof MultiProof) error {
+	proofPairs := make([]mptproof.KV, len(pairs))
+	for i, kv := range pairs {
+		proofPairs[i] = mptproof.KV(kv)
+	}
+	return mptproof.VerifyKeys(hs, rootHash, proofPairs, proof)
+}
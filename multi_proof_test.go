@@ -0,0 +1,185 @@
+package merkle_patricia_trie
+
+import (
+	"testing"
+)
+
+func TestMerklePatriciaTrie_ProveKeys(t *testing.T) {
+	hs := hashService(t)
+
+	mt := NewMerklePatriciaTrie(hs)
+	all := []string{"key", "key123", "key12ab", "dog", "doge", "cat", "k", "kk"}
+	for _, key := range all {
+		if err := mt.Insert([]byte(key), []byte("v"+key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pairsOf := func(keys ...string) []KV {
+		pairs := make([]KV, len(keys))
+		for i, key := range keys {
+			pairs[i] = KV{Key: []byte(key), Value: []byte("v" + key)}
+		}
+		return pairs
+	}
+	keysOf := func(pairs []KV) [][]byte {
+		keys := make([][]byte, len(pairs))
+		for i, kv := range pairs {
+			keys[i] = kv.Key
+		}
+		return keys
+	}
+
+	{
+		t.Log("Multi proofs verify every pair")
+
+		for _, pairs := range [][]KV{pairsOf("key"), pairsOf("key12ab", "key", "doge"), pairsOf(all...)} {
+			proof, err := mt.ProveKeys(keysOf(pairs))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := VerifyKeys(hs, mt.RootHash(), pairs, proof); err != nil {
+				t.Errorf("Valid multi proof is rejected: %s", err)
+			}
+		}
+	}
+	{
+		t.Log("Shared ancestors appear once")
+
+		proof, err := mt.ProveKeys(keysOf(pairsOf(all...)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		total := 0
+		for _, key := range all {
+			path, err := mt.FindMerklePath([]byte(key))
+			if err != nil {
+				t.Fatal(err)
+			}
+			total += len(path) - 1
+		}
+		if len(proof) >= total {
+			t.Errorf("Multi proof must be smaller than the separate paths. got = %d nodes, separate = %d", len(proof), total)
+		}
+	}
+	{
+		t.Log("Wrong pairs and tampered proofs are rejected")
+
+		pairs := pairsOf("key123", "doge")
+		proof, err := mt.ProveKeys(keysOf(pairs))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := VerifyKeys(hs, mt.RootHash(), []KV{{Key: []byte("doge"), Value: []byte("forged")}}, proof); err == nil {
+			t.Error("Wrong value must be rejected")
+		}
+		if err := VerifyKeys(hs, mt.RootHash(), pairsOf("cat"), proof); err == nil {
+			t.Error("Key outside of the proof must be rejected")
+		}
+		if err := VerifyKeys(hs, NewMerklePatriciaTrie(hs).RootHash(), pairs, proof); err == nil {
+			t.Error("Wrong root hash must be rejected")
+		}
+		if err := VerifyKeys(hs, mt.RootHash(), pairs, proof[:len(proof)-1]); err == nil {
+			t.Error("Truncated proof must be rejected")
+		}
+		tampered := append(MultiProof(nil), proof...)
+		last := tampered[len(tampered)-1]
+		last.Key = last.Key[:len(last.Key)-1] + "0"
+		tampered[len(tampered)-1] = last
+		if err := VerifyKeys(hs, mt.RootHash(), pairs, tampered); err == nil {
+			t.Error("Tampered proof must be rejected")
+		}
+	}
+	{
+		t.Log("Missing keys cannot be proven")
+
+		if _, err := mt.ProveKeys([][]byte{[]byte("key"), []byte("key1")}); err == nil {
+			t.Error("Missing key must not be proven")
+		}
+		if _, err := mt.ProveKeys(nil); err == nil {
+			t.Error("Empty key set must not be proven")
+		}
+	}
+}
+
+func TestMultiProof_MarshalBinary(t *testing.T) {
+	hs := hashService(t)
+
+	mt := NewMerklePatriciaTrie(hs)
+	keys := []string{"key", "key123", "key12ab", "dog", "doge"}
+	for _, key := range keys {
+		if err := mt.Insert([]byte(key), []byte("v"+key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	proof, err := mt.ProveKeys([][]byte{[]byte("key123"), []byte("doge")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := proof.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded MultiProof
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	pairs := []KV{{[]byte("key123"), []byte("vkey123")}, {[]byte("doge"), []byte("vdoge")}}
+	if err := VerifyKeys(hs, mt.RootHash(), pairs, decoded); err != nil {
+		t.Errorf("Decoded multi proof does not verify: %s", err)
+	}
+	for name, bad := range map[string][]byte{
+		"empty":     {},
+		"version":   append([]byte{0x02}, data[1:]...),
+		"truncated": data[:len(data)-1],
+		"trailing":  append(append([]byte(nil), data...), 0x00),
+	} {
+		if err := new(MultiProof).UnmarshalBinary(bad); err == nil {
+			t.Errorf("Multi proof with bad %s must be rejected", name)
+		}
+	}
+}
+
+func TestMultiProof_MarshalSSZ(t *testing.T) {
+	hs := hashService(t)
+
+	mt := NewMerklePatriciaTrie(hs)
+	for _, key := range []string{"key", "key123", "key12ab", "dog", "doge", "cat"} {
+		if err := mt.Insert([]byte(key), []byte("v"+key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	proof, err := mt.ProveKeys([][]byte{[]byte("key123"), []byte("doge")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := proof.MarshalSSZ()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded MultiProof
+	if err := decoded.UnmarshalSSZ(data); err != nil {
+		t.Fatal(err)
+	}
+	pairs := []KV{{[]byte("key123"), []byte("vkey123")}, {[]byte("doge"), []byte("vdoge")}}
+	if err := VerifyKeys(hs, mt.RootHash(), pairs, decoded); err != nil {
+		t.Errorf("Decoded multi proof does not verify: %s", err)
+	}
+	for name, bad := range map[string][]byte{
+		"truncated": data[:len(data)-1],
+		"offset":    append([]byte{0xff}, data[1:]...),
+		"boolean":   flipSSZBool(data),
+	} {
+		if err := new(MultiProof).UnmarshalSSZ(bad); err == nil {
+			t.Errorf("Multi proof with bad %s must be rejected", name)
+		}
+	}
+}
+
+// flipSSZBool sets the has_value boolean of the first node of an SSZ multi proof to 2.
+func flipSSZBool(data []byte) []byte {
+	bad := append([]byte(nil), data...)
+	first := int(bad[0]) | int(bad[1])<<8
+	// bitmap, expanded, hashes offset, key offset and value offset precede it
+	bad[first+2+2+4+4+4] = 2
+	return bad
+}
@@ -0,0 +1,135 @@
+package merkle_patricia_trie
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"github.com/example/infra/db/merkle_patricia_trie/mptproof"
+	"github.com/example/infra/db/merkle_patricia_trie/trie"
+)
+
+// Nibbles is a path in the trie as lower case hex nibbles, one character per nibble.
+type Nibbles string
+
+func NibblesOf(key []byte) Nibbles {
+	return Nibbles(hex.EncodeToString(key))
+}
+
+type NodeType int
+
+const (
+	BranchNode NodeType = iota
+	ExtensionNode
+)
+
+func (t NodeType) String() string {
+	switch t {
+	case BranchNode:
+		return "branch"
+	case ExtensionNode:
+		return "extension"
+	default:
+		return fmt.Sprintf("NodeType(%d)", int(t))
+	}
+}
+
+// ChildInfo summarizes a child of a branch, or the next node of an extension.
+type ChildInfo struct {
+	// Index is the nibble of a branch child and -1 for the next node of an extension.
+	Index int
+	Type  NodeType
+	// Key is the key fragment of an extension child, including its branch index.
+	Key  string
+	Hash trie.HashBlob
+}
+
+// NodeInfo describes a node found by NodeAt.
+type NodeInfo struct {
+	Type NodeType
+	// Key is the key fragment of an extension, including its branch index.
+	Key  string
+	Hash trie.HashBlob
+	// Value is the committed value of an extension, i.e. before the inverse value transforms. nil if none.
+	Value    []byte
+	Children []ChildInfo
+}
+
+func childInfo(index int, node trie.Node) ChildInfo {
+	switch n := node.(type) {
+	case trie.NodeExtension:
+		return ChildInfo{Index: index, Type: ExtensionNode, Key: n.Key(), Hash: n.Hash()}
+	case trie.NodeBranch:
+		return ChildInfo{Index: index, Type: BranchNode, Hash: n.Hash()}
+	default:
+		panic("Unknown node type")
+	}
+}
+
+func nodeInfo(node trie.Node) NodeInfo {
+	switch n := node.(type) {
+	case trie.NodeExtension:
+		info := NodeInfo{Type: ExtensionNode, Key: n.Key(), Hash: n.Hash()}
+		if n.HasValueObject() {
+			info.Value = n.ValueObject().Value()
+		}
+		if n.HasNext() {
+			info.Children = []ChildInfo{childInfo(-1, n.Next())}
+		}
+		return info
+	case trie.NodeBranch:
+		info := NodeInfo{Type: BranchNode, Hash: n.Hash()}
+		for i, c := range n.ListChildren() {
+			if c != nil {
+				info.Children = append(info.Children, childInfo(i, c))
+			}
+		}
+		return info
+	default:
+		panic("Unknown node type")
+	}
+}
+
+// NodeAt returns the node at path.
+// A branch is at the nibbles leading to it, so the root branch is at the empty path.
+// An extension is at the nibbles leading to it followed by the first nibble of its key.
+// An extension with a single-nibble key shares its path with the branch below it; NodeAt returns
+// the branch then, and the extension is still described among the children of its parent.
+func (mt *MerklePatriciaTrie) NodeAt(path Nibbles) (NodeInfo, error) {
+	p := string(path)
+	for i := range p {
+		if _, err := mptproof.NibbleIndex(p[i]); err != nil {
+			return NodeInfo{}, err
+		}
+	}
+	pos := 0
+	var node trie.Node = mt.root
+	for {
+		switch current := node.(type) {
+		case trie.NodeBranch:
+			if pos == len(p) {
+				return nodeInfo(current), nil
+			}
+			if !current.HasChildAt(p[pos]) {
+				return NodeInfo{}, fmt.Errorf("no node at path = <%s>", p)
+			}
+			node = current.ChildAt(p[pos])
+		case trie.NodeExtension:
+			k := current.Key()
+			if pos == len(p) || p[pos] != k[0] {
+				return NodeInfo{}, fmt.Errorf("no node at path = <%s>", p)
+			}
+			if pos+1 == len(p) {
+				if _, ok := current.Next().(trie.NodeBranch); len(k) > 1 || !ok {
+					return nodeInfo(current), nil
+				}
+			}
+			if len(p)-pos < len(k) || p[pos:pos+len(k)] != k || !current.HasNext() {
+				return NodeInfo{}, fmt.Errorf("no node at path = <%s>", p)
+			}
+			pos += len(k)
+			node = current.Next()
+		default:
+			panic("Unknown node type")
+		}
+	}
+}
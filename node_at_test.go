@@ -0,0 +1,100 @@
+package merkle_patricia_trie
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestMerklePatriciaTrie_NodeAt(t *testing.T) {
+	hs := hashService(t)
+
+	mt := NewMerklePatriciaTrie(hs)
+	for _, key := range []string{"key", "key123", "key12ab"} {
+		if err := mt.Insert([]byte(key), []byte("v"+key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	{
+		t.Log("Every node is addressable")
+
+		cases := []struct {
+			path     Nibbles
+			typ      NodeType
+			key      string
+			value    string
+			children int
+		}{
+			{"", BranchNode, "", "", 1},
+			{"6", ExtensionNode, "6b6579", "vkey", 1},
+			{"6b65793", ExtensionNode, "3132", "", 1},
+			{"6b65793132", BranchNode, "", "", 2},
+			{"6b657931323", ExtensionNode, "33", "vkey123", 0},
+			{"6b657931326", ExtensionNode, "6162", "vkey12ab", 0},
+		}
+		for _, c := range cases {
+			info, err := mt.NodeAt(c.path)
+			if err != nil {
+				t.Errorf("Node at <%s> not found: %s", c.path, err)
+				continue
+			}
+			if info.Type != c.typ || info.Key != c.key || string(info.Value) != c.value || len(info.Children) != c.children {
+				t.Errorf("Unexpected node at <%s>.\n  got = %s %s %q %d children\n  want = %s %s %q %d children",
+					c.path, info.Type, info.Key, info.Value, len(info.Children), c.typ, c.key, c.value, c.children)
+			}
+		}
+
+		root, err := mt.NodeAt("")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(root.Hash, mt.RootHash()) {
+			t.Error("Root node must carry the root hash")
+		}
+		if child := root.Children[0]; child.Index != 6 || child.Type != ExtensionNode || child.Key != "6b6579" {
+			t.Errorf("Unexpected child summary of the root. got = %+v", child)
+		}
+		info, err := mt.NodeAt("6")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if next := info.Children[0]; next.Index != -1 || next.Type != ExtensionNode || next.Key != "3132" {
+			t.Errorf("Unexpected next node summary. got = %+v", next)
+		}
+	}
+	{
+		t.Log("Paths between nodes are rejected")
+
+		for _, path := range []Nibbles{"7", "6b", "6b65", "6b6579", "6b65794", "6b65793132333", "x"} {
+			if _, err := mt.NodeAt(path); err == nil {
+				t.Errorf("Path <%s> must not lead to a node", path)
+			}
+		}
+	}
+	{
+		t.Log("Single nibble extension above a branch")
+
+		mt := NewMerklePatriciaTrie(hs)
+		for _, key := range []string{"ab", "abc", "abs", "ac"} {
+			if err := mt.Insert([]byte(key), []byte("v"+key)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if info, err := mt.NodeAt("6162"); err != nil || info.Type != BranchNode {
+			t.Errorf("Branch below the single nibble extension must win. info: %+v, err: %v", info, err)
+		}
+		if info, err := mt.NodeAt("61626"); err != nil || info.Type != ExtensionNode || string(info.Value) != "vabc" {
+			t.Errorf("Extension below the branch must be addressable. info: %+v, err: %v", info, err)
+		}
+		if info, err := mt.NodeAt("6163"); err != nil || info.Type != ExtensionNode || string(info.Value) != "vac" {
+			t.Errorf("Single nibble leaf must be addressable. info: %+v, err: %v", info, err)
+		}
+		info, err := mt.NodeAt("616")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(info.Children) != 2 || info.Children[0].Key != "2" || info.Children[0].Index != 2 {
+			t.Errorf("Single nibble extension must be described by its parent. got = %+v", info.Children)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package merkle_patricia_trie
+
+import (
+	"github.com/example/infra/db/merkle_patricia_trie/trie"
+	"github.com/pkg/errors"
+)
+
+// NodeIterator traverses every extension and branch node in pre-order, children in nibble order.
+// The trie must not be modified while iterating.
+type NodeIterator struct {
+	stack []iteratorItem
+	path  string
+	node  trie.Node
+	blob  []byte
+	err   error
+}
+
+func (mt *MerklePatriciaTrie) NewNodeIterator() *NodeIterator {
+	return &NodeIterator{stack: []iteratorItem{{"", mt.root, nil}}}
+}
+
+// Next advances to the next node and reports whether there is one.
+func (it *NodeIterator) Next() bool {
+	if it.err != nil || len(it.stack) == 0 {
+		it.node, it.blob = nil, nil
+		return false
+	}
+	item := it.stack[len(it.stack)-1]
+	it.stack = it.stack[:len(it.stack)-1]
+
+	switch node := item.node.(type) {
+	case trie.NodeExtension:
+		if node.HasNext() {
+			it.stack = append(it.stack, iteratorItem{item.path + node.Key(), node.Next(), nil})
+		}
+	case trie.NodeBranch:
+		children := node.ListChildren()
+		for i := len(children) - 1; i >= 0; i-- {
+			if children[i] != nil {
+				it.stack = append(it.stack, iteratorItem{item.path, children[i], nil})
+			}
+		}
+	default:
+		panic("Unknown node type")
+	}
+
+	blob, err := item.node.Serialize()
+	if err != nil {
+		it.err = errors.Wrapf(err, "failed to serialize node at path = <%s>", item.path)
+		it.node, it.blob = nil, nil
+		return false
+	}
+	it.path, it.node, it.blob = item.path, item.node, blob
+	return true
+}
+
+// Path returns the hex nibble path from the root to the current node.
+// For an extension node it does not include the node's own key.
+func (it *NodeIterator) Path() string {
+	return it.path
+}
+
+func (it *NodeIterator) Node() trie.Node {
+	return it.node
+}
+
+func (it *NodeIterator) Hash() trie.HashBlob {
+	return it.node.Hash()
+}
+
+// Blob returns the serialized node, which is the preimage of Hash().
+func (it *NodeIterator) Blob() []byte {
+	return it.blob
+}
+
+// Err returns the error which stopped the iteration, if any.
+func (it *NodeIterator) Err() error {
+	return it.err
+}
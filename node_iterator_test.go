@@ -0,0 +1,46 @@
+package merkle_patricia_trie
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/example/infra/db/merkle_patricia_trie/trie"
+)
+
+func TestNodeIterator(t *testing.T) {
+	hs := hashService(t)
+
+	mt := NewMerklePatriciaTrie(hs)
+	for _, key := range []string{"key", "key123", "key12ab"} {
+		if err := mt.Insert([]byte(key), []byte("value")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// root B -> E(6b6579) -> E(3132) -> B -> E(33), E(6162)
+	var got []string
+	it := mt.NewNodeIterator()
+	for it.Next() {
+		h, err := hs.Hash(it.Blob())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(h, it.Hash()) {
+			t.Errorf("Blob must be the preimage of the hash at path <%s>", it.Path())
+		}
+		switch node := it.Node().(type) {
+		case trie.NodeExtension:
+			got = append(got, "E:"+it.Path()+"+"+node.Key())
+		case trie.NodeBranch:
+			got = append(got, "B:"+it.Path())
+		}
+	}
+	if err := it.Err(); err != nil {
+		t.Fatal(err)
+	}
+	want := "B:,E:+6b6579,E:6b6579+3132,B:6b65793132,E:6b65793132+33,E:6b65793132+6162"
+	if strings.Join(got, ",") != want {
+		t.Errorf("Unexpected nodes.\n  got = %s\n  want = %s", strings.Join(got, ","), want)
+	}
+}
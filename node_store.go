@@ -0,0 +1,281 @@
+package merkle_patricia_trie
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/example/infra/db/merkle_patricia_trie/mptproof"
+	"github.com/example/infra/db/merkle_patricia_trie/trie"
+	"github.com/example/service/crypto"
+	"github.com/pkg/errors"
+)
+
+// NodeStore keeps encoded nodes by their hash, so a trie can be persisted with Commit and read back with Load.
+// Nodes refer to their children by hash, so a store holds the nodes of many roots and shares the common subtrees.
+type NodeStore interface {
+	Put(hash trie.HashBlob, blob []byte) error
+	// Get returns ErrNodeNotFound for a hash which is not stored.
+	Get(hash trie.HashBlob) ([]byte, error)
+	Delete(hash trie.HashBlob) error
+}
+
+var ErrNodeNotFound = errors.New("node not found")
+
+type memoryNodeStore struct {
+	blobs map[string][]byte
+}
+
+func NewMemoryNodeStore() NodeStore {
+	return &memoryNodeStore{make(map[string][]byte)}
+}
+
+func (s *memoryNodeStore) Put(hash trie.HashBlob, blob []byte) error {
+	s.blobs[string(hash)] = append([]byte(nil), blob...)
+	return nil
+}
+
+func (s *memoryNodeStore) Get(hash trie.HashBlob) ([]byte, error) {
+	blob, ok := s.blobs[string(hash)]
+	if !ok {
+		return nil, ErrNodeNotFound
+	}
+	return blob, nil
+}
+
+func (s *memoryNodeStore) Delete(hash trie.HashBlob) error {
+	delete(s.blobs, string(hash))
+	return nil
+}
+
+func hasNode(store NodeStore, hash trie.HashBlob) (bool, error) {
+	_, err := store.Get(hash)
+	if err == ErrNodeNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to read node = <%x>", hash)
+	}
+	return true, nil
+}
+
+// commitNode writes node and the nodes below it which are missing from store, and returns the number written.
+// A stored node implies its subtree is stored, so the walk stops at the first one found.
+func commitNode(store NodeStore, node trie.Node) (int, error) {
+	stored, err := hasNode(store, node.Hash())
+	if err != nil || stored {
+		return 0, err
+	}
+	written := 0
+	switch n := node.(type) {
+	case trie.NodeExtension:
+		if n.HasNext() {
+			if written, err = commitNode(store, n.Next()); err != nil {
+				return 0, err
+			}
+		}
+	case trie.NodeBranch:
+		for _, child := range n.ListChildren() {
+			if child == nil {
+				continue
+			}
+			w, err := commitNode(store, child)
+			if err != nil {
+				return 0, err
+			}
+			written += w
+		}
+	default:
+		panic("Unknown node type")
+	}
+	// Children go first, so an interrupted commit never leaves a stored node with a missing child
+	blob, err := node.Serialize()
+	if err != nil {
+		return 0, err
+	}
+	if err := store.Put(node.Hash(), blob); err != nil {
+		return 0, errors.Wrapf(err, "failed to write node = <%x>", node.Hash())
+	}
+	return written + 1, nil
+}
+
+// Commit writes the nodes of the trie which are missing from store and returns the number of nodes written.
+// After a commit, Load reads the trie back from its root hash.
+func (mt *MerklePatriciaTrie) Commit(store NodeStore) (int, error) {
+	return commitNode(store, mt.root)
+}
+
+// reachable collects the hashes of the nodes under node.
+func reachable(node trie.Node, hashes map[string]bool) {
+	hashes[string(node.Hash())] = true
+	switch n := node.(type) {
+	case trie.NodeExtension:
+		if n.HasNext() {
+			reachable(n.Next(), hashes)
+		}
+	case trie.NodeBranch:
+		for _, child := range n.ListChildren() {
+			if child != nil {
+				reachable(child, hashes)
+			}
+		}
+	default:
+		panic("Unknown node type")
+	}
+}
+
+// Prune deletes the nodes under the committed root oldRoot which are not in the trie, and returns the number deleted.
+// Nodes are deleted parents first, so an interrupted prune never leaves a stored node with a missing child.
+// Other roots sharing the store lose the nodes they share with oldRoot, so only the latest root of a store
+// which keeps one trie should be passed.
+func (mt *MerklePatriciaTrie) Prune(store NodeStore, oldRoot trie.HashBlob) (int, error) {
+	keep := make(map[string]bool)
+	reachable(mt.root, keep)
+	var prune func(hash trie.HashBlob) (int, error)
+	prune = func(hash trie.HashBlob) (int, error) {
+		if keep[string(hash)] {
+			return 0, nil
+		}
+		// Shared subtrees are reached once per parent, so a deleted node is kept from here on
+		keep[string(hash)] = true
+		blob, err := store.Get(hash)
+		if err == ErrNodeNotFound {
+			return 0, nil
+		}
+		if err != nil {
+			return 0, errors.Wrapf(err, "failed to read node = <%x>", hash)
+		}
+		node, err := mptproof.DecodeNode(blob)
+		if err != nil {
+			return 0, errors.Wrapf(err, "failed to decode node = <%x>", hash)
+		}
+		if err := store.Delete(hash); err != nil {
+			return 0, errors.Wrapf(err, "failed to delete node = <%x>", hash)
+		}
+		deleted := 1
+		for _, child := range append(node.Children, node.Next) {
+			if child == nil {
+				continue
+			}
+			d, err := prune(child)
+			if err != nil {
+				return 0, err
+			}
+			deleted += d
+		}
+		return deleted, nil
+	}
+	return prune(oldRoot)
+}
+
+// loadNode reads the node of hash from store and checks the blob against the hash.
+func loadNode(hs crypto.Hash, store NodeStore, hash trie.HashBlob) (mptproof.Node, error) {
+	blob, err := store.Get(hash)
+	if err != nil {
+		return mptproof.Node{}, errors.Wrapf(err, "failed to read node = <%x>", hash)
+	}
+	h, err := hs.Hash(blob)
+	if err != nil {
+		return mptproof.Node{}, err
+	}
+	if !bytes.Equal(h, hash) {
+		return mptproof.Node{}, fmt.Errorf("node = <%x> does not match its hash", hash)
+	}
+	node, err := mptproof.DecodeNode(blob)
+	if err != nil {
+		return mptproof.Node{}, errors.Wrapf(err, "failed to decode node = <%x>", hash)
+	}
+	return node, nil
+}
+
+// loadSubtree builds the node of hash and the nodes below it from store.
+func loadSubtree(hs crypto.Hash, store NodeStore, hash trie.HashBlob) (trie.Node, error) {
+	node, err := loadNode(hs, store, hash)
+	if err != nil {
+		return nil, err
+	}
+	if node.Branch {
+		branch := trie.NewNodeBranch()
+		for i, h := range node.Children {
+			if h == nil {
+				continue
+			}
+			child, err := loadSubtree(hs, store, h)
+			if err != nil {
+				return nil, err
+			}
+			extension, ok := child.(trie.NodeExtension)
+			if !ok || extension.Key()[0] != "0123456789abcdef"[i] {
+				return nil, fmt.Errorf("child = <%x> of branch = <%x> is not an extension at its index", h, hash)
+			}
+			if err := branch.Append(extension); err != nil {
+				return nil, err
+			}
+		}
+		if err := branch.UpdateHash(hs); err != nil {
+			return nil, err
+		}
+		return checkRebuilt(branch, hash)
+	}
+
+	if node.Key == "" {
+		return nil, fmt.Errorf("extension = <%x> has no key", hash)
+	}
+	for i := 0; i < len(node.Key); i++ {
+		if _, err := mptproof.NibbleIndex(node.Key[i]); err != nil {
+			return nil, errors.Wrapf(err, "extension = <%x> has an invalid key", hash)
+		}
+	}
+	if !node.HasValue && node.Next == nil {
+		return nil, fmt.Errorf("extension = <%x> has neither a value nor a next node", hash)
+	}
+	var next trie.Node
+	if node.Next != nil {
+		if next, err = loadSubtree(hs, store, node.Next); err != nil {
+			return nil, err
+		}
+	}
+	// Writes merge a valueless extension with the extension below it and fold a branch left with one child,
+	// so neither shape is ever stored
+	switch next := next.(type) {
+	case trie.NodeExtension:
+		if !node.HasValue {
+			return nil, fmt.Errorf("extension = <%x> without a value is followed by an extension", hash)
+		}
+	case trie.NodeBranch:
+		if next.Count() < 2 {
+			return nil, fmt.Errorf("extension = <%x> is followed by a branch with less than two children", hash)
+		}
+	}
+	var value trie.ValueObject
+	if node.HasValue {
+		value = trie.NewValueObject(node.Value)
+	}
+	extension, err := trie.NewNodeExtension(node.Key, next, value, hs)
+	if err != nil {
+		return nil, err
+	}
+	return checkRebuilt(extension, hash)
+}
+
+// checkRebuilt fails if node, rebuilt from the blob of hash, does not encode to the same blob again.
+func checkRebuilt(node trie.Node, hash trie.HashBlob) (trie.Node, error) {
+	if !bytes.Equal(node.Hash(), hash) {
+		return nil, fmt.Errorf("node = <%x> is not in canonical encoding", hash)
+	}
+	return node, nil
+}
+
+// Load reads the trie of root committed to store. Every node is checked against its hash
+// and for the shape writes give it, so a corrupted store fails the load instead of yielding another trie.
+// Transforms are not stored with the nodes and have to be set again on the loaded trie.
+func Load(hs crypto.Hash, store NodeStore, root trie.HashBlob) (*MerklePatriciaTrie, error) {
+	node, err := loadSubtree(hs, store, root)
+	if err != nil {
+		return nil, err
+	}
+	branch, ok := node.(trie.NodeBranch)
+	if !ok {
+		return nil, fmt.Errorf("root = <%x> is not a branch", root)
+	}
+	return &MerklePatriciaTrie{hs: hs, root: branch}, nil
+}
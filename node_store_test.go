@@ -0,0 +1,148 @@
+package merkle_patricia_trie
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/example/infra/db/merkle_patricia_trie/mptproof"
+)
+
+func TestMerklePatriciaTrie_Commit(t *testing.T) {
+	hs := hashService(t)
+
+	mt := NewMerklePatriciaTrie(hs)
+	for i := 0; i < 100; i++ {
+		if err := mt.Insert([]byte(fmt.Sprintf("key%d", i)), []byte(fmt.Sprintf("value%d", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := mt.Insert([]byte("empty"), []byte{}); err != nil {
+		t.Fatal(err)
+	}
+	store := NewMemoryNodeStore()
+	written, err := mt.Commit(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodes := len(store.(*memoryNodeStore).blobs)
+
+	{
+		t.Log("Load() reads the committed trie back")
+		loaded, err := Load(hs, store, mt.RootHash())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(loaded.RootHash(), mt.RootHash()) {
+			t.Errorf("Unexpected root hash\n  got = %x\n  want = %x", loaded.RootHash(), mt.RootHash())
+		}
+		for i := 0; i < 100; i++ {
+			if value, err := loaded.Get([]byte(fmt.Sprintf("key%d", i))); err != nil || string(value) != fmt.Sprintf("value%d", i) {
+				t.Errorf("Unexpected value = <%s> of key%d, err: %v", value, i, err)
+			}
+		}
+		if value, err := loaded.Get([]byte("empty")); err != nil || len(value) != 0 {
+			t.Errorf("Unexpected value = <%s> of an empty value, err: %v", value, err)
+		}
+	}
+
+	{
+		t.Log("Commit() writes only the changed nodes and Prune() deletes the replaced ones")
+		if w, err := mt.Commit(store); err != nil || w != 0 {
+			t.Errorf("Nothing must be written again, got %d, err: %v", w, err)
+		}
+		oldRoot := mt.RootHash()
+		if _, _, err := mt.Put([]byte("key1"), []byte("changed")); err != nil {
+			t.Fatal(err)
+		}
+		w, err := mt.Commit(store)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if w == 0 || w >= written {
+			t.Errorf("Only the path to the change must be written, got %d of %d nodes", w, written)
+		}
+		pruned, err := mt.Prune(store, oldRoot)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pruned != w || len(store.(*memoryNodeStore).blobs) != nodes {
+			t.Errorf("Prune() must delete the replaced path, deleted %d of %d, %d nodes left of %d",
+				pruned, w, len(store.(*memoryNodeStore).blobs), nodes)
+		}
+		if _, err := Load(hs, store, oldRoot); err == nil {
+			t.Error("Pruned root must not load")
+		}
+		loaded, err := Load(hs, store, mt.RootHash())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if value, err := loaded.Get([]byte("key1")); err != nil || string(value) != "changed" {
+			t.Errorf("Unexpected value = <%s>, err: %v", value, err)
+		}
+	}
+
+	{
+		t.Log("Load() rejects a corrupted store")
+		blobs := store.(*memoryNodeStore).blobs
+		for h, blob := range blobs {
+			if bytes.Equal([]byte(h), mt.RootHash()) {
+				continue
+			}
+			blobs[h] = append(blob[:len(blob)-1:len(blob)-1], blob[len(blob)-1]^1)
+			break
+		}
+		if _, err := Load(hs, store, mt.RootHash()); err == nil {
+			t.Error("Load() must fail on a node which does not match its hash")
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	hs := hashService(t)
+
+	store := NewMemoryNodeStore()
+	put := func(blob []byte, err error) []byte {
+		if err != nil {
+			t.Fatal(err)
+		}
+		h, err := hs.Hash(blob)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := store.Put(h, blob); err != nil {
+			t.Fatal(err)
+		}
+		return h
+	}
+	root := func(child []byte) []byte {
+		children := make([][]byte, mptproof.ChildCount)
+		children[6] = child
+		return put(mptproof.EncodeBranch(children))
+	}
+
+	leaf := put(mptproof.EncodeExtension("1", nil, []byte("value"), true))
+	if _, err := Load(hs, store, root(put(mptproof.EncodeExtension("6", leaf, nil, false)))); err == nil {
+		t.Error("Valueless extension followed by an extension must be rejected")
+	}
+	single := make([][]byte, mptproof.ChildCount)
+	single[1] = leaf
+	if _, err := Load(hs, store, root(put(mptproof.EncodeExtension("6", put(mptproof.EncodeBranch(single)), nil, false)))); err == nil {
+		t.Error("Branch with a single child below an extension must be rejected")
+	}
+	if _, err := Load(hs, store, root(put(mptproof.EncodeExtension("6", nil, nil, false)))); err == nil {
+		t.Error("Extension without a value and a next node must be rejected")
+	}
+	for _, key := range []string{"6g", "6A", "6-1"} {
+		if _, err := Load(hs, store, root(put(mptproof.EncodeExtension(key, nil, []byte("value"), true)))); err == nil {
+			t.Errorf("Extension with key = <%s> must be rejected", key)
+		}
+	}
+	loaded, err := Load(hs, store, root(put(mptproof.EncodeExtension("61", nil, []byte("value"), true))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value, err := loaded.Get([]byte("a")); err != nil || string(value) != "value" {
+		t.Errorf("Unexpected value = <%s>, err: %v", value, err)
+	}
+}
@@ -0,0 +1,70 @@
+package merkle_patricia_trie
+
+import (
+	"context"
+	"encoding/hex"
+	"runtime/pprof"
+)
+
+// The *Ctx variants below run an operation with pprof labels naming it and the first key byte,
+// so CPU profiles attribute samples to specific operations and key prefix buckets.
+// The labels are added to the ones of ctx, and the goroutine gets the labels of ctx back afterwards.
+// The plain methods set no labels.
+
+func doWithLabels(ctx context.Context, op string, key []byte, fn func()) {
+	bucket := ""
+	if len(key) > 0 {
+		bucket = hex.EncodeToString(key[:1])
+	}
+	pprof.Do(ctx, pprof.Labels("mpt_op", op, "mpt_key_prefix", bucket), func(context.Context) {
+		fn()
+	})
+}
+
+// InsertCtx is Insert labelled for profiles.
+func (mt *MerklePatriciaTrie) InsertCtx(ctx context.Context, key []byte, value []byte) (err error) {
+	doWithLabels(ctx, "insert", key, func() {
+		err = mt.Insert(key, value)
+	})
+	return err
+}
+
+// PutCtx is Put labelled for profiles.
+func (mt *MerklePatriciaTrie) PutCtx(ctx context.Context, key []byte, value []byte) (prev []byte, existed bool, err error) {
+	doWithLabels(ctx, "put", key, func() {
+		prev, existed, err = mt.Put(key, value)
+	})
+	return prev, existed, err
+}
+
+// DeleteCtx is Delete labelled for profiles.
+func (mt *MerklePatriciaTrie) DeleteCtx(ctx context.Context, key []byte) (err error) {
+	doWithLabels(ctx, "delete", key, func() {
+		err = mt.Delete(key)
+	})
+	return err
+}
+
+// RemoveCtx is Remove labelled for profiles.
+func (mt *MerklePatriciaTrie) RemoveCtx(ctx context.Context, key []byte) (removed []byte, err error) {
+	doWithLabels(ctx, "delete", key, func() {
+		removed, err = mt.Remove(key)
+	})
+	return removed, err
+}
+
+// FindMerklePathCtx is FindMerklePath labelled for profiles.
+func (mt *MerklePatriciaTrie) FindMerklePathCtx(ctx context.Context, key []byte) (path MerklePath, err error) {
+	doWithLabels(ctx, "prove", key, func() {
+		path, err = mt.FindMerklePath(key)
+	})
+	return path, err
+}
+
+// CommitCtx is Commit labelled for profiles. Its key prefix label is empty.
+func (mt *MerklePatriciaTrie) CommitCtx(ctx context.Context, store NodeStore) (written int, err error) {
+	doWithLabels(ctx, "commit", nil, func() {
+		written, err = mt.Commit(store)
+	})
+	return written, err
+}
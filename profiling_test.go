@@ -0,0 +1,37 @@
+package merkle_patricia_trie
+
+import (
+	"context"
+	"runtime/pprof"
+	"testing"
+)
+
+func TestMerklePatriciaTrie_Ctx(t *testing.T) {
+	hs := hashService(t)
+
+	trie := NewMerklePatriciaTrie(hs)
+	pprof.Do(context.Background(), pprof.Labels("service", "test"), func(ctx context.Context) {
+		if err := trie.InsertCtx(ctx, []byte("dog"), []byte("value")); err != nil {
+			t.Fatal(err)
+		}
+		if prev, existed, err := trie.PutCtx(ctx, []byte("dog"), []byte("other")); err != nil || !existed || string(prev) != "value" {
+			t.Errorf("Unexpected previous value = <%s>, err: %v", prev, err)
+		}
+		path, err := trie.FindMerklePathCtx(ctx, []byte("dog"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := VerifyMerklePath(hs, trie.RootHash(), []byte("dog"), []byte("other"), path); err != nil {
+			t.Error(err)
+		}
+		if written, err := trie.CommitCtx(ctx, NewMemoryNodeStore()); err != nil || written == 0 {
+			t.Errorf("Unexpected number of nodes written = %d, err: %v", written, err)
+		}
+		if removed, err := trie.RemoveCtx(ctx, []byte("dog")); err != nil || string(removed) != "other" {
+			t.Errorf("Unexpected removed value = <%s>, err: %v", removed, err)
+		}
+		if err := trie.DeleteCtx(ctx, []byte("dog")); err == nil {
+			t.Error("Deleting a removed key must fail")
+		}
+	})
+}
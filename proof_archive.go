@@ -0,0 +1,107 @@
+package merkle_patricia_trie
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"github.com/example/infra/db/merkle_patricia_trie/trie"
+	"github.com/pkg/errors"
+)
+
+// ProofArchive keeps proofs generated under past roots, so audits of a historical root can be served
+// after the trie has moved on. Proofs of the retention most recently archived roots are kept;
+// archiving under one more root expires every proof of the oldest.
+type ProofArchive struct {
+	retention int
+	// Hex roots in archive order, oldest first
+	roots []string
+	// Encoded paths by hex root and hex key
+	proofs map[string]map[string][]byte
+}
+
+func NewProofArchive(retention int) *ProofArchive {
+	if retention <= 0 {
+		panic("retention of ProofArchive must be positive")
+	}
+	return &ProofArchive{retention: retention, proofs: make(map[string]map[string][]byte)}
+}
+
+// Archive proves every key under the current root of mt and stores the proofs.
+// Nothing is stored if any key cannot be proven.
+func (pa *ProofArchive) Archive(mt *MerklePatriciaTrie, keys [][]byte) error {
+	encoded := make(map[string][]byte, len(keys))
+	for _, key := range keys {
+		path, err := mt.FindMerklePath(key)
+		if err != nil {
+			return errors.Wrapf(err, "failed to prove key = <%x>", key)
+		}
+		data, err := path.MarshalBinary()
+		if err != nil {
+			return err
+		}
+		encoded[hex.EncodeToString(key)] = data
+	}
+
+	root := mt.RootHashHex()
+	byKey, ok := pa.proofs[root]
+	if ok {
+		// A root archived again becomes the most recent one
+		pa.removeRoot(root)
+	} else {
+		byKey = make(map[string][]byte, len(encoded))
+		pa.proofs[root] = byKey
+	}
+	pa.roots = append(pa.roots, root)
+	for len(pa.roots) > pa.retention {
+		delete(pa.proofs, pa.roots[0])
+		pa.roots = pa.roots[1:]
+	}
+	for ek, data := range encoded {
+		byKey[ek] = data
+	}
+	return nil
+}
+
+func (pa *ProofArchive) Get(root trie.HashBlob, key []byte) (MerklePath, error) {
+	byKey, ok := pa.proofs[hex.EncodeToString(root)]
+	if !ok {
+		return nil, fmt.Errorf("root = <%x> is not archived", root)
+	}
+	data, ok := byKey[hex.EncodeToString(key)]
+	if !ok {
+		return nil, fmt.Errorf("no proof of key = <%x> under root = <%x>", key, root)
+	}
+	var path MerklePath
+	if err := path.UnmarshalBinary(data); err != nil {
+		return nil, err
+	}
+	return path, nil
+}
+
+// Expire drops every proof of root, e.g. once the root leaves the retention of its owner.
+func (pa *ProofArchive) Expire(root trie.HashBlob) {
+	r := hex.EncodeToString(root)
+	if _, ok := pa.proofs[r]; !ok {
+		return
+	}
+	delete(pa.proofs, r)
+	pa.removeRoot(r)
+}
+
+func (pa *ProofArchive) removeRoot(r string) {
+	for i := range pa.roots {
+		if pa.roots[i] == r {
+			pa.roots = append(pa.roots[:i], pa.roots[i+1:]...)
+			break
+		}
+	}
+}
+
+// Roots returns the archived roots, oldest first.
+func (pa *ProofArchive) Roots() []trie.HashBlob {
+	roots := make([]trie.HashBlob, len(pa.roots))
+	for i, r := range pa.roots {
+		roots[i], _ = hex.DecodeString(r)
+	}
+	return roots
+}
@@ -0,0 +1,94 @@
+package merkle_patricia_trie
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestProofArchive(t *testing.T) {
+	hs := hashService(t)
+
+	mt := NewMerklePatriciaTrie(hs)
+	pa := NewProofArchive(2)
+	var roots [][]byte
+	for _, key := range []string{"key", "key123", "key12ab"} {
+		if err := mt.Insert([]byte(key), []byte("v"+key)); err != nil {
+			t.Fatal(err)
+		}
+		if err := pa.Archive(mt, [][]byte{[]byte("key"), []byte(key)}); err != nil {
+			t.Fatal(err)
+		}
+		roots = append(roots, mt.RootHash())
+	}
+
+	{
+		t.Log("Proofs of retained roots are served")
+
+		for _, r := range []struct {
+			root []byte
+			key  string
+		}{{roots[1], "key"}, {roots[1], "key123"}, {roots[2], "key12ab"}} {
+			path, err := pa.Get(r.root, []byte(r.key))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := VerifyMerklePath(hs, r.root, []byte(r.key), []byte("v"+r.key), path); err != nil {
+				t.Errorf("Archived proof of %s does not verify: %s", r.key, err)
+			}
+		}
+		if _, err := pa.Get(roots[1], []byte("key12ab")); err == nil {
+			t.Error("Key proven under another root only must not be served")
+		}
+	}
+	{
+		t.Log("Roots beyond the retention expire")
+
+		if _, err := pa.Get(roots[0], []byte("key")); err == nil {
+			t.Error("Proof of the expired root must not be served")
+		}
+		if got := pa.Roots(); len(got) != 2 || !bytes.Equal(got[0], roots[1]) || !bytes.Equal(got[1], roots[2]) {
+			t.Errorf("Unexpected archived roots. got = %x", got)
+		}
+		pa.Expire(roots[1])
+		if _, err := pa.Get(roots[1], []byte("key")); err == nil {
+			t.Error("Proof of an expired root must not be served")
+		}
+		if len(pa.Roots()) != 1 {
+			t.Errorf("Unexpected number of archived roots. got = %d, want = %d", len(pa.Roots()), 1)
+		}
+	}
+	{
+		t.Log("Archiving a root again makes it the most recent one")
+
+		pa := NewProofArchive(2)
+		other := NewMerklePatriciaTrie(hs)
+		if err := other.Insert([]byte("dog"), []byte("vdog")); err != nil {
+			t.Fatal(err)
+		}
+		for _, a := range []struct {
+			mt  *MerklePatriciaTrie
+			key string
+		}{{other, "dog"}, {mt, "key"}, {other, "dog"}, {NewMerklePatriciaTrie(hs), ""}} {
+			var keys [][]byte
+			if a.key != "" {
+				keys = append(keys, []byte(a.key))
+			}
+			if err := pa.Archive(a.mt, keys); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, err := pa.Get(mt.RootHash(), []byte("key")); err == nil {
+			t.Error("Least recently archived root must expire first")
+		}
+		if _, err := pa.Get(other.RootHash(), []byte("dog")); err != nil {
+			t.Errorf("Root archived again must be retained: %s", err)
+		}
+	}
+	{
+		t.Log("Unprovable keys are not archived")
+
+		if err := pa.Archive(mt, [][]byte{[]byte("key"), []byte("nokey")}); err == nil {
+			t.Error("Archiving a missing key must fail")
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package merkle_patricia_trie
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/example/infra/db/merkle_patricia_trie/mptproof"
+	"github.com/example/infra/db/merkle_patricia_trie/trie"
+	"github.com/example/service/crypto"
+)
+
+// A range proof is a MultiProof which expands every node that may hold keys in [start, end)
+// and gives every other subtree by its hash, as mptproof.VerifyRange checks.
+
+func (mt *MerklePatriciaTrie) rangeProofInExtension(prefix, start, end string, node trie.NodeExtension) MultiProof {
+	mt.touch()
+	proofNode := extensionNode(node)
+	if !node.HasNext() {
+		return MultiProof{proofNode}
+	}
+	ek := prefix + node.Key()
+	if mptproof.OutsideRange(ek, start, end) {
+		proofNode.NextHash = node.Next().Hash()
+		return MultiProof{proofNode}
+	}
+
+	proofNode.NextFollows = true
+	var rest MultiProof
+	switch next := node.Next().(type) {
+	case trie.NodeExtension:
+		rest = mt.rangeProofInExtension(ek, start, end, next)
+	case trie.NodeBranch:
+		rest = mt.rangeProofInBranch(ek, start, end, next)
+	default:
+		panic("Unknown node type")
+	}
+	return append(MultiProof{proofNode}, rest...)
+}
+
+func (mt *MerklePatriciaTrie) rangeProofInBranch(prefix, start, end string, node trie.NodeBranch) MultiProof {
+	mt.touch()
+	var proofNode mptproof.MultiNode
+	var rest MultiProof
+	for i, c := range node.ListChildren() {
+		if c == nil {
+			continue
+		}
+		proofNode.Bitmap |= 1 << uint(i)
+		if mptproof.OutsideRange(prefix+"0123456789abcdef"[i:i+1], start, end) {
+			proofNode.Hashes = append(proofNode.Hashes, c.Hash())
+			continue
+		}
+		proofNode.Expanded |= 1 << uint(i)
+		rest = append(rest, mt.rangeProofInExtension(prefix, start, end, c)...)
+	}
+	return append(MultiProof{proofNode}, rest...)
+}
+
+// ProveRange returns a proof of all keys in [start, end) and their values.
+// A nil end leaves the range unbounded, so the next page of a download starts at the end of the previous one.
+// The proof is of the stored range, clamped to the KeyRange of the key transformer if it has one.
+func (mt *MerklePatriciaTrie) ProveRange(start, end []byte) (MultiProof, error) {
+	start, end, err := mt.forwardRange(start, end)
+	if err != nil {
+		return nil, err
+	}
+	return mt.rawRangeProof(start, end)
+}
+
+// rawRangeProof is ProveRange on the stored keys.
+func (mt *MerklePatriciaTrie) rawRangeProof(start, end []byte) (MultiProof, error) {
+	if end != nil && bytes.Compare(start, end) >= 0 {
+		return nil, fmt.Errorf("start of range must be less than its end")
+	}
+	if !branchSet(mt.root).isBranch() {
+		return MultiProof{}, nil
+	}
+	return mt.rangeProofInBranch("", hex.EncodeToString(start), hex.EncodeToString(end), mt.root), nil
+}
+
+// VerifyRange checks that proof holds every key in [start, end) under rootHash and returns those keys
+// in order with their values as committed, i.e. after the value transforms of the trie.
+func VerifyRange(hs crypto.Hash, rootHash trie.HashBlob, start, end []byte, proof MultiProof) ([]KV, error) {
+	proofPairs, err := mptproof.VerifyRange(hs, rootHash, start, end, proof)
+	if err != nil {
+		return nil, err
+	}
+	pairs := make([]KV, len(proofPairs))
+	for i, kv := range proofPairs {
+		pairs[i] = KV(kv)
+	}
+	return pairs, nil
+}
@@ -0,0 +1,85 @@
+package merkle_patricia_trie
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestMerklePatriciaTrie_ProveRange(t *testing.T) {
+	hs := hashService(t)
+
+	{
+		t.Log("Empty trie")
+
+		mt := NewMerklePatriciaTrie(hs)
+		proof, err := mt.ProveRange(nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pairs, err := VerifyRange(hs, mt.RootHash(), nil, nil, proof)
+		if err != nil || len(pairs) != 0 {
+			t.Errorf("Empty trie must prove an empty range. pairs: %d, err: %v", len(pairs), err)
+		}
+	}
+
+	mt := NewMerklePatriciaTrie(hs)
+	for _, key := range []string{"key", "key123", "key12ab", "dog", "doge", "cat", "k", "kk", "\x00", "\xff"} {
+		if err := mt.Insert([]byte(key), []byte("v"+key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	{
+		t.Log("Range proofs return exactly the keys of the range")
+
+		ranges := [][2][]byte{
+			{nil, nil},
+			{[]byte("d"), []byte("k")},
+			{[]byte("dog"), []byte("key12ab")},
+			{[]byte("key1"), []byte("key2")},
+			{[]byte("kk"), nil},
+			{[]byte("x"), []byte("y")},
+		}
+		for _, r := range ranges {
+			proof, err := mt.ProveRange(r[0], r[1])
+			if err != nil {
+				t.Fatal(err)
+			}
+			pairs, err := VerifyRange(hs, mt.RootHash(), r[0], r[1], proof)
+			if err != nil {
+				t.Errorf("Valid range proof of [%q, %q) is rejected: %s", r[0], r[1], err)
+				continue
+			}
+			var want []KV
+			for key, value := range mt.Range(r[0], r[1]) {
+				want = append(want, KV{Key: key, Value: value})
+			}
+			if len(pairs) != len(want) {
+				t.Errorf("Unexpected number of pairs in [%q, %q). got = %d, want = %d", r[0], r[1], len(pairs), len(want))
+				continue
+			}
+			for i := range want {
+				if !bytes.Equal(pairs[i].Key, want[i].Key) || !bytes.Equal(pairs[i].Value, want[i].Value) {
+					t.Errorf("Unexpected pair #%d in [%q, %q).\n  got = %q\n  want = %q", i, r[0], r[1], pairs[i], want[i])
+				}
+			}
+		}
+	}
+	{
+		t.Log("Proofs of another range or root are rejected")
+
+		proof, err := mt.ProveRange([]byte("key1"), []byte("key2"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := VerifyRange(hs, mt.RootHash(), []byte("cat"), []byte("key2"), proof); err == nil {
+			t.Error("Proof must not verify a wider range")
+		}
+		if _, err := VerifyRange(hs, NewMerklePatriciaTrie(hs).RootHash(), []byte("key1"), []byte("key2"), proof); err == nil {
+			t.Error("Proof must not verify against another root")
+		}
+		if _, err := mt.ProveRange([]byte("k"), []byte("k")); err == nil {
+			t.Error("Empty range must be rejected")
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package merkle_patricia_trie
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"github.com/example/infra/db/merkle_patricia_trie/mptproof"
+	"github.com/example/infra/db/merkle_patricia_trie/trie"
+	"github.com/example/service/crypto"
+	"github.com/pkg/errors"
+)
+
+// Roots and proof hashes are exchanged as 0x-prefixed lower case hex.
+// ParseRoot also accepts them without the prefix and in upper case.
+
+const rootPrefix = "0x"
+
+// HashSize returns the size in bytes of the digests produced by hs.
+func HashSize(hs crypto.Hash) (int, error) {
+	h, err := hs.Hash(nil)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to probe hash size")
+	}
+	if len(h) == 0 {
+		return 0, fmt.Errorf("hash service produced an empty digest")
+	}
+	return len(h), nil
+}
+
+func FormatRoot(root trie.HashBlob) string {
+	return rootPrefix + hex.EncodeToString(root)
+}
+
+func ParseRoot(s string) (trie.HashBlob, error) {
+	return mptproof.ParseRoot(s)
+}
+
+// ParseRootFor parses s like ParseRoot and also checks that it has the digest size of hs.
+func ParseRootFor(hs crypto.Hash, s string) (trie.HashBlob, error) {
+	size, err := HashSize(hs)
+	if err != nil {
+		return nil, err
+	}
+	root, err := ParseRoot(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(root) != size {
+		return nil, fmt.Errorf("root has %d bytes, want %d", len(root), size)
+	}
+	return root, nil
+}
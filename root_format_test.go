@@ -0,0 +1,54 @@
+package merkle_patricia_trie
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestHashSize(t *testing.T) {
+	hs := hashService(t)
+
+	size, err := HashSize(hs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != 32 {
+		t.Errorf("Unexpected hash size. got = %d, want = %d", size, 32)
+	}
+}
+
+func TestFormatRoot(t *testing.T) {
+	hs := hashService(t)
+
+	mt := NewMerklePatriciaTrie(hs)
+	if err := mt.Insert([]byte("key"), []byte("value")); err != nil {
+		t.Fatal(err)
+	}
+	s := FormatRoot(mt.RootHash())
+	if s != "0x"+mt.RootHashHex() {
+		t.Errorf("Unexpected formatted root.\n  got = %s\n  want = %s", s, "0x"+mt.RootHashHex())
+	}
+
+	for _, in := range []string{s, mt.RootHashHex(), "0X" + mt.RootHashHex()} {
+		root, err := ParseRoot(in)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if !bytes.Equal(root, mt.RootHash()) {
+			t.Errorf("Root <%s> does not round trip", in)
+		}
+	}
+	if _, err := ParseRootFor(hs, s); err != nil {
+		t.Error(err)
+	}
+
+	for _, in := range []string{"", "0x", "0x0", "0xzz", "0x0x00"} {
+		if _, err := ParseRoot(in); err == nil {
+			t.Errorf("Invalid root <%s> must be rejected", in)
+		}
+	}
+	if _, err := ParseRootFor(hs, "0x00"); err == nil {
+		t.Error("Root of wrong size must be rejected")
+	}
+}
@@ -0,0 +1,120 @@
+package merkle_patricia_trie
+
+import (
+	"math/rand"
+
+	"github.com/example/infra/db/merkle_patricia_trie/trie"
+)
+
+// Sample is a key picked by SampleKeys with its value and inclusion proof.
+type Sample struct {
+	Key   []byte
+	Value []byte
+	Path  MerklePath
+}
+
+// sampler descends the trie along random choices and remembers what is used up:
+// sampled values and subtrees without values left, both by the nibble path to them.
+type sampler struct {
+	mt        *MerklePatriciaTrie
+	rng       *rand.Rand
+	sampled   map[string]bool
+	exhausted map[string]bool
+}
+
+// descendInExtension returns the path to an unsampled value under node, or false after marking node exhausted.
+func (s *sampler) descendInExtension(path string, node trie.NodeExtension) (string, bool) {
+	s.mt.touch()
+	path += node.Key()
+	for {
+		var choices []func() (string, bool)
+		if node.HasValueObject() && !s.sampled[path] {
+			choices = append(choices, func() (string, bool) { return path, true })
+		}
+		if node.HasNext() {
+			switch next := node.Next().(type) {
+			case trie.NodeExtension:
+				if !s.exhausted[path+next.Key()] {
+					choices = append(choices, func() (string, bool) { return s.descendInExtension(path, next) })
+				}
+			case trie.NodeBranch:
+				if !s.exhausted[path+"/"] {
+					choices = append(choices, func() (string, bool) { return s.descendInBranch(path+"/", next) })
+				}
+			default:
+				panic("Unknown node type")
+			}
+		}
+		if len(choices) == 0 {
+			s.exhausted[path] = true
+			return "", false
+		}
+		if found, ok := choices[s.rng.Intn(len(choices))](); ok {
+			return found, true
+		}
+	}
+}
+
+// descendInBranch takes the path to node suffixed with "/", as a branch shares its nibble path with the extension above it.
+func (s *sampler) descendInBranch(path string, node trie.NodeBranch) (string, bool) {
+	s.mt.touch()
+	prefix := path[:len(path)-1]
+	for {
+		var children []trie.NodeExtension
+		for _, child := range node.ListChildren() {
+			if child != nil && !s.exhausted[prefix+child.Key()] {
+				children = append(children, child)
+			}
+		}
+		if len(children) == 0 {
+			s.exhausted[path] = true
+			return "", false
+		}
+		if found, ok := s.descendInExtension(prefix, children[s.rng.Intn(len(children))]); ok {
+			return found, true
+		}
+	}
+}
+
+// SampleKeys picks n distinct keys by descending from the root with a choice uniform among the children
+// of every branch, and between the value and the next node of every extension holding both.
+// The same seed picks the same keys from the same trie, so an auditor can reproduce the choice
+// and check the samples against a published root. Keys under sparse subtrees are more likely
+// picked than keys under dense ones. A trie with fewer than n keys returns all of them.
+// Keys which the key transformer fails to invert are passed over. Value is the value Get returns
+// and Path proves the stored value, as the one of FindMerklePath.
+func (mt *MerklePatriciaTrie) SampleKeys(seed int64, n int) ([]Sample, error) {
+	s := &sampler{
+		mt:        mt,
+		rng:       rand.New(rand.NewSource(seed)),
+		sampled:   make(map[string]bool),
+		exhausted: make(map[string]bool),
+	}
+	var samples []Sample
+	for len(samples) < n {
+		path, ok := s.descendInBranch("/", mt.root)
+		if !ok {
+			break
+		}
+		s.sampled[path] = true
+
+		stored := decodePath(path)
+		key, err := mt.inverseKey(stored)
+		if err == ErrForeignKey {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		proof, err := mt.findMerklePath(stored)
+		if err != nil {
+			return nil, err
+		}
+		value, err := mt.inverseValue(stored, append([]byte(nil), proof[0].value.Value()...))
+		if err != nil {
+			return nil, err
+		}
+		samples = append(samples, Sample{Key: key, Value: value, Path: proof})
+	}
+	return samples, nil
+}
@@ -0,0 +1,84 @@
+package merkle_patricia_trie
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestMerklePatriciaTrie_SampleKeys(t *testing.T) {
+	hs := hashService(t)
+
+	mt := NewMerklePatriciaTrie(hs)
+	for i := 0; i < 200; i++ {
+		if err := mt.Insert([]byte(fmt.Sprintf("key%d", i)), []byte(fmt.Sprintf("value%d", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	{
+		t.Log("Samples are distinct, proven and the same for the same seed")
+		samples, err := mt.SampleKeys(42, 20)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(samples) != 20 {
+			t.Fatalf("Unexpected number of samples = %d", len(samples))
+		}
+		seen := make(map[string]bool)
+		for _, s := range samples {
+			if seen[string(s.Key)] {
+				t.Errorf("Key = <%s> is sampled twice", s.Key)
+			}
+			seen[string(s.Key)] = true
+			if err := VerifyMerklePath(hs, mt.RootHash(), s.Key, s.Value, s.Path); err != nil {
+				t.Errorf("Proof of key = <%s> is rejected: %s", s.Key, err)
+			}
+		}
+		again, err := mt.SampleKeys(42, 20)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := range samples {
+			if !bytes.Equal(samples[i].Key, again[i].Key) {
+				t.Fatalf("Samples differ for the same seed\n  got = %s\n  want = %s", again[i].Key, samples[i].Key)
+			}
+		}
+		other, err := mt.SampleKeys(7, 20)
+		if err != nil {
+			t.Fatal(err)
+		}
+		same := true
+		for i := range samples {
+			same = same && bytes.Equal(samples[i].Key, other[i].Key)
+		}
+		if same {
+			t.Error("Samples must differ between seeds")
+		}
+	}
+
+	{
+		t.Log("Keys on the path to other keys are sampled, and small tries are sampled whole")
+		small := NewMerklePatriciaTrie(hs)
+		for _, key := range []string{"a", "ab", "abc", "b"} {
+			if err := small.Insert([]byte(key), []byte(key)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		samples, err := small.SampleKeys(1, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(samples) != 4 {
+			t.Errorf("Every key must be sampled, got %d samples", len(samples))
+		}
+		for _, s := range samples {
+			if !bytes.Equal(s.Key, s.Value) {
+				t.Errorf("Unexpected value = <%s> of key = <%s>", s.Value, s.Key)
+			}
+		}
+		if samples, err := NewMerklePatriciaTrie(hs).SampleKeys(1, 10); err != nil || len(samples) != 0 {
+			t.Errorf("Empty trie has no samples, got %d, err: %v", len(samples), err)
+		}
+	}
+}
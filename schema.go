@@ -0,0 +1,161 @@
+package merkle_patricia_trie
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// Codec converts the values of one table from and to the bytes stored in the trie.
+type Codec[T any] interface {
+	Encode(value T) ([]byte, error)
+	Decode(data []byte) (T, error)
+}
+
+// JSONCodec stores values as JSON.
+type JSONCodec[T any] struct{}
+
+func (JSONCodec[T]) Encode(value T) ([]byte, error) {
+	return json.Marshal(value)
+}
+
+func (JSONCodec[T]) Decode(data []byte) (T, error) {
+	var value T
+	err := json.Unmarshal(data, &value)
+	return value, err
+}
+
+// Schema maps key prefixes of a trie to the codecs of their tables.
+// No prefix may be a prefix of another, so every key belongs to at most one table.
+type Schema struct {
+	mt     *MerklePatriciaTrie
+	tables map[string]any
+}
+
+func NewSchema(mt *MerklePatriciaTrie) *Schema {
+	return &Schema{mt: mt, tables: make(map[string]any)}
+}
+
+// Register adds the table under prefix with its codec.
+func Register[T any](s *Schema, prefix []byte, codec Codec[T]) error {
+	if len(prefix) == 0 {
+		return ErrEmptyKey
+	}
+	for p := range s.tables {
+		if bytes.HasPrefix(prefix, []byte(p)) || bytes.HasPrefix([]byte(p), prefix) {
+			return fmt.Errorf("prefix = <%x> overlaps the table at <%x>", prefix, p)
+		}
+	}
+	s.tables[string(prefix)] = codec
+	return nil
+}
+
+// Table reads and writes the values of one table as T. Its keys are relative to the prefix of the table.
+type Table[T any] struct {
+	mt     *MerklePatriciaTrie
+	prefix []byte
+	codec  Codec[T]
+}
+
+// Typed returns the table registered under prefix. It fails if there is none or its values are not T.
+func Typed[T any](s *Schema, prefix []byte) (*Table[T], error) {
+	c, ok := s.tables[string(prefix)]
+	if !ok {
+		return nil, fmt.Errorf("no table at prefix = <%x>", prefix)
+	}
+	codec, ok := c.(Codec[T])
+	if !ok {
+		var zero T
+		return nil, fmt.Errorf("table at prefix = <%x> does not hold %T", prefix, zero)
+	}
+	return &Table[T]{mt: s.mt, prefix: append([]byte(nil), prefix...), codec: codec}, nil
+}
+
+func (tb *Table[T]) key(key []byte) ([]byte, error) {
+	if len(key) == 0 {
+		return nil, ErrEmptyKey
+	}
+	return append(append([]byte(nil), tb.prefix...), key...), nil
+}
+
+func (tb *Table[T]) Get(key []byte) (T, error) {
+	var zero T
+	k, err := tb.key(key)
+	if err != nil {
+		return zero, err
+	}
+	data, err := tb.mt.Get(k)
+	if err != nil {
+		return zero, err
+	}
+	value, err := tb.codec.Decode(data)
+	if err != nil {
+		return zero, errors.Wrapf(err, "failed to decode value of key = <%x>", key)
+	}
+	return value, nil
+}
+
+func (tb *Table[T]) encode(key []byte, value T) ([]byte, []byte, error) {
+	k, err := tb.key(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err := tb.codec.Encode(value)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to encode value of key = <%x>", key)
+	}
+	return k, data, nil
+}
+
+// Insert stores value at key. It fails if key already exists.
+func (tb *Table[T]) Insert(key []byte, value T) error {
+	k, data, err := tb.encode(key, value)
+	if err != nil {
+		return err
+	}
+	return tb.mt.Insert(k, data)
+}
+
+// Put stores value at key, overwriting the existing value if any.
+func (tb *Table[T]) Put(key []byte, value T) error {
+	k, data, err := tb.encode(key, value)
+	if err != nil {
+		return err
+	}
+	_, _, err = tb.mt.Put(k, data)
+	return err
+}
+
+func (tb *Table[T]) Has(key []byte) (bool, error) {
+	k, err := tb.key(key)
+	if err != nil {
+		return false, err
+	}
+	return tb.mt.Has(k)
+}
+
+func (tb *Table[T]) Delete(key []byte) error {
+	k, err := tb.key(key)
+	if err != nil {
+		return err
+	}
+	return tb.mt.Delete(k)
+}
+
+// Walk calls fn for every key/value pair of the table in key order until fn returns false.
+func (tb *Table[T]) Walk(fn func(key []byte, value T) bool) error {
+	it := tb.mt.NewPrefixIterator(tb.prefix)
+	for it.Next() {
+		key := it.Key()[len(tb.prefix):]
+		value, err := tb.codec.Decode(it.Value())
+		if err != nil {
+			return errors.Wrapf(err, "failed to decode value of key = <%x>", key)
+		}
+		if !fn(key, value) {
+			return nil
+		}
+	}
+	return it.Err()
+}
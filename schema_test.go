@@ -0,0 +1,107 @@
+package merkle_patricia_trie
+
+import (
+	"errors"
+	"testing"
+)
+
+type testAccount struct {
+	Name    string `json:"name"`
+	Balance int    `json:"balance"`
+}
+
+type stringCodec struct{}
+
+func (stringCodec) Encode(value string) ([]byte, error) {
+	return []byte(value), nil
+}
+
+func (stringCodec) Decode(data []byte) (string, error) {
+	return string(data), nil
+}
+
+func TestSchema(t *testing.T) {
+	hs := hashService(t)
+
+	mt := NewMerklePatriciaTrie(hs)
+	s := NewSchema(mt)
+	if err := Register[testAccount](s, []byte("acct/"), JSONCodec[testAccount]{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Register[string](s, []byte("name/"), stringCodec{}); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{"acct/x/", "acc"} {
+		if err := Register[string](s, []byte(p), stringCodec{}); err == nil {
+			t.Errorf("Overlapping prefix <%s> must be rejected", p)
+		}
+	}
+	if err := Register[string](s, nil, stringCodec{}); !errors.Is(err, ErrEmptyKey) {
+		t.Errorf("Empty prefix must be rejected with ErrEmptyKey. err: %v", err)
+	}
+
+	accounts, err := Typed[testAccount](s, []byte("acct/"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	names, err := Typed[string](s, []byte("name/"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Typed[string](s, []byte("acct/")); err == nil {
+		t.Error("Table must not be typed with another value type")
+	}
+	if _, err := Typed[string](s, []byte("none/")); err == nil {
+		t.Error("Unregistered table must be rejected")
+	}
+
+	if err := accounts.Insert([]byte("alice"), testAccount{"Alice", 10}); err != nil {
+		t.Fatal(err)
+	}
+	if err := accounts.Put([]byte("bob"), testAccount{"Bob", 5}); err != nil {
+		t.Fatal(err)
+	}
+	if err := names.Put([]byte("alice"), "Alice"); err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := accounts.Get([]byte("alice"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a != (testAccount{"Alice", 10}) {
+		t.Errorf("Unexpected account. got = %+v", a)
+	}
+	raw, err := mt.Get([]byte("acct/alice"))
+	if err != nil || string(raw) != `{"name":"Alice","balance":10}` {
+		t.Errorf("Value must be stored under the prefix with its codec. value: %s, err: %v", raw, err)
+	}
+
+	var keys []string
+	if err := accounts.Walk(func(key []byte, value testAccount) bool {
+		keys = append(keys, string(key)+"="+value.Name)
+		return true
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 || keys[0] != "alice=Alice" || keys[1] != "bob=Bob" {
+		t.Errorf("Walk must cover only its table. got = %q", keys)
+	}
+
+	if err := accounts.Delete([]byte("alice")); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := accounts.Has([]byte("alice")); ok {
+		t.Error("Deleted key must be gone")
+	}
+	if ok, _ := names.Has([]byte("alice")); !ok {
+		t.Error("Keys of other tables must stay")
+	}
+
+	if err := mt.Insert([]byte("acct/broken"), []byte("{")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := accounts.Get([]byte("broken")); err == nil {
+		t.Error("Undecodable value must be reported")
+	}
+}
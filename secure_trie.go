@@ -0,0 +1,117 @@
+package merkle_patricia_trie
+
+import (
+	"iter"
+
+	"github.com/example/infra/db/merkle_patricia_trie/trie"
+	"github.com/example/service/crypto"
+	"github.com/pkg/errors"
+)
+
+// SecureTrie keys the trie by the hash of every key, so paths have the depth of the digest
+// whatever keys an attacker chooses. It keeps the preimages of the stored keys in memory
+// to hand the original keys out again.
+// Proofs are of the hashed keys; verifiers hash the key with HashKey first.
+type SecureTrie struct {
+	mt        *MerklePatriciaTrie
+	preimages map[string][]byte
+}
+
+func NewSecureMerklePatriciaTrie(hs crypto.Hash) *SecureTrie {
+	return &SecureTrie{mt: NewMerklePatriciaTrie(hs), preimages: make(map[string][]byte)}
+}
+
+// HashKey returns the key key is stored at in a SecureTrie using hs.
+func HashKey(hs crypto.Hash, key []byte) ([]byte, error) {
+	if len(key) == 0 {
+		return nil, ErrEmptyKey
+	}
+	h, err := hs.Hash(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to hash key")
+	}
+	return h, nil
+}
+
+func (st *SecureTrie) hashKey(key []byte) ([]byte, error) {
+	return HashKey(st.mt.hs, key)
+}
+
+func (st *SecureTrie) Insert(key []byte, value []byte) error {
+	hk, err := st.hashKey(key)
+	if err != nil {
+		return err
+	}
+	if err := st.mt.Insert(hk, value); err != nil {
+		return err
+	}
+	st.preimages[string(hk)] = append([]byte(nil), key...)
+	return nil
+}
+
+// Put stores value at key, overwriting the existing value if any, and returns the previous value
+// and whether key existed.
+func (st *SecureTrie) Put(key []byte, value []byte) (prev []byte, existed bool, err error) {
+	hk, err := st.hashKey(key)
+	if err != nil {
+		return nil, false, err
+	}
+	prev, existed, err = st.mt.Put(hk, value)
+	if err != nil {
+		return nil, false, err
+	}
+	st.preimages[string(hk)] = append([]byte(nil), key...)
+	return prev, existed, nil
+}
+
+func (st *SecureTrie) Get(key []byte) ([]byte, error) {
+	hk, err := st.hashKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return st.mt.Get(hk)
+}
+
+func (st *SecureTrie) Has(key []byte) (bool, error) {
+	hk, err := st.hashKey(key)
+	if err != nil {
+		return false, err
+	}
+	return st.mt.Has(hk)
+}
+
+func (st *SecureTrie) Delete(key []byte) error {
+	hk, err := st.hashKey(key)
+	if err != nil {
+		return err
+	}
+	if err := st.mt.Delete(hk); err != nil {
+		return err
+	}
+	delete(st.preimages, string(hk))
+	return nil
+}
+
+// FindMerklePath returns the path of the hashed key.
+func (st *SecureTrie) FindMerklePath(key []byte) (MerklePath, error) {
+	hk, err := st.hashKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return st.mt.FindMerklePath(hk)
+}
+
+func (st *SecureTrie) RootHash() trie.HashBlob {
+	return st.mt.RootHash()
+}
+
+// All returns every pair with its original key, in the order of the hashed keys.
+func (st *SecureTrie) All() iter.Seq2[[]byte, []byte] {
+	return func(yield func([]byte, []byte) bool) {
+		for hk, value := range st.mt.All() {
+			if !yield(st.preimages[string(hk)], value) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package merkle_patricia_trie
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestSecureTrie(t *testing.T) {
+	hs := hashService(t)
+
+	st := NewSecureMerklePatriciaTrie(hs)
+	keys := []string{"key", "key123", "key12ab", "dog", strings.Repeat("a", 200)}
+	for _, key := range keys {
+		if err := st.Insert([]byte(key), []byte("v"+key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := st.Insert([]byte("key"), []byte("again")); err == nil {
+		t.Error("Existing key must be rejected")
+	}
+
+	{
+		t.Log("Keys are stored by their hash")
+		path, err := st.FindMerklePath([]byte(keys[4]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		hk, err := HashKey(hs, []byte(keys[4]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if key, _ := path.Key(); !bytes.Equal(key, hk) {
+			t.Errorf("Path must prove the hashed key.\n  got = %x\n  want = %x", key, hk)
+		}
+		if err := VerifyMerklePath(hs, st.RootHash(), hk, []byte("v"+keys[4]), path); err != nil {
+			t.Error(err)
+		}
+		value, err := st.Get([]byte("dog"))
+		if err != nil || string(value) != "vdog" {
+			t.Errorf("Unexpected value. value: %s, err: %v", value, err)
+		}
+	}
+
+	{
+		t.Log("Iteration hands out the original keys")
+		prev, existed, err := st.Put([]byte("dog"), []byte("woof"))
+		if err != nil || !existed || string(prev) != "vdog" {
+			t.Errorf("Unexpected previous value. value: %s, err: %v", prev, err)
+		}
+		if err := st.Delete([]byte("key")); err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for key := range st.All() {
+			got = append(got, string(key))
+		}
+		sort.Strings(got)
+		want := append([]string{}, keys[1:]...)
+		sort.Strings(want)
+		if strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Errorf("Unexpected keys.\n  got = %q\n  want = %q", got, want)
+		}
+		if ok, _ := st.Has([]byte("key")); ok {
+			t.Error("Deleted key must be gone")
+		}
+	}
+}
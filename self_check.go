@@ -0,0 +1,120 @@
+package merkle_patricia_trie
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+
+	"github.com/example/infra/db/merkle_patricia_trie/trie"
+	"github.com/pkg/errors"
+)
+
+func (mt *MerklePatriciaTrie) checkNodeHash(path string, node trie.Node) error {
+	s, err := node.Serialize()
+	if err != nil {
+		return errors.Wrapf(err, "failed to serialize node at path = <%s>", path)
+	}
+	h, err := mt.hs.Hash(s)
+	if err != nil {
+		return errors.Wrapf(err, "failed to hash node at path = <%s>", path)
+	}
+	if !bytes.Equal(h, node.Hash()) {
+		return fmt.Errorf("hash mismatch at path = <%s>", path)
+	}
+	return nil
+}
+
+// VerifySample recomputes the node hashes along n pseudo-random root-to-value paths chosen by seed,
+// as a cheap check for corrupted nodes before serving a trie.
+// It returns an error describing every mismatching node.
+func (mt *MerklePatriciaTrie) VerifySample(n int, seed int64) error {
+	rnd := rand.New(rand.NewSource(seed))
+	checked := make(map[trie.Node]struct{})
+	var anomalies []error
+	check := func(path string, node trie.Node) {
+		if _, ok := checked[node]; ok {
+			return
+		}
+		checked[node] = struct{}{}
+		if err := mt.checkNodeHash(path, node); err != nil {
+			anomalies = append(anomalies, err)
+		}
+	}
+
+	for i := 0; i < n; i++ {
+		path := ""
+		var node trie.Node = mt.root
+		for node != nil {
+			check(path, node)
+			switch current := node.(type) {
+			case trie.NodeBranch:
+				if current.Count() == 0 {
+					node = nil
+					break
+				}
+				var children []trie.NodeExtension
+				for _, child := range current.ListChildren() {
+					if child != nil {
+						children = append(children, child)
+					}
+				}
+				node = children[rnd.Intn(len(children))]
+			case trie.NodeExtension:
+				path += current.Key()
+				if !current.HasNext() || (current.HasValueObject() && rnd.Intn(2) == 0) {
+					node = nil
+					break
+				}
+				node = current.Next()
+			default:
+				panic("Unknown node type")
+			}
+		}
+	}
+
+	if len(anomalies) == 0 {
+		return nil
+	}
+	bf := bytes.NewBufferString(fmt.Sprintf("%d corrupted node(s) found", len(anomalies)))
+	for _, a := range anomalies {
+		bf.WriteString("; " + a.Error())
+	}
+	return fmt.Errorf("%s", bf.String())
+}
+
+// CheckOrderIndependence inserts kvs into fresh tries configured like mt, once in the given order
+// and then in trials shuffled orders, and fails unless every root hash is the same.
+// Users plugging in their own hash service or value transforms can run it to confirm
+// their configuration still yields deterministic commitments.
+func (mt *MerklePatriciaTrie) CheckOrderIndependence(kvs []KV, trials int) error {
+	build := func(order []KV) (trie.HashBlob, error) {
+		fresh := NewMerklePatriciaTrie(mt.hs)
+		fresh.Use(mt.transforms...)
+		fresh.UseKeys(mt.keys)
+		fresh.envelopes = mt.envelopes
+		for _, kv := range order {
+			if err := fresh.Insert(kv.Key, kv.Value); err != nil {
+				return nil, errors.Wrapf(err, "failed to insert key = <%x>", kv.Key)
+			}
+		}
+		return fresh.RootHash(), nil
+	}
+
+	want, err := build(kvs)
+	if err != nil {
+		return err
+	}
+	rnd := rand.New(rand.NewSource(1))
+	order := append([]KV(nil), kvs...)
+	for i := 0; i < trials; i++ {
+		rnd.Shuffle(len(order), func(a, b int) { order[a], order[b] = order[b], order[a] })
+		got, err := build(order)
+		if err != nil {
+			return errors.Wrapf(err, "trial #%d", i)
+		}
+		if !bytes.Equal(got, want) {
+			return fmt.Errorf("root hash of trial #%d differs. got = %x, want = %x", i, got, want)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package merkle_patricia_trie
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/example/infra/db/merkle_patricia_trie/trie"
+)
+
+func TestMerklePatriciaTrie_VerifySample(t *testing.T) {
+	hs := hashService(t)
+
+	mt := NewMerklePatriciaTrie(hs)
+	if err := mt.VerifySample(10, 1); err != nil {
+		t.Errorf("Empty trie must pass. err: %v", err)
+	}
+	for i := 0; i < 100; i++ {
+		if err := mt.Insert([]byte(fmt.Sprintf("key%d", i)), []byte("value")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := mt.VerifySample(50, 1); err != nil {
+		t.Errorf("Consistent trie must pass. err: %v", err)
+	}
+
+	// Corrupt a node below the root without rehashing it
+	child := mt.root.First()
+	child.SetValueObject(trie.NewValueObject([]byte("corrupted")))
+	if err := mt.VerifySample(50, 1); err == nil {
+		t.Error("Corrupted node must be reported")
+	}
+}
+
+// countingTransform tags every value with a running counter, so the committed values depend on the insertion order.
+type countingTransform struct {
+	n *int
+}
+
+func (t countingTransform) Forward(key, value []byte) ([]byte, error) {
+	*t.n++
+	return append([]byte(fmt.Sprintf("%d:", *t.n)), value...), nil
+}
+
+func (t countingTransform) Inverse(key, value []byte) ([]byte, error) {
+	return value, nil
+}
+
+func TestMerklePatriciaTrie_CheckOrderIndependence(t *testing.T) {
+	hs := hashService(t)
+
+	var kvs []KV
+	for _, key := range []string{"key", "key123", "key12ab", "dog", "doge", "cat", "k", "kk"} {
+		kvs = append(kvs, KV{Key: []byte(key), Value: []byte("v" + key)})
+	}
+
+	mt := NewMerklePatriciaTrie(hs)
+	mt.Use(prefixTransform{[]byte("p:")})
+	if err := mt.CheckOrderIndependence(kvs, 20); err != nil {
+		t.Errorf("Deterministic configuration must pass. err: %v", err)
+	}
+
+	mt = NewMerklePatriciaTrie(hs)
+	mt.Use(countingTransform{new(int)})
+	if err := mt.CheckOrderIndependence(kvs, 20); err == nil {
+		t.Error("Order dependent configuration must be reported")
+	}
+
+	if err := mt.CheckOrderIndependence(append(kvs, kvs[0]), 1); err == nil {
+		t.Error("Duplicate keys must be reported")
+	}
+}
@@ -0,0 +1,111 @@
+package merkle_patricia_trie
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/example/infra/db/merkle_patricia_trie/trie"
+	"github.com/example/service/crypto"
+	"github.com/pkg/errors"
+)
+
+// Signer signs a root hash with the key of the service distributing it.
+type Signer interface {
+	Sign(message []byte) ([]byte, error)
+}
+
+// SignatureVerifier checks a signature of Signer.
+type SignatureVerifier interface {
+	Verify(message, signature []byte) error
+}
+
+// signedRootDomain separates signatures of roots from anything else the same key signs.
+const signedRootDomain = "merkle-patricia-trie/root/v1:"
+
+func signedRootMessage(root trie.HashBlob) []byte {
+	return append([]byte(signedRootDomain), root...)
+}
+
+// SignedProof packages a root hash, a proof under it and a detached signature of the root,
+// so a commitment and the proofs against it travel together.
+// The key and the committed value are the ones carried by the path.
+type SignedProof struct {
+	Root      trie.HashBlob
+	Path      MerklePath
+	Signature []byte
+}
+
+// ProveSigned proves key under the current root and signs the root with s.
+func (mt *MerklePatriciaTrie) ProveSigned(s Signer, key []byte) (SignedProof, error) {
+	path, err := mt.FindMerklePath(key)
+	if err != nil {
+		return SignedProof{}, err
+	}
+	root := mt.RootHash()
+	sig, err := s.Sign(signedRootMessage(root))
+	if err != nil {
+		return SignedProof{}, errors.Wrap(err, "failed to sign root")
+	}
+	return SignedProof{Root: root, Path: path, Signature: sig}, nil
+}
+
+// Verify checks the signature of the root with v and then the proof against the root.
+func (sp SignedProof) Verify(hs crypto.Hash, v SignatureVerifier) error {
+	if err := v.Verify(signedRootMessage(sp.Root), sp.Signature); err != nil {
+		return errors.Wrap(err, "invalid signature of root")
+	}
+	key, err := sp.Path.Key()
+	if err != nil {
+		return err
+	}
+	return VerifyMerklePath(hs, sp.Root, key, sp.Path.Value(), sp.Path)
+}
+
+const signedProofVersion = 1
+
+// MarshalBinary encodes the bundle as a version byte and the uvarint-prefixed root, signature and binary path.
+func (sp SignedProof) MarshalBinary() ([]byte, error) {
+	path, err := sp.Path.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	b := []byte{signedProofVersion}
+	for _, field := range [][]byte{sp.Root, sp.Signature, path} {
+		b = binary.AppendUvarint(b, uint64(len(field)))
+		b = append(b, field...)
+	}
+	return b, nil
+}
+
+func (sp *SignedProof) UnmarshalBinary(data []byte) error {
+	r := bytes.NewReader(data)
+	version, err := r.ReadByte()
+	if err != nil {
+		return fmt.Errorf("signed proof is empty")
+	}
+	if version != signedProofVersion {
+		return fmt.Errorf("unsupported signed proof version %d", version)
+	}
+	var fields [3][]byte
+	for i := range fields {
+		n, err := binary.ReadUvarint(r)
+		if err != nil {
+			return errors.Wrapf(err, "failed to read field #%d of signed proof", i)
+		}
+		if n > uint64(r.Len()) {
+			return fmt.Errorf("field #%d of signed proof exceeds the remaining %d bytes", i, r.Len())
+		}
+		fields[i] = make([]byte, n)
+		r.Read(fields[i])
+	}
+	if r.Len() != 0 {
+		return fmt.Errorf("signed proof has %d trailing bytes", r.Len())
+	}
+	var path MerklePath
+	if err := path.UnmarshalBinary(fields[2]); err != nil {
+		return err
+	}
+	*sp = SignedProof{Root: fields[0], Path: path, Signature: fields[1]}
+	return nil
+}
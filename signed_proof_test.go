@@ -0,0 +1,89 @@
+package merkle_patricia_trie
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
+	"testing"
+)
+
+type hmacSigner struct {
+	secret []byte
+}
+
+func (s hmacSigner) Sign(message []byte) ([]byte, error) {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write(message)
+	return mac.Sum(nil), nil
+}
+
+func (s hmacSigner) Verify(message, signature []byte) error {
+	want, _ := s.Sign(message)
+	if !hmac.Equal(want, signature) {
+		return fmt.Errorf("signature mismatch")
+	}
+	return nil
+}
+
+func TestMerklePatriciaTrie_ProveSigned(t *testing.T) {
+	hs := hashService(t)
+
+	mt := NewMerklePatriciaTrie(hs)
+	for _, key := range []string{"key", "key123", "dog"} {
+		if err := mt.Insert([]byte(key), []byte("v"+key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	signer := hmacSigner{[]byte("secret")}
+	sp, err := mt.ProveSigned(signer, []byte("key123"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := sp.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded SignedProof
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := decoded.Verify(hs, signer); err != nil {
+		t.Errorf("Valid signed proof is rejected: %s", err)
+	}
+	if string(decoded.Path.Value()) != "vkey123" {
+		t.Errorf("Unexpected value.\n  got = %s\n  want = %s", decoded.Path.Value(), "vkey123")
+	}
+
+	if err := decoded.Verify(hs, hmacSigner{[]byte("other")}); err == nil {
+		t.Error("Signature of another signer must be rejected")
+	}
+	other, err := mt.FindMerklePath([]byte("dog"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged := decoded
+	forged.Path = other[:len(other)-1]
+	if err := forged.Verify(hs, signer); err == nil {
+		t.Error("Truncated path must be rejected")
+	}
+	if err := mt.Insert([]byte("cat"), []byte("vcat")); err != nil {
+		t.Fatal(err)
+	}
+	forged.Root = mt.RootHash()
+	forged.Path = decoded.Path
+	if err := forged.Verify(hs, signer); err == nil {
+		t.Error("Root without signature must be rejected")
+	}
+
+	for name, bad := range map[string][]byte{
+		"empty":     {},
+		"version":   append([]byte{0x02}, data[1:]...),
+		"truncated": data[:len(data)-1],
+		"trailing":  append(append([]byte(nil), data...), 0x00),
+	} {
+		if err := new(SignedProof).UnmarshalBinary(bad); err == nil {
+			t.Errorf("Signed proof with bad %s must be rejected", name)
+		}
+	}
+}
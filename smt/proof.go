@@ -0,0 +1,121 @@
+package smt
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/example/infra/db/merkle_patricia_trie/mptproof"
+	"github.com/example/service/crypto"
+)
+
+// Proof holds the sibling hashes on the path of a key from the leaf up to the root,
+// nil for a sibling which is an empty subtree. Proofs of inclusion and of absence have the same form.
+type Proof [][]byte
+
+// Size is the number of bytes of non-empty siblings.
+func (p Proof) Size() int {
+	size := 0
+	for _, s := range p {
+		size += len(s)
+	}
+	return size
+}
+
+func (t *Tree) prove(path []byte) Proof {
+	proof := make(Proof, t.depth)
+	for h := range proof {
+		if s := t.sibling(path, h); !bytes.Equal(s, t.defaults[h]) {
+			proof[h] = s
+		}
+	}
+	return proof
+}
+
+// Prove returns a proof that key is stored. It fails if key does not exist.
+func (t *Tree) Prove(key []byte) (Proof, error) {
+	path, err := t.path(key)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := t.values[string(path)]; !ok {
+		return nil, fmt.Errorf("key = <%x> not found", key)
+	}
+	return t.prove(path), nil
+}
+
+// ProveAbsence returns a proof that the leaf of key is empty. It fails if key exists.
+func (t *Tree) ProveAbsence(key []byte) (Proof, error) {
+	path, err := t.path(key)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := t.values[string(path)]; ok {
+		return nil, fmt.Errorf("key = <%x> exists", key)
+	}
+	return t.prove(path), nil
+}
+
+// rootOf recomputes the root from the leaf of key upwards.
+func rootOf(hs crypto.Hash, key, leaf []byte, proof Proof) ([]byte, error) {
+	if len(key) == 0 {
+		return nil, mptproof.ErrEmptyKey
+	}
+	defaults, err := defaultHashes(hs)
+	if err != nil {
+		return nil, err
+	}
+	depth := len(defaults) - 1
+	if len(proof) != depth {
+		return nil, fmt.Errorf("proof has %d siblings, want %d", len(proof), depth)
+	}
+	path, err := hs.Hash(key)
+	if err != nil {
+		return nil, err
+	}
+	if leaf == nil {
+		leaf = defaults[0]
+	}
+	h := leaf
+	for i, s := range proof {
+		if s == nil {
+			s = defaults[i]
+		}
+		if bit(path, depth-1-i) == 0 {
+			h, err = hashInner(hs, h, s)
+		} else {
+			h, err = hashInner(hs, s, h)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return h, nil
+}
+
+// Verify checks that proof proves value is stored at key under root.
+func Verify(hs crypto.Hash, root, key, value []byte, proof Proof) error {
+	leaf, err := hashLeaf(hs, value)
+	if err != nil {
+		return err
+	}
+	h, err := rootOf(hs, key, leaf, proof)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(root, h) {
+		return fmt.Errorf("root hash does not match")
+	}
+	return nil
+}
+
+// VerifyAbsence checks that proof proves key is not stored under root.
+func VerifyAbsence(hs crypto.Hash, root, key []byte, proof Proof) error {
+	h, err := rootOf(hs, key, nil, proof)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(root, h) {
+		return fmt.Errorf("root hash does not match")
+	}
+	return nil
+}
@@ -0,0 +1,228 @@
+// Package smt is a sparse Merkle tree: a binary tree as deep as the bits of a hash, in which a key is stored
+// at the leaf its hash leads to and empty subtrees have default hashes. It offers the Insert/Get/Prove API
+// of the merkle patricia trie, and proves that a key is absent with a proof as small as one of inclusion.
+//
+// Leaves hash as H(0x00 || value) and inner nodes as H(0x01 || left || right). An empty leaf is all zeros.
+package smt
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/example/infra/db/merkle_patricia_trie/mptproof"
+	"github.com/example/service/crypto"
+	"github.com/pkg/errors"
+)
+
+const (
+	leafPrefix  = 0x00
+	innerPrefix = 0x01
+)
+
+// Tree is not safe for concurrent writes.
+type Tree struct {
+	hs crypto.Hash
+	// depth is the number of bits of a path.
+	depth int
+	// defaults holds the hash of an empty subtree by its height, the empty leaf at 0 and the empty tree at depth.
+	defaults [][]byte
+	// nodes holds the hashes which differ from the default by nodeID.
+	nodes map[string][]byte
+	// values holds the values by path.
+	values map[string][]byte
+	root   []byte
+}
+
+func New(hs crypto.Hash) *Tree {
+	defaults, err := defaultHashes(hs)
+	if err != nil {
+		panic("Cannot initialize the default hashes. Error of Hash(): " + err.Error())
+	}
+	depth := len(defaults) - 1
+	return &Tree{
+		hs:       hs,
+		depth:    depth,
+		defaults: defaults,
+		nodes:    make(map[string][]byte),
+		values:   make(map[string][]byte),
+		root:     defaults[depth],
+	}
+}
+
+func defaultHashes(hs crypto.Hash) ([][]byte, error) {
+	probe, err := hs.Hash(nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(probe) == 0 {
+		return nil, fmt.Errorf("hash service produces empty digests")
+	}
+	depth := len(probe) * 8
+	defaults := make([][]byte, depth+1)
+	defaults[0] = make([]byte, len(probe))
+	for h := 1; h <= depth; h++ {
+		if defaults[h], err = hashInner(hs, defaults[h-1], defaults[h-1]); err != nil {
+			return nil, err
+		}
+	}
+	return defaults, nil
+}
+
+// EmptyRoot returns the root hash of a tree without keys.
+func EmptyRoot(hs crypto.Hash) ([]byte, error) {
+	defaults, err := defaultHashes(hs)
+	if err != nil {
+		return nil, err
+	}
+	return defaults[len(defaults)-1], nil
+}
+
+func hashLeaf(hs crypto.Hash, value []byte) ([]byte, error) {
+	return hs.Hash(append([]byte{leafPrefix}, value...))
+}
+
+func hashInner(hs crypto.Hash, left, right []byte) ([]byte, error) {
+	blob := make([]byte, 0, 1+len(left)+len(right))
+	blob = append(append(append(blob, innerPrefix), left...), right...)
+	return hs.Hash(blob)
+}
+
+// bit returns bit i of path, counting from the most significant bit of its first byte.
+func bit(path []byte, i int) byte {
+	return path[i/8] >> (7 - uint(i%8)) & 1
+}
+
+// nodeID identifies the node at height h above the leaf of path by h and the bits of path leading to it.
+func nodeID(path []byte, h int) string {
+	id := append([]byte{byte(h >> 8), byte(h)}, path...)
+	for i := len(path)*8 - h; i < len(path)*8; i++ {
+		id[2+i/8] &^= 1 << (7 - uint(i%8))
+	}
+	return string(id)
+}
+
+func (t *Tree) node(path []byte, h int) []byte {
+	if n, ok := t.nodes[nodeID(path, h)]; ok {
+		return n
+	}
+	return t.defaults[h]
+}
+
+// sibling returns the hash of the sibling of the node at height h on path.
+func (t *Tree) sibling(path []byte, h int) []byte {
+	s := append([]byte{}, path...)
+	i := t.depth - 1 - h
+	s[i/8] ^= 1 << (7 - uint(i%8))
+	return t.node(s, h)
+}
+
+func (t *Tree) path(key []byte) ([]byte, error) {
+	if len(key) == 0 {
+		return nil, mptproof.ErrEmptyKey
+	}
+	path, err := t.hs.Hash(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to hash key")
+	}
+	return path, nil
+}
+
+// update sets the leaf of path and rehashes the nodes above it. The hashes are computed before any is stored,
+// so a failing hash leaves the tree untouched.
+func (t *Tree) update(path, leaf []byte) error {
+	hashes := make([][]byte, t.depth+1)
+	hashes[0] = leaf
+	for h := 0; h < t.depth; h++ {
+		var err error
+		if bit(path, t.depth-1-h) == 0 {
+			hashes[h+1], err = hashInner(t.hs, hashes[h], t.sibling(path, h))
+		} else {
+			hashes[h+1], err = hashInner(t.hs, t.sibling(path, h), hashes[h])
+		}
+		if err != nil {
+			return errors.Wrap(err, "failed to hash inner node")
+		}
+	}
+	for h, hash := range hashes {
+		if bytes.Equal(hash, t.defaults[h]) {
+			delete(t.nodes, nodeID(path, h))
+		} else {
+			t.nodes[nodeID(path, h)] = hash
+		}
+	}
+	t.root = hashes[t.depth]
+	return nil
+}
+
+func (t *Tree) put(key, value []byte, overwrite bool) ([]byte, error) {
+	path, err := t.path(key)
+	if err != nil {
+		return nil, err
+	}
+	previous, ok := t.values[string(path)]
+	if ok && !overwrite {
+		return nil, fmt.Errorf("key = <%x> already exists", key)
+	}
+	leaf, err := hashLeaf(t.hs, value)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to hash leaf")
+	}
+	if err := t.update(path, leaf); err != nil {
+		return nil, err
+	}
+	t.values[string(path)] = append([]byte{}, value...)
+	return previous, nil
+}
+
+// Insert stores value at key. It fails if key exists.
+func (t *Tree) Insert(key, value []byte) error {
+	_, err := t.put(key, value, false)
+	return err
+}
+
+// Put stores value at key, overwriting the existing value if any.
+// It returns the previous value, or nil if key did not exist.
+func (t *Tree) Put(key, value []byte) ([]byte, error) {
+	return t.put(key, value, true)
+}
+
+// Delete removes key. It fails if key does not exist.
+func (t *Tree) Delete(key []byte) error {
+	path, err := t.path(key)
+	if err != nil {
+		return err
+	}
+	if _, ok := t.values[string(path)]; !ok {
+		return fmt.Errorf("key = <%x> not found", key)
+	}
+	if err := t.update(path, t.defaults[0]); err != nil {
+		return err
+	}
+	delete(t.values, string(path))
+	return nil
+}
+
+func (t *Tree) Get(key []byte) ([]byte, error) {
+	path, err := t.path(key)
+	if err != nil {
+		return nil, err
+	}
+	value, ok := t.values[string(path)]
+	if !ok {
+		return nil, fmt.Errorf("key = <%x> not found", key)
+	}
+	return value, nil
+}
+
+func (t *Tree) Has(key []byte) (bool, error) {
+	path, err := t.path(key)
+	if err != nil {
+		return false, err
+	}
+	_, ok := t.values[string(path)]
+	return ok, nil
+}
+
+func (t *Tree) RootHash() []byte {
+	return t.root
+}
@@ -0,0 +1,123 @@
+package smt
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/example/entity"
+	"github.com/example/infra/db/merkle_patricia_trie/mptproof"
+	"github.com/example/service/crypto"
+	"github.com/example/service/crypto/sha256"
+)
+
+func hashService(t *testing.T) crypto.Hash {
+	sha256.NewSha256()
+	hs, err := crypto.GetHashService(entity.HashSha256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hs
+}
+
+func TestTree(t *testing.T) {
+	hs := hashService(t)
+
+	tree := New(hs)
+	empty, err := EmptyRoot(hs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(tree.RootHash(), empty) {
+		t.Errorf("Unexpected root of an empty tree\n  got = %x\n  want = %x", tree.RootHash(), empty)
+	}
+	for i := 0; i < 100; i++ {
+		if err := tree.Insert([]byte(fmt.Sprintf("key%d", i)), []byte(fmt.Sprintf("value%d", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	{
+		t.Log("Values are found and proven")
+		for i := 0; i < 100; i++ {
+			key := []byte(fmt.Sprintf("key%d", i))
+			value, err := tree.Get(key)
+			if err != nil || string(value) != fmt.Sprintf("value%d", i) {
+				t.Fatalf("Unexpected value = <%s> of key = <%s>, err: %v", value, key, err)
+			}
+			proof, err := tree.Prove(key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := Verify(hs, tree.RootHash(), key, value, proof); err != nil {
+				t.Errorf("Proof of key = <%s> is rejected: %s", key, err)
+			}
+			if err := Verify(hs, tree.RootHash(), key, []byte("forged"), proof); err == nil {
+				t.Errorf("Forged value of key = <%s> must be rejected", key)
+			}
+			if err := VerifyAbsence(hs, tree.RootHash(), key, proof); err == nil {
+				t.Errorf("Proof of key = <%s> must not prove its absence", key)
+			}
+		}
+	}
+
+	{
+		t.Log("Absent keys are proven with proofs of the same length")
+		key := []byte("absent")
+		if _, err := tree.Get(key); err == nil {
+			t.Error("Absent key must not be found")
+		}
+		proof, err := tree.ProveAbsence(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(proof) != 256 {
+			t.Errorf("Proof must have a sibling per bit of the hash, got %d", len(proof))
+		}
+		if err := VerifyAbsence(hs, tree.RootHash(), key, proof); err != nil {
+			t.Errorf("Absence proof is rejected: %s", err)
+		}
+		if err := VerifyAbsence(hs, tree.RootHash(), []byte("key1"), proof); err == nil {
+			t.Error("Absence proof must not prove another key")
+		}
+		if _, err := tree.ProveAbsence([]byte("key1")); err == nil {
+			t.Error("ProveAbsence() of an existing key must fail")
+		}
+	}
+
+	{
+		t.Log("The root depends only on the stored keys")
+		root := tree.RootHash()
+		if err := tree.Insert([]byte("key1"), []byte("x")); err == nil {
+			t.Error("Insert() of an existing key must fail")
+		}
+		if prev, err := tree.Put([]byte("key1"), []byte("x")); err != nil || string(prev) != "value1" {
+			t.Errorf("Unexpected previous value = <%s>, err: %v", prev, err)
+		}
+		if _, err := tree.Put([]byte("key1"), []byte("value1")); err != nil {
+			t.Fatal(err)
+		}
+		if err := tree.Insert([]byte("extra"), []byte("value")); err != nil {
+			t.Fatal(err)
+		}
+		if err := tree.Delete([]byte("extra")); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(tree.RootHash(), root) {
+			t.Error("Root must be restored after overwriting and deleting back")
+		}
+		for i := 0; i < 100; i++ {
+			if err := tree.Delete([]byte(fmt.Sprintf("key%d", i))); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if !bytes.Equal(tree.RootHash(), empty) || len(tree.nodes) != 0 {
+			t.Errorf("Tree must be empty after deleting every key, %d nodes left", len(tree.nodes))
+		}
+	}
+
+	if err := tree.Insert(nil, []byte("value")); !errors.Is(err, mptproof.ErrEmptyKey) {
+		t.Errorf("Insert() must fail with ErrEmptyKey for an empty key, got %v", err)
+	}
+}
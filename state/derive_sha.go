@@ -0,0 +1,34 @@
+package state
+
+import (
+	"encoding/binary"
+
+	mpt "github.com/example/infra/db/merkle_patricia_trie"
+	"github.com/example/infra/db/merkle_patricia_trie/trie"
+	"github.com/example/service/crypto"
+	"github.com/pkg/errors"
+)
+
+// IndexKey returns the key blob i is stored at by DeriveSha: the RLP encoding of the index.
+func IndexKey(i int) []byte {
+	b := binary.BigEndian.AppendUint64(nil, uint64(i))
+	for len(b) > 0 && b[0] == 0 {
+		b = b[1:]
+	}
+	return rlpString(b)
+}
+
+// DeriveSha stores every blob at its IndexKey and returns the root, the way Ethereum derives
+// transactionsRoot and receiptsRoot. Keys are not hashed. As with the state root,
+// the node layout differs from Ethereum's, so the root does not match the one in a block header.
+func DeriveSha(hs crypto.Hash, blobs [][]byte) (trie.HashBlob, error) {
+	mt := mpt.NewMerklePatriciaTrie(hs)
+	pairs := make([]mpt.KV, len(blobs))
+	for i, blob := range blobs {
+		pairs[i] = mpt.KV{Key: IndexKey(i), Value: blob}
+	}
+	if err := mt.InsertBatch(pairs); err != nil {
+		return nil, errors.Wrap(err, "failed to derive root")
+	}
+	return mt.RootHash(), nil
+}
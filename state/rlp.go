@@ -0,0 +1,97 @@
+package state
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// Only the subset of RLP that accounts need is implemented: byte strings and a flat list of them.
+
+func rlpLength(offset byte, n int) []byte {
+	if n <= 55 {
+		return []byte{offset + byte(n)}
+	}
+	size := binary.BigEndian.AppendUint64(nil, uint64(n))
+	for len(size) > 1 && size[0] == 0 {
+		size = size[1:]
+	}
+	return append([]byte{offset + 55 + byte(len(size))}, size...)
+}
+
+func rlpString(b []byte) []byte {
+	if len(b) == 1 && b[0] < 0x80 {
+		return []byte{b[0]}
+	}
+	return append(rlpLength(0x80, len(b)), b...)
+}
+
+func rlpList(items ...[]byte) []byte {
+	var payload []byte
+	for _, item := range items {
+		payload = append(payload, rlpString(item)...)
+	}
+	return append(rlpLength(0xc0, len(payload)), payload...)
+}
+
+// rlpHeader reads the header at the start of b for strings if list is false, and returns
+// the offset and length of the payload. It rejects non-canonical headers.
+func rlpHeader(b []byte, list bool) (int, int, error) {
+	if len(b) == 0 {
+		return 0, 0, fmt.Errorf("rlp item is empty")
+	}
+	short, long := byte(0x80), byte(0xb8)
+	if list {
+		short, long = 0xc0, 0xf8
+	}
+	h := b[0]
+	switch {
+	case !list && h < 0x80:
+		return 0, 1, nil
+	case list && h < 0xc0, !list && h >= 0xc0:
+		return 0, 0, fmt.Errorf("rlp item of unexpected kind %02x", h)
+	case h < long:
+		n := int(h - short)
+		if !list && n == 1 && (len(b) < 2 || b[1] < 0x80) {
+			return 0, 0, fmt.Errorf("single byte below 0x80 must be encoded as itself")
+		}
+		if 1+n > len(b) {
+			return 0, 0, fmt.Errorf("rlp item is truncated")
+		}
+		return 1, n, nil
+	default:
+		size := int(h - long + 1)
+		if 1+size > len(b) || b[1] == 0 || size > 8 {
+			return 0, 0, fmt.Errorf("invalid rlp length")
+		}
+		var n uint64
+		for _, c := range b[1 : 1+size] {
+			n = n<<8 | uint64(c)
+		}
+		if n <= 55 || n > uint64(len(b)-1-size) {
+			return 0, 0, fmt.Errorf("invalid rlp length %d", n)
+		}
+		return 1 + size, int(n), nil
+	}
+}
+
+// rlpDecodeList decodes a flat list of byte strings which must span all of b.
+func rlpDecodeList(b []byte) ([][]byte, error) {
+	offset, n, err := rlpHeader(b, true)
+	if err != nil {
+		return nil, err
+	}
+	if offset+n != len(b) {
+		return nil, fmt.Errorf("rlp list has %d trailing bytes", len(b)-offset-n)
+	}
+	payload := b[offset:]
+	var items [][]byte
+	for len(payload) > 0 {
+		offset, n, err := rlpHeader(payload, false)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, payload[offset:offset+n])
+		payload = payload[offset+n:]
+	}
+	return items, nil
+}
@@ -0,0 +1,221 @@
+// Package state stores account objects on top of the merkle patricia trie.
+// Accounts are RLP encoded as in Ethereum and keyed by the hash of their address through a SecureTrie,
+// but the state root is the root of this trie, whose node layout differs from Ethereum's,
+// so it does not match the state root of an Ethereum client for the same accounts.
+package state
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+
+	mpt "github.com/example/infra/db/merkle_patricia_trie"
+	"github.com/example/infra/db/merkle_patricia_trie/mptproof"
+	"github.com/example/infra/db/merkle_patricia_trie/trie"
+	"github.com/example/service/crypto"
+	"github.com/pkg/errors"
+)
+
+type Account struct {
+	Nonce       uint64
+	Balance     *big.Int
+	StorageRoot []byte
+	CodeHash    []byte
+}
+
+// EncodeRLP encodes the account as the RLP list [nonce, balance, storageRoot, codeHash].
+func (a Account) EncodeRLP() ([]byte, error) {
+	if a.Balance != nil && a.Balance.Sign() < 0 {
+		return nil, fmt.Errorf("balance must not be negative")
+	}
+	nonce := new(big.Int).SetUint64(a.Nonce).Bytes()
+	var balance []byte
+	if a.Balance != nil {
+		balance = a.Balance.Bytes()
+	}
+	return rlpList(nonce, balance, a.StorageRoot, a.CodeHash), nil
+}
+
+// DecodeAccount decodes the encoding of EncodeRLP. Integers must be minimal, as RLP requires.
+func DecodeAccount(b []byte) (Account, error) {
+	items, err := rlpDecodeList(b)
+	if err != nil {
+		return Account{}, err
+	}
+	if len(items) != 4 {
+		return Account{}, fmt.Errorf("account must have 4 fields, got %d", len(items))
+	}
+	for _, integer := range items[:2] {
+		if len(integer) > 0 && integer[0] == 0 {
+			return Account{}, fmt.Errorf("integer of account has leading zeros")
+		}
+	}
+	if len(items[0]) > 8 {
+		return Account{}, fmt.Errorf("nonce of account overflows")
+	}
+	return Account{
+		Nonce:       new(big.Int).SetBytes(items[0]).Uint64(),
+		Balance:     new(big.Int).SetBytes(items[1]),
+		StorageRoot: append([]byte(nil), items[2]...),
+		CodeHash:    append([]byte(nil), items[3]...),
+	}, nil
+}
+
+// State holds the accounts by address, and the storage of every account in a trie of its own
+// whose root is the StorageRoot of the account.
+type State struct {
+	hs crypto.Hash
+	st *mpt.SecureTrie
+	// Storage tries by address
+	storage map[string]*mpt.SecureTrie
+}
+
+func New(hs crypto.Hash) *State {
+	return &State{hs: hs, st: mpt.NewSecureMerklePatriciaTrie(hs), storage: make(map[string]*mpt.SecureTrie)}
+}
+
+// NewAccount returns an account without nonce, balance, storage and code.
+func (s *State) NewAccount() (Account, error) {
+	storageRoot, err := mptproof.EmptyRoot(s.hs)
+	if err != nil {
+		return Account{}, err
+	}
+	codeHash, err := s.hs.Hash(nil)
+	if err != nil {
+		return Account{}, err
+	}
+	return Account{Balance: new(big.Int), StorageRoot: storageRoot, CodeHash: codeHash}, nil
+}
+
+func (s *State) HasAccount(address []byte) (bool, error) {
+	return s.st.Has(address)
+}
+
+func (s *State) GetAccount(address []byte) (Account, error) {
+	b, err := s.st.Get(address)
+	if err != nil {
+		return Account{}, errors.Wrapf(err, "account = <%x> not found", address)
+	}
+	a, err := DecodeAccount(b)
+	if err != nil {
+		return Account{}, errors.Wrapf(err, "invalid account = <%x>", address)
+	}
+	return a, nil
+}
+
+// SetAccount stores a at address. Once the account has storage, a must carry its storage root.
+func (s *State) SetAccount(address []byte, a Account) error {
+	if storage, ok := s.storage[string(address)]; ok && !bytes.Equal(a.StorageRoot, storage.RootHash()) {
+		return fmt.Errorf("storage root of account = <%x> does not match its storage", address)
+	}
+	b, err := a.EncodeRLP()
+	if err != nil {
+		return err
+	}
+	_, _, err = s.st.Put(address, b)
+	return err
+}
+
+// DeleteAccount deletes the account at address together with its storage.
+func (s *State) DeleteAccount(address []byte) error {
+	if err := s.st.Delete(address); err != nil {
+		return err
+	}
+	delete(s.storage, string(address))
+	return nil
+}
+
+// SetStorage stores value at slot in the storage of the account at address, which must exist,
+// and rolls the new storage root into the account. An empty value deletes the slot.
+func (s *State) SetStorage(address, slot, value []byte) error {
+	a, err := s.GetAccount(address)
+	if err != nil {
+		return err
+	}
+	storage, ok := s.storage[string(address)]
+	if !ok {
+		storage = mpt.NewSecureMerklePatriciaTrie(s.hs)
+	}
+	if len(value) == 0 {
+		if ok, err := storage.Has(slot); err != nil || !ok {
+			return err
+		}
+		err = storage.Delete(slot)
+	} else {
+		_, _, err = storage.Put(slot, value)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to update storage of account = <%x>", address)
+	}
+
+	s.storage[string(address)] = storage
+	a.StorageRoot = storage.RootHash()
+	return s.SetAccount(address, a)
+}
+
+// GetStorage returns the value at slot in the storage of the account at address, nil if the slot is empty.
+func (s *State) GetStorage(address, slot []byte) ([]byte, error) {
+	if _, err := s.GetAccount(address); err != nil {
+		return nil, err
+	}
+	storage, ok := s.storage[string(address)]
+	if !ok {
+		return nil, nil
+	}
+	if ok, err := storage.Has(slot); err != nil || !ok {
+		return nil, err
+	}
+	return storage.Get(slot)
+}
+
+// ProveStorage returns the path of slot under the storage root of the account at address.
+// Together with the account proof it proves the value under the state root.
+func (s *State) ProveStorage(address, slot []byte) (mpt.MerklePath, error) {
+	storage, ok := s.storage[string(address)]
+	if !ok {
+		return nil, fmt.Errorf("account = <%x> has no storage", address)
+	}
+	return storage.FindMerklePath(slot)
+}
+
+// Root returns the state root, the root hash of the trie of accounts.
+func (s *State) Root() trie.HashBlob {
+	return s.st.RootHash()
+}
+
+// ProveAccount returns the path of the account under Root. It proves the hashed address and the RLP encoding.
+func (s *State) ProveAccount(address []byte) (mpt.MerklePath, error) {
+	return s.st.FindMerklePath(address)
+}
+
+// VerifyAccount checks that path proves a is the account at address under root.
+func VerifyAccount(hs crypto.Hash, root trie.HashBlob, address []byte, a Account, path mpt.MerklePath) error {
+	key, err := mpt.HashKey(hs, address)
+	if err != nil {
+		return err
+	}
+	b, err := a.EncodeRLP()
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(path.Value(), b) {
+		return fmt.Errorf("account does not match the path")
+	}
+	return mpt.VerifyMerklePath(hs, root, key, b, path)
+}
+
+// VerifyStorage checks both levels of the commitment: accountPath proves a at address under root,
+// and storagePath proves value at slot under the storage root of a.
+func VerifyStorage(hs crypto.Hash, root trie.HashBlob, address []byte, a Account, accountPath mpt.MerklePath, slot, value []byte, storagePath mpt.MerklePath) error {
+	if err := VerifyAccount(hs, root, address, a, accountPath); err != nil {
+		return errors.Wrap(err, "invalid account proof")
+	}
+	key, err := mpt.HashKey(hs, slot)
+	if err != nil {
+		return err
+	}
+	if err := mpt.VerifyMerklePath(hs, a.StorageRoot, key, value, storagePath); err != nil {
+		return errors.Wrap(err, "invalid storage proof")
+	}
+	return nil
+}
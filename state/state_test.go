@@ -0,0 +1,322 @@
+package state
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/example/entity"
+	mpt "github.com/example/infra/db/merkle_patricia_trie"
+	"github.com/example/infra/db/merkle_patricia_trie/mptproof"
+	"github.com/example/service/crypto"
+	"github.com/example/service/crypto/sha256"
+)
+
+func hashService(t *testing.T) crypto.Hash {
+	sha256.NewSha256()
+	hs, err := crypto.GetHashService(entity.HashSha256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hs
+}
+
+func TestAccount_EncodeRLP(t *testing.T) {
+	a := Account{
+		Nonce:       1,
+		Balance:     big.NewInt(1024),
+		StorageRoot: bytes.Repeat([]byte{0xaa}, 32),
+		CodeHash:    bytes.Repeat([]byte{0xbb}, 32),
+	}
+	b, err := a.EncodeRLP()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "f846" + "01" + "820400" + "a0" + hex.EncodeToString(a.StorageRoot) + "a0" + hex.EncodeToString(a.CodeHash)
+	if hex.EncodeToString(b) != want {
+		t.Errorf("Unexpected encoding.\n  got = %x\n  want = %s", b, want)
+	}
+	decoded, err := DecodeAccount(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Nonce != 1 || decoded.Balance.Cmp(a.Balance) != 0 || !bytes.Equal(decoded.CodeHash, a.CodeHash) {
+		t.Errorf("Account does not round trip. got = %+v", decoded)
+	}
+
+	zero, err := Account{}.EncodeRLP()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hex.EncodeToString(zero) != "c480808080" {
+		t.Errorf("Zero integers must be empty strings. got = %x", zero)
+	}
+
+	for _, bad := range []string{"", "c3808080", "c58080808080", "c40080808080", "c58200018080", "c481008080", "c5808080"} {
+		b, _ := hex.DecodeString(bad)
+		if _, err := DecodeAccount(b); err == nil {
+			t.Errorf("Invalid account <%s> must be rejected", bad)
+		}
+	}
+}
+
+func TestState(t *testing.T) {
+	hs := hashService(t)
+
+	s := New(hs)
+	alice, bob := []byte{0xa1}, []byte{0xb0}
+	a, err := s.NewAccount()
+	if err != nil {
+		t.Fatal(err)
+	}
+	empty := s.Root()
+	if !bytes.Equal(a.StorageRoot, empty) {
+		t.Error("Storage root of a new account must be the empty root")
+	}
+
+	a.Balance = big.NewInt(100)
+	if err := s.SetAccount(alice, a); err != nil {
+		t.Fatal(err)
+	}
+	a.Nonce = 7
+	if err := s.SetAccount(bob, a); err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.GetAccount(bob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Nonce != 7 || got.Balance.Int64() != 100 {
+		t.Errorf("Unexpected account. got = %+v", got)
+	}
+
+	path, err := s.ProveAccount(bob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyAccount(hs, s.Root(), bob, got, path); err != nil {
+		t.Errorf("Valid account proof is rejected: %s", err)
+	}
+	got.Nonce = 8
+	if err := VerifyAccount(hs, s.Root(), bob, got, path); err == nil {
+		t.Error("Forged account must be rejected")
+	}
+
+	if err := s.DeleteAccount(alice); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := s.HasAccount(alice); ok {
+		t.Error("Deleted account must be gone")
+	}
+	if _, err := s.GetAccount(alice); err == nil {
+		t.Error("Missing account must be reported")
+	}
+	if err := s.DeleteAccount(bob); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(s.Root(), empty) {
+		t.Error("State without accounts must have the empty root")
+	}
+}
+
+func TestState_SetStorage(t *testing.T) {
+	hs := hashService(t)
+
+	s := New(hs)
+	alice, bob := []byte{0xa1}, []byte{0xb0}
+	if err := s.SetStorage(alice, []byte{1}, []byte("v")); err == nil {
+		t.Error("Storage of a missing account must be rejected")
+	}
+	for _, address := range [][]byte{alice, bob} {
+		a, err := s.NewAccount()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.SetAccount(address, a); err != nil {
+			t.Fatal(err)
+		}
+	}
+	empty, err := s.GetAccount(alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := s.Root()
+
+	if err := s.SetStorage(alice, []byte{1}, []byte("one")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetStorage(alice, []byte{2}, []byte("two")); err != nil {
+		t.Fatal(err)
+	}
+	a, err := s.GetAccount(alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(a.StorageRoot, empty.StorageRoot) || bytes.Equal(s.Root(), before) {
+		t.Error("Storage update must roll into the account and the state root")
+	}
+	if value, err := s.GetStorage(alice, []byte{2}); err != nil || string(value) != "two" {
+		t.Errorf("Unexpected storage value. value: %s, err: %v", value, err)
+	}
+	if value, err := s.GetStorage(bob, []byte{2}); err != nil || value != nil {
+		t.Errorf("Storage of another account must be empty. value: %s, err: %v", value, err)
+	}
+
+	{
+		t.Log("Both levels of the commitment are proven")
+		accountPath, err := s.ProveAccount(alice)
+		if err != nil {
+			t.Fatal(err)
+		}
+		storagePath, err := s.ProveStorage(alice, []byte{2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := VerifyStorage(hs, s.Root(), alice, a, accountPath, []byte{2}, []byte("two"), storagePath); err != nil {
+			t.Errorf("Valid storage proof is rejected: %s", err)
+		}
+		if err := VerifyStorage(hs, s.Root(), alice, a, accountPath, []byte{2}, []byte("forged"), storagePath); err == nil {
+			t.Error("Forged storage value must be rejected")
+		}
+		if err := VerifyStorage(hs, s.Root(), alice, empty, accountPath, []byte{2}, []byte("two"), storagePath); err == nil {
+			t.Error("Account with another storage root must be rejected")
+		}
+	}
+
+	if err := s.SetAccount(alice, empty); err == nil {
+		t.Error("Account with a stale storage root must be rejected")
+	}
+	for _, slot := range [][]byte{{1}, {2}, {3}} {
+		if err := s.SetStorage(alice, slot, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !bytes.Equal(s.Root(), before) {
+		t.Error("Clearing the storage must restore the state root")
+	}
+}
+
+func TestDeriveSha(t *testing.T) {
+	hs := hashService(t)
+
+	{
+		t.Log("Keys are the RLP encoded indices")
+		for i, want := range map[int]string{0: "80", 1: "01", 127: "7f", 128: "8180", 256: "820100"} {
+			if got := hex.EncodeToString(IndexKey(i)); got != want {
+				t.Errorf("Unexpected key of index %d\n  got = %s\n  want = %s", i, got, want)
+			}
+		}
+	}
+
+	blobs := make([][]byte, 300)
+	for i := range blobs {
+		blobs[i] = []byte(fmt.Sprintf("tx%d", i))
+	}
+	root, err := DeriveSha(hs, blobs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mt := mpt.NewMerklePatriciaTrie(hs)
+	for i, blob := range blobs {
+		if err := mt.Insert(IndexKey(i), blob); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !bytes.Equal(root, mt.RootHash()) {
+		t.Error("Root of DeriveSha() does not match the trie of the indexed blobs")
+	}
+
+	swapped := append([][]byte{blobs[1], blobs[0]}, blobs[2:]...)
+	if other, err := DeriveSha(hs, swapped); err != nil || bytes.Equal(root, other) {
+		t.Errorf("Root must commit to the order of the blobs. err: %v", err)
+	}
+
+	empty, err := DeriveSha(hs, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, _ := mptproof.EmptyRoot(hs); !bytes.Equal(empty, want) {
+		t.Error("Root of no blobs must be the empty root")
+	}
+}
+
+const testStateDump = `{
+  "root": "0x0102",
+  "accounts": {
+    "0x00000000000000000000000000000000000000bb": {
+      "balance": "7",
+      "nonce": 1,
+      "root": "0x56e8",
+      "codeHash": "0xc5d2",
+      "code": "0x6001",
+      "storage": {"0x02": "0x1", "0x01": "0xff", "0x03": ""}
+    },
+    "0x00000000000000000000000000000000000000aa": {
+      "balance": "0x64",
+      "nonce": 0,
+      "root": "0x56e8",
+      "codeHash": ""
+    }
+  }
+}`
+
+func TestState_Import(t *testing.T) {
+	hs := hashService(t)
+
+	d, err := mpt.ReadStateDump(strings.NewReader(testStateDump))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := New(hs)
+	r, err := s.Import(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Accounts != 2 || r.Slots != 2 || !bytes.Equal(r.Root, s.Root()) {
+		t.Errorf("Unexpected result %+v", r)
+	}
+	if r.RootMatches() {
+		t.Error("Root of a foreign dump must not match")
+	}
+
+	bb := d.Accounts[1].Address
+	a, err := s.GetAccount(bb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Nonce != 1 || a.Balance.Int64() != 7 || !bytes.Equal(a.CodeHash, []byte{0xc5, 0xd2}) {
+		t.Errorf("Unexpected account %+v", a)
+	}
+	if value, err := s.GetStorage(bb, []byte{1}); err != nil || !bytes.Equal(value, []byte{0xff}) {
+		t.Errorf("Unexpected storage value %x, err: %v", value, err)
+	}
+	if value, err := s.GetStorage(bb, []byte{3}); err != nil || value != nil {
+		t.Errorf("Empty slot must not be stored. value: %x, err: %v", value, err)
+	}
+	aa, err := s.GetAccount(d.Accounts[0].Address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	empty, err := s.NewAccount()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if aa.Balance.Int64() != 100 || !bytes.Equal(aa.CodeHash, empty.CodeHash) || !bytes.Equal(aa.StorageRoot, empty.StorageRoot) {
+		t.Errorf("Unexpected account %+v", aa)
+	}
+
+	{
+		t.Log("Dump of the same state matches")
+		same := mpt.StateDump{Root: s.Root(), Accounts: d.Accounts}
+		if r, err := New(hs).Import(same); err != nil || !r.RootMatches() {
+			t.Errorf("Recomputed root must match. err: %v", err)
+		}
+		if _, err := s.Import(d); err == nil {
+			t.Error("Importing existing accounts must fail")
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package merkle_patricia_trie
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// State dumps are read in the format of debug_dumpBlock and `geth dump`, which besu and nethermind follow:
+// a single object with the state root and the accounts keyed by address, optionally wrapped in a JSON-RPC response,
+// or the iterative form with one account per line carrying its own address and a final line with the root.
+// The node encoding of this trie differs from the one of Ethereum, so the roots of imported state
+// do not match StateDump.Root and can only be compared between imports.
+
+type DumpAccount struct {
+	Address  []byte
+	Balance  string
+	Nonce    uint64
+	Root     []byte
+	CodeHash []byte
+	Code     []byte
+	// Storage is sorted by key.
+	Storage []KV
+}
+
+type StateDump struct {
+	Root []byte
+	// Accounts is sorted by address.
+	Accounts []DumpAccount
+}
+
+type dumpAccountJSON struct {
+	Address  string            `json:"address"`
+	Balance  string            `json:"balance"`
+	Nonce    uint64            `json:"nonce"`
+	Root     string            `json:"root"`
+	CodeHash string            `json:"codeHash"`
+	Code     string            `json:"code"`
+	Storage  map[string]string `json:"storage"`
+}
+
+type dumpJSON struct {
+	Root     *string                    `json:"root"`
+	Accounts map[string]dumpAccountJSON `json:"accounts"`
+	Address  *string                    `json:"address"`
+	Result   json.RawMessage            `json:"result"`
+	Error    json.RawMessage            `json:"error"`
+}
+
+// decodeDumpHex decodes hex with an optional 0x prefix. Dumps drop leading zero nibbles of storage values.
+func decodeDumpHex(s string) ([]byte, error) {
+	digits := s
+	if strings.HasPrefix(digits, "0x") || strings.HasPrefix(digits, "0X") {
+		digits = digits[2:]
+	}
+	if len(digits)%2 == 1 {
+		digits = "0" + digits
+	}
+	b, err := hex.DecodeString(digits)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid hex = <%s>", s)
+	}
+	return b, nil
+}
+
+func (j dumpAccountJSON) account(address string) (DumpAccount, error) {
+	var a DumpAccount
+	var err error
+	if a.Address, err = decodeDumpHex(address); err != nil {
+		return DumpAccount{}, err
+	}
+	if len(a.Address) == 0 {
+		return DumpAccount{}, fmt.Errorf("account has no address")
+	}
+	a.Balance, a.Nonce = j.Balance, j.Nonce
+	if a.Root, err = decodeDumpHex(j.Root); err != nil {
+		return DumpAccount{}, err
+	}
+	if a.CodeHash, err = decodeDumpHex(j.CodeHash); err != nil {
+		return DumpAccount{}, err
+	}
+	if a.Code, err = decodeDumpHex(j.Code); err != nil {
+		return DumpAccount{}, err
+	}
+	for k, v := range j.Storage {
+		key, err := decodeDumpHex(k)
+		if err != nil {
+			return DumpAccount{}, err
+		}
+		if len(key) == 0 {
+			return DumpAccount{}, fmt.Errorf("account = <%s> has an empty storage key", address)
+		}
+		value, err := decodeDumpHex(v)
+		if err != nil {
+			return DumpAccount{}, err
+		}
+		a.Storage = append(a.Storage, KV{Key: key, Value: value})
+	}
+	sort.Slice(a.Storage, func(i, k int) bool { return bytes.Compare(a.Storage[i].Key, a.Storage[k].Key) < 0 })
+	return a, nil
+}
+
+// ReadStateDump reads a state dump in any of the supported forms.
+func ReadStateDump(r io.Reader) (StateDump, error) {
+	var d StateDump
+	seen := make(map[string]struct{})
+	add := func(address string, j dumpAccountJSON) error {
+		a, err := j.account(address)
+		if err != nil {
+			return err
+		}
+		if _, ok := seen[string(a.Address)]; ok {
+			return fmt.Errorf("account = <%s> appears twice in the dump", address)
+		}
+		seen[string(a.Address)] = struct{}{}
+		d.Accounts = append(d.Accounts, a)
+		return nil
+	}
+
+	dec := json.NewDecoder(r)
+	for {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err == io.EOF {
+			break
+		} else if err != nil {
+			return StateDump{}, errors.Wrap(err, "failed to read state dump")
+		}
+		var j dumpJSON
+		if err := json.Unmarshal(raw, &j); err != nil {
+			return StateDump{}, errors.Wrap(err, "failed to read state dump")
+		}
+		if len(j.Error) > 0 && string(j.Error) != "null" {
+			return StateDump{}, fmt.Errorf("state dump is an error response: %s", j.Error)
+		}
+		if len(j.Result) > 0 {
+			if err := json.Unmarshal(j.Result, &j); err != nil {
+				return StateDump{}, errors.Wrap(err, "failed to read state dump")
+			}
+		}
+
+		if j.Address != nil {
+			var a dumpAccountJSON
+			if err := json.Unmarshal(raw, &a); err != nil {
+				return StateDump{}, errors.Wrap(err, "failed to read state dump")
+			}
+			if err := add(*j.Address, a); err != nil {
+				return StateDump{}, err
+			}
+			continue
+		}
+		for address, a := range j.Accounts {
+			if err := add(address, a); err != nil {
+				return StateDump{}, err
+			}
+		}
+		if j.Root != nil {
+			root, err := decodeDumpHex(*j.Root)
+			if err != nil {
+				return StateDump{}, err
+			}
+			d.Root = root
+		}
+	}
+	sort.Slice(d.Accounts, func(i, k int) bool { return bytes.Compare(d.Accounts[i].Address, d.Accounts[k].Address) < 0 })
+	return d, nil
+}
+
+// accountValue is the value an account is stored with, its dump fields without the storage.
+type accountValue struct {
+	Balance  string `json:"balance"`
+	Nonce    uint64 `json:"nonce"`
+	Root     string `json:"root"`
+	CodeHash string `json:"codeHash"`
+}
+
+// Value returns the value ImportAccounts stores the account with.
+func (a DumpAccount) Value() ([]byte, error) {
+	return json.Marshal(accountValue{
+		Balance:  a.Balance,
+		Nonce:    a.Nonce,
+		Root:     rootPrefix + hex.EncodeToString(a.Root),
+		CodeHash: rootPrefix + hex.EncodeToString(a.CodeHash),
+	})
+}
+
+// ImportAccounts inserts every account of the dump keyed by its address. Like InsertBatch, it fails if an address exists.
+func (mt *MerklePatriciaTrie) ImportAccounts(d StateDump) error {
+	pairs := make([]KV, len(d.Accounts))
+	for i, a := range d.Accounts {
+		value, err := a.Value()
+		if err != nil {
+			return err
+		}
+		pairs[i] = KV{Key: a.Address, Value: value}
+	}
+	return mt.InsertBatch(pairs)
+}
+
+// ImportStorage inserts the storage of the account. Slots with an empty value are skipped, as they are deleted.
+func (mt *MerklePatriciaTrie) ImportStorage(a DumpAccount) error {
+	var pairs []KV
+	for _, kv := range a.Storage {
+		if len(kv.Value) > 0 {
+			pairs = append(pairs, kv)
+		}
+	}
+	return mt.InsertBatch(pairs)
+}
@@ -0,0 +1,97 @@
+package merkle_patricia_trie
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const testStateDump = `{
+  "root": "0x0102",
+  "accounts": {
+    "0x00000000000000000000000000000000000000bb": {
+      "balance": "7",
+      "nonce": 1,
+      "root": "0x56e8",
+      "codeHash": "0xc5d2",
+      "code": "0x6001",
+      "storage": {"0x02": "0x1", "0x01": "0xff", "0x03": ""}
+    },
+    "0x00000000000000000000000000000000000000aa": {
+      "balance": "100",
+      "nonce": 0,
+      "root": "0x56e8",
+      "codeHash": "0xc5d2"
+    }
+  }
+}`
+
+func TestReadStateDump(t *testing.T) {
+	hs := hashService(t)
+
+	iterative := `{"address":"0x00000000000000000000000000000000000000bb","balance":"7","nonce":1,"root":"0x56e8","codeHash":"0xc5d2","code":"0x6001","storage":{"0x02":"0x1","0x01":"0xff","0x03":""}}
+{"address":"0x00000000000000000000000000000000000000aa","balance":"100","nonce":0,"root":"0x56e8","codeHash":"0xc5d2"}
+{"root":"0x0102"}`
+	inputs := map[string]string{
+		"object":    testStateDump,
+		"json-rpc":  `{"jsonrpc":"2.0","id":1,"result":` + testStateDump + `}`,
+		"iterative": iterative,
+	}
+
+	var roots []string
+	for name, in := range inputs {
+		d, err := ReadStateDump(strings.NewReader(in))
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if !bytes.Equal(d.Root, []byte{1, 2}) {
+			t.Errorf("%s: unexpected root %x", name, d.Root)
+		}
+		if len(d.Accounts) != 2 || d.Accounts[0].Address[19] != 0xaa || d.Accounts[1].Address[19] != 0xbb {
+			t.Errorf("%s: accounts must be sorted by address", name)
+			continue
+		}
+		st := d.Accounts[1].Storage
+		if len(st) != 3 || !bytes.Equal(st[0].Value, []byte{0xff}) || !bytes.Equal(st[1].Value, []byte{1}) {
+			t.Errorf("%s: unexpected storage %v", name, st)
+		}
+
+		mt := NewMerklePatriciaTrie(hs)
+		if err := mt.ImportAccounts(d); err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		value, err := mt.Get(d.Accounts[0].Address)
+		if err != nil {
+			t.Error(err)
+		} else if want := `{"balance":"100","nonce":0,"root":"0x56e8","codeHash":"0xc5d2"}`; string(value) != want {
+			t.Errorf("Unexpected account value.\n  got = %s\n  want = %s", value, want)
+		}
+		roots = append(roots, mt.RootHashHex())
+
+		storage := NewMerklePatriciaTrie(hs)
+		if err := storage.ImportStorage(d.Accounts[1]); err != nil {
+			t.Error(err)
+		}
+		if ok, _ := storage.Has([]byte{3}); ok {
+			t.Error("Empty storage slot must be skipped")
+		}
+	}
+	for _, r := range roots[1:] {
+		if r != roots[0] {
+			t.Error("Forms of the same dump must import to the same root")
+		}
+	}
+
+	for _, in := range []string{
+		`{"accounts":{"0xzz":{}}}`,
+		`{"jsonrpc":"2.0","id":1,"error":{"code":-32000,"message":"missing trie node"}}`,
+		`{"address":"0xaa"}` + "\n" + `{"address":"0xaa"}`,
+		`{"root":`,
+	} {
+		if _, err := ReadStateDump(strings.NewReader(in)); err == nil {
+			t.Errorf("Invalid dump must be rejected: %s", in)
+		}
+	}
+}
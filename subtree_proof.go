@@ -0,0 +1,89 @@
+package merkle_patricia_trie
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/example/infra/db/merkle_patricia_trie/mptproof"
+	"github.com/example/infra/db/merkle_patricia_trie/trie"
+	"github.com/example/service/crypto"
+)
+
+// A subtree proof is a MerklePath whose first level is the subtree root instead of a leaf.
+// The subtree root is the shallowest extension whose key reaches past the prefix,
+// so every key under the prefix lies below it and its hash commits to all of them.
+
+func (mt *MerklePatriciaTrie) subtreePathInExtension(prefix string, node trie.NodeExtension) (MerklePath, error) {
+	mt.touch()
+	if len(prefix) <= len(node.Key()) {
+		if !strings.HasPrefix(node.Key(), prefix) {
+			return nil, fmt.Errorf("no keys under prefix")
+		}
+		return MerklePath{extensionSet(node, true)}, nil
+	}
+	if !strings.HasPrefix(prefix, node.Key()) || !node.HasNext() {
+		return nil, fmt.Errorf("no keys under prefix")
+	}
+
+	prefixTail := prefix[len(node.Key()):]
+	var path MerklePath
+	var err error
+	switch next := node.Next().(type) {
+	case trie.NodeExtension:
+		path, err = mt.subtreePathInExtension(prefixTail, next)
+	case trie.NodeBranch:
+		path, err = mt.subtreePathInBranch(prefixTail, next)
+	default:
+		panic("Unknown node type")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return append(path, extensionSet(node, false)), nil
+}
+
+func (mt *MerklePatriciaTrie) subtreePathInBranch(prefix string, node trie.NodeBranch) (MerklePath, error) {
+	mt.touch()
+	c := prefix[0]
+	if !node.HasChildAt(c) {
+		return nil, fmt.Errorf("no keys under prefix")
+	}
+	path, err := mt.subtreePathInExtension(prefix, node.ChildAt(c))
+	if err != nil {
+		return nil, err
+	}
+	return append(path, branchSet(node)), nil
+}
+
+// ProveSubtree returns a path from the root of the subtree holding every key under prefix up to the trie root.
+// path[0] carries the subtree hash, which can be handed over as the single commitment of the namespace.
+func (mt *MerklePatriciaTrie) ProveSubtree(prefix []byte) (MerklePath, error) {
+	prefix, err := mt.forwardKey(prefix)
+	if err != nil {
+		return nil, err
+	}
+	if len(prefix) == 0 {
+		return nil, ErrEmptyKey
+	}
+	ep := hex.EncodeToString(prefix)
+	path, err := mt.subtreePathInBranch(ep, mt.root)
+	if err != nil {
+		return nil, err
+	}
+	return append(path, MerkleSet{hashes: []trie.HashBlob{mt.root.Hash()}}), nil
+}
+
+// SubtreeHash returns the hash of the subtree root proven by a ProveSubtree path.
+func (mp MerklePath) SubtreeHash() trie.HashBlob {
+	if len(mp) == 0 || len(mp[0].hashes) != 1 {
+		return nil
+	}
+	return mp[0].hashes[0]
+}
+
+// VerifySubtree checks that path proves subtreeHash is the root of the subtree holding every key under prefix
+// and that it is committed under rootHash.
+func VerifySubtree(hs crypto.Hash, rootHash trie.HashBlob, prefix []byte, subtreeHash trie.HashBlob, path MerklePath) error {
+	return mptproof.VerifySubtree(hs, rootHash, prefix, subtreeHash, path.ProofPath())
+}
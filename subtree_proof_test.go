@@ -0,0 +1,90 @@
+package merkle_patricia_trie
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestMerklePatriciaTrie_ProveSubtree(t *testing.T) {
+	hs := hashService(t)
+
+	mt := NewMerklePatriciaTrie(hs)
+	for _, key := range []string{"dog", "doge", "cat", "key", "key123"} {
+		if err := mt.Insert([]byte(key), []byte("v"+key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	{
+		t.Log("Subtree proofs verify against the root")
+
+		for _, prefix := range []string{"d", "do", "dog", "doge", "c", "k", "key1"} {
+			path, err := mt.ProveSubtree([]byte(prefix))
+			if err != nil {
+				t.Fatalf("Failed to prove subtree of %s: %s", prefix, err)
+			}
+			if err := VerifySubtree(hs, mt.RootHash(), []byte(prefix), path.SubtreeHash(), path); err != nil {
+				t.Errorf("Valid subtree proof of %s is rejected: %s", prefix, err)
+			}
+		}
+		if _, err := mt.ProveSubtree([]byte("x")); err == nil {
+			t.Error("Prefix without keys must not be proven")
+		}
+		if _, err := mt.ProveSubtree([]byte("dogs")); err == nil {
+			t.Error("Prefix without keys must not be proven")
+		}
+		if _, err := mt.ProveSubtree(nil); !errors.Is(err, ErrEmptyKey) {
+			t.Errorf("Empty prefix must be rejected with ErrEmptyKey. err: %v", err)
+		}
+	}
+	{
+		t.Log("Subtree hash commits to the keys under the prefix only")
+
+		other := NewMerklePatriciaTrie(hs)
+		for _, key := range []string{"dog", "doge", "cow"} {
+			if err := other.Insert([]byte(key), []byte("v"+key)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		path, err := mt.ProveSubtree([]byte("do"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		otherPath, err := other.ProveSubtree([]byte("do"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(path.SubtreeHash(), otherPath.SubtreeHash()) {
+			t.Error("Keys outside of the prefix must not change the subtree hash")
+		}
+
+		if err := other.Insert([]byte("dot"), []byte("vdot")); err != nil {
+			t.Fatal(err)
+		}
+		otherPath, err = other.ProveSubtree([]byte("do"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Equal(path.SubtreeHash(), otherPath.SubtreeHash()) {
+			t.Error("Keys under the prefix must change the subtree hash")
+		}
+	}
+	{
+		t.Log("Mismatched proofs are rejected")
+
+		path, err := mt.ProveSubtree([]byte("doge"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := VerifySubtree(hs, mt.RootHash(), []byte("do"), path.SubtreeHash(), path); err == nil {
+			t.Error("Subtree below the prefix must be rejected")
+		}
+		if err := VerifySubtree(hs, mt.RootHash(), []byte("ca"), path.SubtreeHash(), path); err == nil {
+			t.Error("Subtree of another prefix must be rejected")
+		}
+		if err := VerifySubtree(hs, mt.RootHash(), []byte("doge"), mt.RootHash(), path); err == nil {
+			t.Error("Wrong subtree hash must be rejected")
+		}
+	}
+}
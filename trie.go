@@ -3,12 +3,14 @@ package trie
 import (
 	"bytes"
 
-	"encoding/gob"
-
 	"encoding/hex"
 
 	"fmt"
 
+	"sync"
+
+	"github.com/example/infra/db/merkle_patricia_trie/mptproof"
+
 	"github.com/example/logger"
 
 	"github.com/example/service/crypto"
@@ -18,6 +20,71 @@ import (
 
 var log = logger.NewLogger()
 
+// WarningKind identifies the condition a Warning reports.
+type WarningKind int
+
+const (
+	// WarningValueTruncated is reported by MarshalJSON for a value cut to maxJSONValueLength bytes.
+	WarningValueTruncated WarningKind = iota
+)
+
+// Warning is a condition which does not fail the operation raising it.
+type Warning struct {
+	Kind WarningKind
+
+	Message string
+
+	// Key is the key fragment of the node the warning is about.
+	Key string
+}
+
+var (
+	warningMu sync.RWMutex
+
+	warningHandler = logWarning
+)
+
+func logWarning(w Warning) {
+
+	log.Warn(w.Message)
+
+}
+
+// SetWarningHandler routes the warnings of every trie to handler and returns the previous handler.
+// A nil handler suppresses them. By default warnings are logged.
+func SetWarningHandler(handler func(Warning)) func(Warning) {
+
+	warningMu.Lock()
+
+	defer warningMu.Unlock()
+
+	previous := warningHandler
+
+	warningHandler = handler
+
+	return previous
+
+}
+
+func warn(w Warning) {
+
+	warningMu.RLock()
+
+	handler := warningHandler
+
+	warningMu.RUnlock()
+
+	if handler != nil {
+
+		handler(w)
+
+	}
+
+}
+
+// maxJSONValueLength is the number of bytes of a value MarshalJSON writes.
+const maxJSONValueLength = 100
+
 const ChildIndexCount = 16
 
 type HashBlob []byte
@@ -29,6 +96,8 @@ type Node interface {
 
 	Hash() HashBlob
 
+	HasHash() bool
+
 	MarshalJSON() ([]byte, error)
 }
 
@@ -50,6 +119,8 @@ type NodeExtension interface {
 	HasValueObject() bool
 
 	SetValueObject(ValueObject)
+
+	Copy() NodeExtension
 }
 
 type ValueObject interface {
@@ -72,13 +143,13 @@ type NodeBranch interface {
 	Count() int
 
 	First() NodeExtension
+
+	Copy() NodeBranch
 }
 
 func NewNodeExtension(key string, next Node, valueObject ValueObject, hs crypto.Hash) (NodeExtension, error) {
 
-	base := nodeBase{HashBlob{}}
-
-	n := &nodeExtension{base, key, next, valueObject}
+	n := NewUnhashedNodeExtension(key, next, valueObject)
 
 	if err := n.UpdateHash(hs); err != nil {
 
@@ -90,6 +161,16 @@ func NewNodeExtension(key string, next Node, valueObject ValueObject, hs crypto.
 
 }
 
+// NewUnhashedNodeExtension creates an extension node without hashing it.
+// HasHash() is false until UpdateHash is called.
+func NewUnhashedNodeExtension(key string, next Node, valueObject ValueObject) NodeExtension {
+
+	base := nodeBase{HashBlob{}}
+
+	return &nodeExtension{base, key, next, valueObject}
+
+}
+
 func NewValueObject(value []byte) ValueObject {
 
 	return &valueObject{value}
@@ -156,6 +237,12 @@ func (node *nodeBase) Hash() HashBlob {
 
 }
 
+func (node *nodeBase) HasHash() bool {
+
+	return len(node.hash) != 0
+
+}
+
 type nodeExtension struct {
 	nodeBase
 
@@ -166,73 +253,31 @@ type nodeExtension struct {
 	value ValueObject
 }
 
-func (node *nodeExtension) Serialize() ([]byte, error) {
-
-	w := new(bytes.Buffer)
-
-	encoder := gob.NewEncoder(w)
-
-	if err := encoder.Encode("E"); err != nil {
-
-		return nil, err
-
-	}
-
-	if err := encoder.Encode(node.key); err != nil {
-
-		return nil, err
-
-	}
-
-	if node.HasNext() {
-
-		if err := encoder.Encode("C"); err != nil {
-
-			return nil, err
-
-		}
-
-		if err := encoder.Encode(node.next.Hash()); err != nil {
+// SerializeExtension encodes an extension node from its parts, so a verifier can recompute the hash without the node.
+// nextHash and value are nil if the node has no next node or no value.
+func SerializeExtension(key string, nextHash HashBlob, value ValueObject) ([]byte, error) {
 
-			return nil, err
-
-		}
-
-	} else {
-
-		if err := encoder.Encode([]byte("NC")); err != nil {
-
-			return nil, err
+	if value == nil {
 
-		}
+		return mptproof.EncodeExtension(key, nextHash, nil, false)
 
 	}
 
-	if node.HasValueObject() {
-
-		if err := encoder.Encode("V"); err != nil {
-
-			return nil, err
-
-		}
-
-		if err := encoder.Encode(node.value.Value()); err != nil {
+	return mptproof.EncodeExtension(key, nextHash, value.Value(), true)
 
-			return nil, err
-
-		}
+}
 
-	} else {
+func (node *nodeExtension) Serialize() ([]byte, error) {
 
-		if err := encoder.Encode("NV"); err != nil {
+	var nextHash HashBlob
 
-			return nil, err
+	if node.HasNext() {
 
-		}
+		nextHash = node.next.Hash()
 
 	}
 
-	return w.Bytes(), nil
+	return SerializeExtension(node.key, nextHash, node.value)
 
 }
 
@@ -292,11 +337,11 @@ func (node *nodeExtension) MarshalJSON() ([]byte, error) {
 
 		marshalValue := node.value.Value()
 
-		if len(marshalValue) > 100 {
+		if len(marshalValue) > maxJSONValueLength {
 
-			log.Warn("Too large value in MarshalJSON() (omitted)")
+			warn(Warning{Kind: WarningValueTruncated, Message: "Too large value in MarshalJSON() (omitted)", Key: node.key})
 
-			marshalValue = marshalValue[:100]
+			marshalValue = marshalValue[:maxJSONValueLength]
 
 		}
 
@@ -376,6 +421,15 @@ func (node *nodeExtension) SetValueObject(value ValueObject) {
 
 }
 
+// Copy returns a shallow copy which shares next and the value with node.
+func (node *nodeExtension) Copy() NodeExtension {
+
+	c := *node
+
+	return &c
+
+}
+
 type valueObject struct {
 	value []byte
 }
@@ -392,47 +446,36 @@ type nodeBranch struct {
 	children []NodeExtension
 }
 
-func (node *nodeBranch) Serialize() ([]byte, error) {
-
-	w := new(bytes.Buffer)
+// SerializeBranch encodes a branch node from the hashes of its ChildIndexCount children, nil for no child.
+func SerializeBranch(childHashes []HashBlob) ([]byte, error) {
 
-	encoder := gob.NewEncoder(w)
+	children := make([][]byte, len(childHashes))
 
-	if err := encoder.Encode("B"); err != nil {
+	for i, h := range childHashes {
 
-		return nil, err
+		children[i] = h
 
 	}
 
-	for _, child := range node.ListChildren() {
-
-		if child != nil {
-
-			if err := encoder.Encode("C"); err != nil {
-
-				return nil, err
-
-			}
-
-			if err := encoder.Encode(child.Hash()); err != nil {
+	return mptproof.EncodeBranch(children)
 
-				return nil, err
+}
 
-			}
+func (node *nodeBranch) Serialize() ([]byte, error) {
 
-		} else {
+	childHashes := make([]HashBlob, ChildIndexCount)
 
-			if err := encoder.Encode("NC"); err != nil {
+	for i, child := range node.ListChildren() {
 
-				return nil, err
+		if child != nil {
 
-			}
+			childHashes[i] = child.Hash()
 
 		}
 
 	}
 
-	return w.Bytes(), nil
+	return SerializeBranch(childHashes)
 
 }
 
@@ -530,6 +573,17 @@ func (node *nodeBranch) Count() int {
 
 }
 
+// Copy returns a shallow copy which shares the children with node.
+func (node *nodeBranch) Copy() NodeBranch {
+
+	children := make([]NodeExtension, ChildIndexCount)
+
+	copy(children, node.children)
+
+	return &nodeBranch{node.nodeBase, children}
+
+}
+
 func (node *nodeBranch) First() NodeExtension {
 
 	for _, child := range node.children {
@@ -608,7 +662,7 @@ func toChildIndex(ch byte) int {
 
 	} else if 'a' <= ch && ch <= 'f' {
 
-		return int(ch) - 'a'
+		return int(ch) - 'a' + 10
 
 	} else {
 
@@ -0,0 +1,85 @@
+package merkle_patricia_trie
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// ValueMeta describes how an application encoded a value, so encodings can evolve without out-of-band bookkeeping.
+// The trie does not interpret it.
+type ValueMeta struct {
+	Codec   uint64
+	Version uint64
+	Flags   uint64
+}
+
+// envelopeVersion is the first byte of an envelope.
+const envelopeVersion = 1
+
+// EncodeEnvelope encodes value with its metadata as the version byte, the uvarint Codec, Version and Flags,
+// and the value. With envelopes, this is the value the value transforms run on, so the metadata
+// is committed into the hash of the node like the value itself.
+func EncodeEnvelope(meta ValueMeta, value []byte) []byte {
+	b := []byte{envelopeVersion}
+	b = binary.AppendUvarint(b, meta.Codec)
+	b = binary.AppendUvarint(b, meta.Version)
+	b = binary.AppendUvarint(b, meta.Flags)
+	return append(b, value...)
+}
+
+func DecodeEnvelope(b []byte) (ValueMeta, []byte, error) {
+	r := bytes.NewReader(b)
+	version, err := r.ReadByte()
+	if err != nil {
+		return ValueMeta{}, nil, fmt.Errorf("envelope is empty")
+	}
+	if version != envelopeVersion {
+		return ValueMeta{}, nil, fmt.Errorf("unsupported envelope version %d", version)
+	}
+	var meta ValueMeta
+	for _, field := range []*uint64{&meta.Codec, &meta.Version, &meta.Flags} {
+		if *field, err = binary.ReadUvarint(r); err != nil {
+			return ValueMeta{}, nil, errors.Wrap(err, "failed to read envelope")
+		}
+	}
+	return meta, b[len(b)-r.Len():], nil
+}
+
+// UseEnvelopes stores every value in an envelope with its ValueMeta, zero unless written by PutWithMeta.
+// Like value transforms, it must be set before the first write.
+func (mt *MerklePatriciaTrie) UseEnvelopes() {
+	mt.envelopes = true
+}
+
+// PutWithMeta is Put storing meta along with value. It requires envelopes.
+func (mt *MerklePatriciaTrie) PutWithMeta(key, value []byte, meta ValueMeta) (prev []byte, existed bool, err error) {
+	if !mt.envelopes {
+		return nil, false, fmt.Errorf("trie does not use envelopes")
+	}
+	return mt.put(key, value, meta)
+}
+
+// ValueMeta returns the metadata stored with the value at key. It requires envelopes.
+func (mt *MerklePatriciaTrie) ValueMeta(key []byte) (ValueMeta, error) {
+	if !mt.envelopes {
+		return ValueMeta{}, fmt.Errorf("trie does not use envelopes")
+	}
+	key, err := mt.forwardKey(key)
+	if err != nil {
+		return ValueMeta{}, err
+	}
+	if len(key) == 0 {
+		return ValueMeta{}, ErrEmptyKey
+	}
+	ek := hex.EncodeToString(key)
+	vo := mt.valueObjectInBranch(ek, mt.root)
+	if vo == nil {
+		return ValueMeta{}, fmt.Errorf("ValueObject not found for key = <%s>", ek)
+	}
+	meta, _, err := mt.openValue(key, append([]byte(nil), vo.Value()...))
+	return meta, err
+}
@@ -0,0 +1,93 @@
+package merkle_patricia_trie
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestMerklePatriciaTrie_ValueMeta(t *testing.T) {
+	hs := hashService(t)
+
+	trie := NewMerklePatriciaTrie(hs)
+	trie.UseEnvelopes()
+	v1 := ValueMeta{Codec: 1, Version: 1}
+	v2 := ValueMeta{Codec: 1, Version: 2, Flags: 0x80}
+	if _, _, err := trie.PutWithMeta([]byte("key1"), []byte("old"), v1); err != nil {
+		t.Fatal(err)
+	}
+	if err := trie.Insert([]byte("key2"), []byte("plain")); err != nil {
+		t.Fatal(err)
+	}
+
+	{
+		t.Log("Values are read without their envelope")
+		if value, err := trie.Get([]byte("key1")); err != nil || string(value) != "old" {
+			t.Errorf("Unexpected value = <%s>, err: %v", value, err)
+		}
+		if meta, err := trie.ValueMeta([]byte("key1")); err != nil || meta != v1 {
+			t.Errorf("Unexpected meta %+v, err: %v", meta, err)
+		}
+		if meta, err := trie.ValueMeta([]byte("key2")); err != nil || meta != (ValueMeta{}) {
+			t.Errorf("Values without meta must have the zero meta. meta: %+v, err: %v", meta, err)
+		}
+	}
+
+	{
+		t.Log("Metadata is committed into the root")
+		root := trie.RootHash()
+		prev, _, err := trie.PutWithMeta([]byte("key1"), []byte("old"), v2)
+		if err != nil || string(prev) != "old" {
+			t.Fatalf("Unexpected previous value = <%s>, err: %v", prev, err)
+		}
+		if bytes.Equal(root, trie.RootHash()) {
+			t.Error("Changing only the meta must change the root")
+		}
+		path, err := trie.FindMerklePath([]byte("key1"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := VerifyMerklePath(hs, trie.RootHash(), []byte("key1"), EncodeEnvelope(v2, []byte("old")), path); err != nil {
+			t.Errorf("Proof of the envelope is rejected: %s", err)
+		}
+		meta, value, err := DecodeEnvelope(path.Value())
+		if err != nil || meta != v2 || string(value) != "old" {
+			t.Errorf("Unexpected envelope in the proof. meta: %+v, value: %s, err: %v", meta, value, err)
+		}
+	}
+
+	{
+		t.Log("Envelopes are inside the value transforms")
+		other := NewMerklePatriciaTrie(hs)
+		other.UseEnvelopes()
+		other.Use(prefixTransform{[]byte("ab:")})
+		if _, _, err := other.PutWithMeta([]byte("key1"), []byte("old"), v2); err != nil {
+			t.Fatal(err)
+		}
+		if meta, err := other.ValueMeta([]byte("key1")); err != nil || meta != v2 {
+			t.Errorf("Unexpected meta %+v, err: %v", meta, err)
+		}
+	}
+
+	{
+		t.Log("Meta requires envelopes")
+		plain := NewMerklePatriciaTrie(hs)
+		if _, _, err := plain.PutWithMeta([]byte("key1"), []byte("value"), v1); err == nil {
+			t.Error("PutWithMeta() without envelopes must fail")
+		}
+		if err := plain.Insert([]byte("key1"), []byte("value")); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := plain.ValueMeta([]byte("key1")); err == nil {
+			t.Error("ValueMeta() without envelopes must fail")
+		}
+	}
+
+	{
+		t.Log("Malformed envelopes are rejected")
+		for _, b := range [][]byte{nil, {2, 0, 0, 0}, {1, 0x80}} {
+			if _, _, err := DecodeEnvelope(b); err == nil {
+				t.Errorf("Envelope %x must be rejected", b)
+			}
+		}
+	}
+}
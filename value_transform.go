@@ -0,0 +1,54 @@
+package merkle_patricia_trie
+
+import (
+	"github.com/pkg/errors"
+)
+
+// ValueTransform is one stage of the value path.
+// Forward runs before the value is hashed into the trie and Inverse undoes it on read,
+// so features like compression, encryption or signing can be chained without touching the trie core.
+type ValueTransform interface {
+	Forward(key, value []byte) ([]byte, error)
+	Inverse(key, value []byte) ([]byte, error)
+}
+
+// Use appends transforms to the value path. Forward runs in the given order and Inverse in reverse.
+// Transforms must be registered before the first write; values already in the trie are not rewritten.
+func (mt *MerklePatriciaTrie) Use(transforms ...ValueTransform) {
+	mt.transforms = append(mt.transforms, transforms...)
+}
+
+// forwardValue wraps value in its envelope, if the trie uses envelopes, and runs the transforms on it.
+func (mt *MerklePatriciaTrie) forwardValue(key, value []byte, meta ValueMeta) ([]byte, error) {
+	if mt.envelopes {
+		value = EncodeEnvelope(meta, value)
+	}
+	for i, t := range mt.transforms {
+		v, err := t.Forward(key, value)
+		if err != nil {
+			return nil, errors.Wrapf(err, "value transform #%d failed", i)
+		}
+		value = v
+	}
+	return value, nil
+}
+
+func (mt *MerklePatriciaTrie) inverseValue(key, value []byte) ([]byte, error) {
+	_, value, err := mt.openValue(key, value)
+	return value, err
+}
+
+// openValue undoes forwardValue, returning the metadata of the envelope if the trie uses envelopes.
+func (mt *MerklePatriciaTrie) openValue(key, value []byte) (ValueMeta, []byte, error) {
+	for i := len(mt.transforms) - 1; i >= 0; i-- {
+		v, err := mt.transforms[i].Inverse(key, value)
+		if err != nil {
+			return ValueMeta{}, nil, errors.Wrapf(err, "inverse of value transform #%d failed", i)
+		}
+		value = v
+	}
+	if !mt.envelopes {
+		return ValueMeta{}, value, nil
+	}
+	return DecodeEnvelope(value)
+}
@@ -0,0 +1,48 @@
+package merkle_patricia_trie
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+type prefixTransform struct {
+	prefix []byte
+}
+
+func (t prefixTransform) Forward(key, value []byte) ([]byte, error) {
+	return append(append([]byte(nil), t.prefix...), value...), nil
+}
+
+func (t prefixTransform) Inverse(key, value []byte) ([]byte, error) {
+	if !bytes.HasPrefix(value, t.prefix) {
+		return nil, fmt.Errorf("missing prefix <%s>", t.prefix)
+	}
+	return value[len(t.prefix):], nil
+}
+
+func TestMerklePatriciaTrie_Use(t *testing.T) {
+	hs := hashService(t)
+
+	plain := NewMerklePatriciaTrie(hs)
+	if err := plain.Insert([]byte("key"), []byte("ab:value")); err != nil {
+		t.Fatal(err)
+	}
+
+	transformed := NewMerklePatriciaTrie(hs)
+	transformed.Use(prefixTransform{[]byte("b:")}, prefixTransform{[]byte("a")})
+	if err := transformed.Insert([]byte("key"), []byte("value")); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(plain.root.Hash(), transformed.root.Hash()) {
+		t.Error("Forward transforms must run in order before hashing")
+	}
+	value, err := transformed.Get([]byte("key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(value) != "value" {
+		t.Errorf("Inverse transforms must run in reverse order.\n  got = %s\n  want = value", value)
+	}
+}
@@ -0,0 +1,91 @@
+package merkle_patricia_trie
+
+import (
+	"container/list"
+	"encoding/binary"
+	"sync"
+
+	"github.com/example/infra/db/merkle_patricia_trie/trie"
+	"github.com/example/service/crypto"
+)
+
+// VerifyCache remembers the last capacity successful verifications by (root, key, hash of value),
+// so a claim gossiped again is accepted without recomputing its hash chain.
+// Only successes are cached; a failed proof says nothing about another proof of the same claim.
+// It is safe for concurrent use.
+type VerifyCache struct {
+	hs       crypto.Hash
+	capacity int
+
+	mu      sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+	hits    int
+}
+
+func NewVerifyCache(hs crypto.Hash, capacity int) *VerifyCache {
+	if capacity <= 0 {
+		panic("capacity of VerifyCache must be positive")
+	}
+	return &VerifyCache{hs: hs, capacity: capacity, order: list.New(), entries: make(map[string]*list.Element)}
+}
+
+func (c *VerifyCache) claim(rootHash trie.HashBlob, key, value []byte) (string, error) {
+	vh, err := c.hs.Hash(value)
+	if err != nil {
+		return "", err
+	}
+	b := binary.AppendUvarint(nil, uint64(len(rootHash)))
+	b = append(b, rootHash...)
+	b = binary.AppendUvarint(b, uint64(len(key)))
+	b = append(b, key...)
+	return string(append(b, vh...)), nil
+}
+
+// VerifyMerklePath verifies like the function of the same name, answering from the cache when it can.
+func (c *VerifyCache) VerifyMerklePath(rootHash trie.HashBlob, key, value []byte, path MerklePath) error {
+	claim, err := c.claim(rootHash, key, value)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	if e, ok := c.entries[claim]; ok {
+		c.order.MoveToFront(e)
+		c.hits++
+		c.mu.Unlock()
+		return nil
+	}
+	c.mu.Unlock()
+
+	if err := VerifyMerklePath(c.hs, rootHash, key, value, path); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[claim]; ok {
+		c.order.MoveToFront(e)
+		return nil
+	}
+	c.entries[claim] = c.order.PushFront(claim)
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(string))
+	}
+	return nil
+}
+
+// Len returns the number of cached verifications.
+func (c *VerifyCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// Hits returns the number of verifications answered from the cache.
+func (c *VerifyCache) Hits() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.hits
+}
@@ -0,0 +1,67 @@
+package merkle_patricia_trie
+
+import (
+	"testing"
+)
+
+func TestVerifyCache(t *testing.T) {
+	hs := hashService(t)
+
+	mt := NewMerklePatriciaTrie(hs)
+	keys := []string{"key", "key123", "dog"}
+	paths := make(map[string]MerklePath)
+	for _, key := range keys {
+		if err := mt.Insert([]byte(key), []byte("v"+key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, key := range keys {
+		path, err := mt.FindMerklePath([]byte(key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		paths[key] = path
+	}
+
+	fh := &failingHash{hs: hs}
+	cache := NewVerifyCache(fh, 2)
+	root := mt.RootHash()
+	if err := cache.VerifyMerklePath(root, []byte("key"), []byte("vkey"), paths["key"]); err != nil {
+		t.Fatal(err)
+	}
+	calls := fh.calls
+	if err := cache.VerifyMerklePath(root, []byte("key"), []byte("vkey"), paths["key"]); err != nil {
+		t.Fatal(err)
+	}
+	if fh.calls-calls != 1 {
+		t.Errorf("Cached claim must only hash its value. got = %d hashes", fh.calls-calls)
+	}
+	if cache.Hits() != 1 {
+		t.Errorf("Unexpected hits. got = %d, want = %d", cache.Hits(), 1)
+	}
+
+	if err := cache.VerifyMerklePath(root, []byte("key"), []byte("forged"), paths["key"]); err == nil {
+		t.Error("Forged value must be rejected")
+	}
+	if err := cache.VerifyMerklePath(root, []byte("dog"), []byte("vdog"), paths["key"]); err == nil {
+		t.Error("Proof of another key must be rejected")
+	}
+	if cache.Len() != 1 {
+		t.Errorf("Failures must not be cached. got = %d entries", cache.Len())
+	}
+
+	for _, key := range []string{"key123", "dog"} {
+		if err := cache.VerifyMerklePath(root, []byte(key), []byte("v"+key), paths[key]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if cache.Len() != 2 {
+		t.Errorf("Cache must be bounded by its capacity. got = %d entries", cache.Len())
+	}
+	if err := cache.VerifyMerklePath(root, []byte("key"), []byte("vkey"), paths["key"]); err != nil {
+		t.Fatal(err)
+	}
+	if cache.Hits() != 1 {
+		t.Error("Least recently used claim must have been evicted")
+	}
+}
@@ -0,0 +1,82 @@
+package merkle_patricia_trie
+
+import (
+	"encoding/hex"
+
+	"github.com/example/infra/db/merkle_patricia_trie/trie"
+)
+
+// walkInExtension calls fn for every value under node in key order and stops when fn returns false.
+// It returns false if the walk was stopped.
+func (mt *MerklePatriciaTrie) walkInExtension(path string, node trie.NodeExtension, fn func(path string, vo trie.ValueObject) bool) bool {
+	mt.touch()
+	path += node.Key()
+	if node.HasValueObject() && !fn(path, node.ValueObject()) {
+		return false
+	}
+	if !node.HasNext() {
+		return true
+	}
+	switch next := node.Next().(type) {
+	case trie.NodeExtension:
+		return mt.walkInExtension(path, next, fn)
+	case trie.NodeBranch:
+		return mt.walkInBranch(path, next, fn)
+	default:
+		panic("Unknown node type")
+	}
+}
+
+func (mt *MerklePatriciaTrie) walkInBranch(path string, node trie.NodeBranch, fn func(path string, vo trie.ValueObject) bool) bool {
+	mt.touch()
+	for _, child := range node.ListChildren() {
+		if child != nil && !mt.walkInExtension(path, child, fn) {
+			return false
+		}
+	}
+	return true
+}
+
+func decodePath(path string) []byte {
+	key, err := hex.DecodeString(path)
+	if err != nil {
+		panic("Path to the value must be hex encoded key. err: " + err.Error())
+	}
+	return key
+}
+
+// Keys returns all keys in lexicographic order of the stored keys.
+// Keys which the key transformer fails to invert are left out.
+func (mt *MerklePatriciaTrie) Keys() [][]byte {
+	var keys [][]byte
+	mt.walkInBranch("", mt.root, func(path string, vo trie.ValueObject) bool {
+		if key, err := mt.inverseKey(decodePath(path)); err == nil {
+			keys = append(keys, key)
+		}
+		return true
+	})
+	return keys
+}
+
+// Walk calls fn for every key/value pair in lexicographic key order until fn returns false.
+func (mt *MerklePatriciaTrie) Walk(fn func(key, value []byte) bool) error {
+	var err error
+	mt.walkInBranch("", mt.root, func(path string, vo trie.ValueObject) bool {
+		stored := decodePath(path)
+		var key, value []byte
+		key, err = mt.inverseKey(stored)
+		if err == ErrForeignKey {
+			err = nil
+			return true
+		}
+		if err != nil {
+			return false
+		}
+		value, err = mt.inverseValue(stored, append([]byte(nil), vo.Value()...))
+		if err != nil {
+			return false
+		}
+		return fn(key, value)
+	})
+	return err
+}
@@ -0,0 +1,61 @@
+package merkle_patricia_trie
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestMerklePatriciaTrie_Keys(t *testing.T) {
+	hs := hashService(t)
+
+	want := []string{"dog", "cat", "doge", "k", "kk", "kkk", "k`", "kj", "key12ab", "key123", "\x00", "\xff"}
+	trie := NewMerklePatriciaTrie(hs)
+	for _, key := range want {
+		if err := trie.Insert([]byte(key), []byte("value")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sort.Strings(want)
+
+	keys := trie.Keys()
+	if len(keys) != len(want) {
+		t.Fatalf("Unexpected number of keys. got = %d, want = %d", len(keys), len(want))
+	}
+	for i := range want {
+		if !bytes.Equal(keys[i], []byte(want[i])) {
+			t.Errorf("Unexpected key at #%d.\n  got = %q\n  want = %q", i, keys[i], want[i])
+		}
+	}
+
+	if keys := NewMerklePatriciaTrie(hs).Keys(); len(keys) != 0 {
+		t.Errorf("Empty trie must have no keys. got = %q", keys)
+	}
+}
+
+func TestMerklePatriciaTrie_Walk(t *testing.T) {
+	hs := hashService(t)
+
+	trie := NewMerklePatriciaTrie(hs)
+	for _, key := range []string{"dog", "cat", "doge", "k", "kk"} {
+		if err := trie.Insert([]byte(key), []byte("value-"+key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var got []string
+	err := trie.Walk(func(key, value []byte) bool {
+		if string(value) != "value-"+string(key) {
+			t.Errorf("Unexpected value of <%s>. got = %s", key, value)
+		}
+		got = append(got, string(key))
+		return string(key) != "doge"
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "cat,dog,doge"; strings.Join(got, ",") != want {
+		t.Errorf("Walk() must stop when fn returns false.\n  got = %q\n  want = %q", got, want)
+	}
+}
@@ -0,0 +1,102 @@
+package wide
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/example/service/crypto"
+)
+
+// Commitment commits to a vector of slots, nil for an empty slot, and opens single slots of it.
+// The hash commitment opens a slot by the whole vector; a vector commitment, as in Verkle tries,
+// opens it with a constant-size proof, which is what makes wide nodes pay off.
+type Commitment interface {
+	Commit(slots [][]byte) ([]byte, error)
+	// Open returns a proof that slot i of the vector committed to by Commit(slots) holds slots[i].
+	Open(slots [][]byte, i int) ([]byte, error)
+	// VerifyOpening checks that opening proves slot i of the vector committed to by commitment holds slot.
+	VerifyOpening(commitment []byte, i int, slot []byte, opening []byte) error
+}
+
+type hashCommitment struct {
+	hs crypto.Hash
+}
+
+// NewHashCommitment commits to a vector by hashing its encoding. Openings carry every other slot.
+func NewHashCommitment(hs crypto.Hash) Commitment {
+	return hashCommitment{hs}
+}
+
+// encodeSlots encodes the uvarint number of slots and, for each slot, 0 if it is empty
+// or 1 followed by its uvarint length and the slot.
+func encodeSlots(slots [][]byte) []byte {
+	b := binary.AppendUvarint(nil, uint64(len(slots)))
+	for _, s := range slots {
+		if s == nil {
+			b = append(b, 0)
+			continue
+		}
+		b = append(b, 1)
+		b = binary.AppendUvarint(b, uint64(len(s)))
+		b = append(b, s...)
+	}
+	return b
+}
+
+func decodeSlots(b []byte) ([][]byte, error) {
+	r := bytes.NewReader(b)
+	count, err := binary.ReadUvarint(r)
+	if err != nil || count > uint64(r.Len()) {
+		return nil, fmt.Errorf("invalid number of slots")
+	}
+	slots := make([][]byte, count)
+	for i := range slots {
+		present, err := r.ReadByte()
+		if err != nil || present > 1 {
+			return nil, fmt.Errorf("invalid slot %d", i)
+		}
+		if present == 0 {
+			continue
+		}
+		n, err := binary.ReadUvarint(r)
+		if err != nil || n > uint64(r.Len()) {
+			return nil, fmt.Errorf("invalid slot %d", i)
+		}
+		slots[i] = make([]byte, n)
+		r.Read(slots[i])
+	}
+	if r.Len() != 0 {
+		return nil, fmt.Errorf("slots have %d trailing bytes", r.Len())
+	}
+	return slots, nil
+}
+
+func (c hashCommitment) Commit(slots [][]byte) ([]byte, error) {
+	return c.hs.Hash(encodeSlots(slots))
+}
+
+func (c hashCommitment) Open(slots [][]byte, i int) ([]byte, error) {
+	others := append([][]byte(nil), slots...)
+	others[i] = nil
+	return encodeSlots(others), nil
+}
+
+func (c hashCommitment) VerifyOpening(commitment []byte, i int, slot []byte, opening []byte) error {
+	slots, err := decodeSlots(opening)
+	if err != nil {
+		return err
+	}
+	if i < 0 || i >= len(slots) || slots[i] != nil {
+		return fmt.Errorf("opening does not leave slot %d open", i)
+	}
+	slots[i] = slot
+	h, err := c.Commit(slots)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(h, commitment) {
+		return fmt.Errorf("opening does not match the commitment")
+	}
+	return nil
+}
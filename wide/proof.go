@@ -0,0 +1,108 @@
+package wide
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/example/infra/db/merkle_patricia_trie/mptproof"
+)
+
+// Level opens one slot of an inner node.
+type Level struct {
+	Commitment []byte
+	// Index is the opened slot: the key byte at the depth of the node, or the value slot for the key ending at it.
+	Index   int
+	Opening []byte
+}
+
+// Proof proves a value by opening the slots on the path of its key, from the deepest inner node up to the root.
+type Proof []Level
+
+// Size is the number of bytes of commitments and openings, for comparing with the proofs of other tries.
+func (p Proof) Size() int {
+	size := 0
+	for _, l := range p {
+		size += len(l.Commitment) + len(l.Opening)
+	}
+	return size
+}
+
+func (t *Trie) Prove(key []byte) (Proof, error) {
+	if len(key) == 0 {
+		return nil, mptproof.ErrEmptyKey
+	}
+	var path Proof
+	n := t.root
+	for depth := 0; ; depth++ {
+		index := valueSlot
+		if depth < len(key) {
+			index = int(key[depth])
+		}
+		opening, err := t.vc.Open(n.slots(), index)
+		if err != nil {
+			return nil, err
+		}
+		path = append(Proof{{Commitment: n.c, Index: index, Opening: opening}}, path...)
+
+		if index == valueSlot {
+			if n.value == nil {
+				return nil, fmt.Errorf("key = <%x> not found", key)
+			}
+			return path, nil
+		}
+		switch child := n.children[index].(type) {
+		case nil:
+			return nil, fmt.Errorf("key = <%x> not found", key)
+		case *leaf:
+			if !bytes.Equal(child.key, key) {
+				return nil, fmt.Errorf("key = <%x> not found", key)
+			}
+			return path, nil
+		case *inner:
+			n = child
+		default:
+			panic("Unknown node type")
+		}
+	}
+}
+
+// Verify checks that proof proves value is stored at key under root.
+func Verify(vc Commitment, root, key, value []byte, proof Proof) error {
+	if len(key) == 0 {
+		return mptproof.ErrEmptyKey
+	}
+	if len(proof) == 0 || len(proof) > len(key)+1 {
+		return fmt.Errorf("proof of %d levels cannot lead to a key of %d bytes", len(proof), len(key))
+	}
+	// The deepest level opens either the value slot of the node the key ends at or the slot of its leaf
+	depth := len(proof) - 1
+	var slot []byte
+	if proof[0].Index == valueSlot {
+		if depth != len(key) {
+			return fmt.Errorf("value slot opened at depth %d for a key of %d bytes", depth, len(key))
+		}
+		slot = append([]byte{}, value...)
+	} else {
+		leaf, err := vc.Commit([][]byte{key, value})
+		if err != nil {
+			return err
+		}
+		slot = leaf
+	}
+	for i, l := range proof {
+		if d := depth - i; l.Index != valueSlot && (d >= len(key) || l.Index != int(key[d])) {
+			return fmt.Errorf("level %d does not follow the key", i)
+		}
+		if i > 0 && l.Index == valueSlot {
+			return fmt.Errorf("level %d opens the value slot above the bottom", i)
+		}
+		if err := vc.VerifyOpening(l.Commitment, l.Index, slot, l.Opening); err != nil {
+			return fmt.Errorf("level %d: %w", i, err)
+		}
+		slot = l.Commitment
+	}
+	if !bytes.Equal(slot, root) {
+		return fmt.Errorf("root does not match")
+	}
+	return nil
+}
@@ -0,0 +1,200 @@
+// Package wide is an experimental trie with 256-ary nodes in the style of Verkle tries,
+// behind the Insert/Get/Prove API of the merkle patricia trie, to compare proof sizes and depths
+// with its 16-ary nodes. Node commitments are pluggable, so a vector commitment can replace hashing.
+//
+// Inner nodes have a slot per key byte and a value slot for the key ending at them.
+// A key is stored in a leaf at the shallowest depth where no other key shares its prefix.
+// There are no deletes or range proofs, and the node layout is not stable.
+package wide
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/example/infra/db/merkle_patricia_trie/mptproof"
+)
+
+// Width is the number of children of an inner node.
+const Width = 256
+
+// valueSlot is the slot of an inner node committing to the value of the key ending at it.
+const valueSlot = Width
+
+type node interface {
+	commitment() []byte
+}
+
+type leaf struct {
+	key   []byte
+	value []byte
+	c     []byte
+}
+
+func (n *leaf) commitment() []byte {
+	return n.c
+}
+
+type inner struct {
+	children [Width]node
+	value    []byte
+	c        []byte
+}
+
+func (n *inner) commitment() []byte {
+	return n.c
+}
+
+func (n *inner) slots() [][]byte {
+	slots := make([][]byte, Width+1)
+	for i, child := range n.children {
+		if child != nil {
+			slots[i] = child.commitment()
+		}
+	}
+	slots[valueSlot] = n.value
+	return slots
+}
+
+// Trie is not safe for concurrent writes.
+type Trie struct {
+	vc   Commitment
+	root *inner
+}
+
+func New(vc Commitment) *Trie {
+	root := &inner{}
+	c, err := vc.Commit(root.slots())
+	if err != nil {
+		panic("Cannot initialize the root commitment. Error of Commit(): " + err.Error())
+	}
+	root.c = c
+	return &Trie{vc: vc, root: root}
+}
+
+func (t *Trie) newLeaf(key, value []byte) (*leaf, error) {
+	c, err := t.vc.Commit([][]byte{key, value})
+	if err != nil {
+		return nil, err
+	}
+	return &leaf{key: key, value: value, c: c}, nil
+}
+
+// insert returns a copy of n with value stored at key, whose bytes before depth lead to n,
+// and the previous value, nil if none.
+func (t *Trie) insert(n *inner, key []byte, depth int, value []byte, overwrite bool) (*inner, []byte, error) {
+	cp := *n
+	var prev []byte
+	if depth == len(key) {
+		if n.value != nil && !overwrite {
+			return nil, nil, fmt.Errorf("key = <%x> already exists", key)
+		}
+		prev, cp.value = n.value, value
+	} else {
+		b := key[depth]
+		switch child := n.children[b].(type) {
+		case nil:
+			l, err := t.newLeaf(key, value)
+			if err != nil {
+				return nil, nil, err
+			}
+			cp.children[b] = l
+		case *leaf:
+			if bytes.Equal(child.key, key) {
+				if !overwrite {
+					return nil, nil, fmt.Errorf("key = <%x> already exists", key)
+				}
+				l, err := t.newLeaf(key, value)
+				if err != nil {
+					return nil, nil, err
+				}
+				prev, cp.children[b] = child.value, l
+				break
+			}
+			// Push the leaf down into a new inner node, which the insert then commits
+			sub := &inner{}
+			if len(child.key) == depth+1 {
+				sub.value = child.value
+			} else {
+				sub.children[child.key[depth+1]] = child
+			}
+			next, _, err := t.insert(sub, key, depth+1, value, overwrite)
+			if err != nil {
+				return nil, nil, err
+			}
+			cp.children[b] = next
+		case *inner:
+			next, p, err := t.insert(child, key, depth+1, value, overwrite)
+			if err != nil {
+				return nil, nil, err
+			}
+			prev, cp.children[b] = p, next
+		default:
+			panic("Unknown node type")
+		}
+	}
+	c, err := t.vc.Commit(cp.slots())
+	if err != nil {
+		return nil, nil, err
+	}
+	cp.c = c
+	return &cp, prev, nil
+}
+
+// Insert stores value at key. It fails if key already exists.
+func (t *Trie) Insert(key, value []byte) error {
+	_, err := t.put(key, value, false)
+	return err
+}
+
+// Put stores value at key, overwriting the existing value if any, and returns the previous value.
+func (t *Trie) Put(key, value []byte) ([]byte, error) {
+	return t.put(key, value, true)
+}
+
+func (t *Trie) put(key, value []byte, overwrite bool) ([]byte, error) {
+	if len(key) == 0 {
+		return nil, mptproof.ErrEmptyKey
+	}
+	// A nil value would leave the value slot empty
+	value = append([]byte{}, value...)
+	root, prev, err := t.insert(t.root, append([]byte(nil), key...), 0, value, overwrite)
+	if err != nil {
+		return nil, err
+	}
+	t.root = root
+	return prev, nil
+}
+
+func (t *Trie) Get(key []byte) ([]byte, error) {
+	if len(key) == 0 {
+		return nil, mptproof.ErrEmptyKey
+	}
+	n := t.root
+	for depth := 0; ; depth++ {
+		if depth == len(key) {
+			if n.value == nil {
+				break
+			}
+			return append([]byte(nil), n.value...), nil
+		}
+		switch child := n.children[key[depth]].(type) {
+		case nil:
+			return nil, fmt.Errorf("key = <%x> not found", key)
+		case *leaf:
+			if !bytes.Equal(child.key, key) {
+				return nil, fmt.Errorf("key = <%x> not found", key)
+			}
+			return append([]byte(nil), child.value...), nil
+		case *inner:
+			n = child
+		default:
+			panic("Unknown node type")
+		}
+	}
+	return nil, fmt.Errorf("key = <%x> not found", key)
+}
+
+// RootHash returns the commitment of the root node.
+func (t *Trie) RootHash() []byte {
+	return append([]byte(nil), t.root.c...)
+}
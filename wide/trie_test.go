@@ -0,0 +1,118 @@
+package wide
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/example/entity"
+	mpt "github.com/example/infra/db/merkle_patricia_trie"
+	"github.com/example/service/crypto"
+	"github.com/example/service/crypto/sha256"
+)
+
+func hashService(t *testing.T) crypto.Hash {
+	sha256.NewSha256()
+	hs, err := crypto.GetHashService(entity.HashSha256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hs
+}
+
+func TestTrie(t *testing.T) {
+	hs := hashService(t)
+	vc := NewHashCommitment(hs)
+
+	tr := New(vc)
+	keys := []string{"a", "ab", "abc", "b", "key1", "key2", "key10"}
+	for _, key := range keys {
+		if err := tr.Insert([]byte(key), []byte("v"+key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	{
+		t.Log("Values are found and proven, also at inner nodes")
+		for _, key := range keys {
+			value, err := tr.Get([]byte(key))
+			if err != nil || string(value) != "v"+key {
+				t.Errorf("Unexpected value = <%s> of key = <%s>, err: %v", value, key, err)
+			}
+			proof, err := tr.Prove([]byte(key))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := Verify(vc, tr.RootHash(), []byte(key), value, proof); err != nil {
+				t.Errorf("Proof of key = <%s> is rejected: %s", key, err)
+			}
+			if err := Verify(vc, tr.RootHash(), []byte(key), []byte("forged"), proof); err == nil {
+				t.Errorf("Forged value of key = <%s> must be rejected", key)
+			}
+			if err := Verify(vc, tr.RootHash(), []byte(key+"x"), value, proof); err == nil {
+				t.Errorf("Proof of key = <%s> must not prove another key", key)
+			}
+		}
+		for _, key := range []string{"k", "key3", "abcd"} {
+			if _, err := tr.Get([]byte(key)); err == nil {
+				t.Errorf("Key = <%s> must not be found", key)
+			}
+		}
+	}
+
+	{
+		t.Log("Roots do not depend on the insertion order and commit to every value")
+		other := New(vc)
+		for i := len(keys) - 1; i >= 0; i-- {
+			if err := other.Insert([]byte(keys[i]), []byte("v"+keys[i])); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if !bytes.Equal(tr.RootHash(), other.RootHash()) {
+			t.Error("Roots differ between insertion orders")
+		}
+		if err := other.Insert([]byte("ab"), []byte("x")); err == nil {
+			t.Error("Insert() of an existing key must fail")
+		}
+		if prev, err := other.Put([]byte("ab"), []byte("x")); err != nil || string(prev) != "vab" {
+			t.Errorf("Unexpected previous value = <%s>, err: %v", prev, err)
+		}
+		if bytes.Equal(tr.RootHash(), other.RootHash()) {
+			t.Error("Root must change with a value")
+		}
+	}
+}
+
+func TestTrie_ProofSize(t *testing.T) {
+	hs := hashService(t)
+
+	tr := New(NewHashCommitment(hs))
+	mt := mpt.NewMerklePatriciaTrie(hs)
+	for i := 0; i < 1000; i++ {
+		key, value := []byte(fmt.Sprintf("key%d", i)), []byte("value")
+		if err := tr.Insert(key, value); err != nil {
+			t.Fatal(err)
+		}
+		if err := mt.Insert(key, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	proof, err := tr.Prove([]byte("key500"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path, err := mt.FindMerklePath([]byte("key500"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	size, err := path.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("key500: %d levels, %d bytes with 256-ary hash commitments; %d levels, %d bytes in the 16-ary trie",
+		len(proof), proof.Size(), len(path), len(size))
+	// Hash openings carry every sibling, so wide nodes only pay off with a vector commitment
+	if len(proof) >= len(path) || proof.Size() <= len(size) {
+		t.Error("Wide proofs must be shallower but larger with hash commitments")
+	}
+}